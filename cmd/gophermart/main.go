@@ -2,14 +2,17 @@ package main
 
 import (
 	"context"
-	"github.com/go-chi/jwtauth/v5"
 	"github.com/go-co-op/gocron"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/clients"
 	"github.com/yurchenkosv/gofermart/internal/config"
 	"github.com/yurchenkosv/gofermart/internal/controllers"
 	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/events"
+	"github.com/yurchenkosv/gofermart/internal/keys"
+	"github.com/yurchenkosv/gofermart/internal/notifier"
 	"github.com/yurchenkosv/gofermart/internal/routers"
 	"net/http"
 	"os"
@@ -19,8 +22,8 @@ import (
 )
 
 var (
-	cfg       = &config.ServerConfig{}
-	tokenAuth *jwtauth.JWTAuth
+	cfg    = &config.ServerConfig{}
+	keySet *keys.KeySet
 )
 
 func init() {
@@ -33,14 +36,30 @@ func main() {
 	if err != nil {
 		log.Error(err)
 	}
-	tokenAuth = jwtauth.New("HS256", []byte(cfg.InitialTokenSecret), nil)
+	if cfg.TokenSigningKeyFile != "" {
+		keySet, err = keys.LoadKeySet(cfg.TokenSigningKeyFile, cfg.TokenVerifyKeyFiles)
+	} else {
+		log.Warn("token signing key is not set, using ephemeral key")
+		keySet, err = keys.GenerateKeySet()
+	}
+	if err != nil {
+		log.Fatal("cannot load token signing keys: ", err)
+	}
 	repo := dao.NewPGRepo(cfg.DatabaseURI)
 	repo.Migrate("file://db/migrations")
 
 	osSignal := make(chan os.Signal, 1)
 	signal.Notify(osSignal, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 
-	router := routers.NewRouter(repo, tokenAuth)
+	broker := events.NewBroker()
+	listener, err := events.ListenPostgres(cfg.DatabaseURI, broker)
+	if err != nil {
+		log.Error("cannot listen to order events, streams will only poll for them: ", err)
+	} else {
+		defer listener.Close()
+	}
+
+	router := routers.NewRouter(repo, keySet, notifier.NewNotifier(cfg), broker, cfg)
 	server := http.Server{
 		Addr:    cfg.RunAddress,
 		Handler: router,
@@ -52,12 +71,32 @@ func main() {
 	}()
 
 	sched := gocron.NewScheduler(time.UTC)
+	accrualLimiter := clients.NewAccrualLimiter(cfg.AccrualRPS)
 	_, err = sched.EveryRandom(2, 7).
 		Second().
-		Do(controllers.StatusCheckLoop, cfg, repo)
+		SingletonMode().
+		Do(controllers.StatusCheckLoop, cfg, repo, accrualLimiter)
 	if err != nil {
 		log.Fatal("cannot create scheduler for update tasks: ", err)
 	}
+	_, err = sched.Every(1).
+		Hour().
+		Do(controllers.PurgeExpiredTokens, cfg, repo)
+	if err != nil {
+		log.Fatal("cannot create scheduler for token cleanup: ", err)
+	}
+	_, err = sched.Every(1).
+		Hour().
+		Do(controllers.PurgeExpiredIdempotencyKeys, cfg, repo)
+	if err != nil {
+		log.Fatal("cannot create scheduler for idempotency keys cleanup: ", err)
+	}
+	_, err = sched.Every(5).
+		Second().
+		Do(controllers.DeliverWebhooks, cfg, repo)
+	if err != nil {
+		log.Fatal("cannot create scheduler for webhook deliveries: ", err)
+	}
 	sched.StartAsync()
 
 	<-osSignal
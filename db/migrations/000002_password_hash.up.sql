@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE users ALTER COLUMN password TYPE TEXT;
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS sessions(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens(
+    id BIGSERIAL PRIMARY KEY,
+    session_id BIGINT,
+    token_hash VARCHAR(64) UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    jti VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+COMMIT;
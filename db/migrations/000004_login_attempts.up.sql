@@ -0,0 +1,8 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS login_attempts(
+    key VARCHAR(256) PRIMARY KEY,
+    failures INTEGER,
+    last_failure_at TIMESTAMP WITH TIME ZONE,
+    locked_until TIMESTAMP WITH TIME ZONE
+);
+COMMIT;
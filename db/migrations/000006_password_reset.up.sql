@@ -0,0 +1,10 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS password_reset_tokens(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    token_hash VARCHAR(64) UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    used_at TIMESTAMP WITH TIME ZONE
+);
+COMMIT;
@@ -0,0 +1,25 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS two_factor(
+    user_id BIGINT PRIMARY KEY,
+    secret VARCHAR(64),
+    enabled BOOLEAN DEFAULT FALSE,
+    last_used_step BIGINT DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    code_hash VARCHAR(64),
+    used_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (user_id, code_hash)
+);
+
+CREATE TABLE IF NOT EXISTS login_challenges(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    token_hash VARCHAR(64) UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    attempts INT DEFAULT 0,
+    used_at TIMESTAMP WITH TIME ZONE
+);
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS admin_audit(
+    id BIGSERIAL PRIMARY KEY,
+    actor_id BIGINT,
+    action VARCHAR(64),
+    target_user_id BIGINT,
+    details TEXT,
+    created_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS admin_audit_target_user_id_idx ON admin_audit(target_user_id);
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+ALTER TABLE orders DROP COLUMN IF EXISTS merchant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS loyalty_card;
+DROP TABLE IF EXISTS merchant_api_keys;
+DROP TABLE IF EXISTS merchants;
+COMMIT;
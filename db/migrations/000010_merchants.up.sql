@@ -0,0 +1,21 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS merchants(
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(128),
+    created_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS merchant_api_keys(
+    id BIGSERIAL PRIMARY KEY,
+    merchant_id BIGINT,
+    prefix VARCHAR(16),
+    key_hash VARCHAR(64) UNIQUE,
+    scopes TEXT,
+    created_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS loyalty_card VARCHAR(32) UNIQUE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS merchant_id BIGINT;
+COMMIT;
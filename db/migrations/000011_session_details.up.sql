@@ -0,0 +1,7 @@
+BEGIN;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS ip VARCHAR(64);
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP WITH TIME ZONE;
+UPDATE sessions SET last_seen_at=created_at WHERE last_seen_at IS NULL;
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions(user_id);
+COMMIT;
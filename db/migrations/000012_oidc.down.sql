@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE IF EXISTS oidc_identities;
+DROP TABLE IF EXISTS oidc_states;
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS oidc_states(
+    id BIGSERIAL PRIMARY KEY,
+    state_hash VARCHAR(64) UNIQUE,
+    code_verifier VARCHAR(128),
+    nonce VARCHAR(64),
+    user_id BIGINT,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS oidc_identities(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    issuer VARCHAR(256),
+    subject VARCHAR(256),
+    created_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (issuer, subject)
+);
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+DROP INDEX IF EXISTS orders_user_id_status_upload_time_idx;
+DROP INDEX IF EXISTS orders_user_id_upload_time_idx;
+COMMIT;
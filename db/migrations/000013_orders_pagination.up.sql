@@ -0,0 +1,4 @@
+BEGIN;
+CREATE INDEX IF NOT EXISTS orders_user_id_upload_time_idx ON orders(user_id, upload_time, id);
+CREATE INDEX IF NOT EXISTS orders_user_id_status_upload_time_idx ON orders(user_id, status, upload_time, id);
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS order_status_history(
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT,
+    from_status VARCHAR(64),
+    to_status VARCHAR(64),
+    accrual FLOAT,
+    changed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_id_idx ON order_status_history(order_id, changed_at);
+COMMIT;
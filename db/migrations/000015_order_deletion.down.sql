@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE orders DROP COLUMN IF EXISTS polled_at;
+COMMIT;
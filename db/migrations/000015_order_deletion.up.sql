@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS polled_at TIMESTAMP WITH TIME ZONE;
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    idempotency_key VARCHAR(255),
+    fingerprint VARCHAR(64),
+    status_code INTEGER,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (user_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys(expires_at);
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+DROP INDEX IF EXISTS order_status_history_user_id_idx;
+ALTER TABLE order_status_history DROP COLUMN IF EXISTS balance_credited;
+ALTER TABLE order_status_history DROP COLUMN IF EXISTS order_number;
+ALTER TABLE order_status_history DROP COLUMN IF EXISTS user_id;
+COMMIT;
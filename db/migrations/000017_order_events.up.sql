@@ -0,0 +1,12 @@
+BEGIN;
+ALTER TABLE order_status_history ADD COLUMN IF NOT EXISTS user_id BIGINT;
+ALTER TABLE order_status_history ADD COLUMN IF NOT EXISTS order_number VARCHAR(64);
+ALTER TABLE order_status_history ADD COLUMN IF NOT EXISTS balance_credited BOOLEAN DEFAULT false;
+
+UPDATE order_status_history h
+SET user_id=o.user_id, order_number=o.number
+FROM orders o
+WHERE o.id=h.order_id AND h.user_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS order_status_history_user_id_idx ON order_status_history(user_id, id);
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS webhooks(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    url TEXT,
+    secret VARCHAR(128),
+    event_types TEXT,
+    created_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_user_id_idx ON webhooks(user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries(
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT,
+    event_type VARCHAR(64),
+    payload TEXT,
+    status VARCHAR(16),
+    attempts INTEGER DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    last_attempt_at TIMESTAMP WITH TIME ZONE,
+    response_status INTEGER,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries(webhook_id, id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries(next_attempt_at) WHERE status='pending';
+COMMIT;
@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE oidc_states DROP COLUMN IF EXISTS binding_hash;
+COMMIT;
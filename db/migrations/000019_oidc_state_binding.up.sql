@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE oidc_states ADD COLUMN IF NOT EXISTS binding_hash VARCHAR(64);
+COMMIT;
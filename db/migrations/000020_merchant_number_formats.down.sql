@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE merchants DROP COLUMN IF EXISTS number_luhn;
+ALTER TABLE merchants DROP COLUMN IF EXISTS number_pattern;
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS number_pattern VARCHAR(256);
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS number_luhn BOOLEAN NOT NULL DEFAULT FALSE;
+COMMIT;
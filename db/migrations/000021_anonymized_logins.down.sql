@@ -0,0 +1,3 @@
+BEGIN;
+UPDATE users SET username='deleted-' || id WHERE deleted_at IS NOT NULL AND username='deleted:' || id;
+COMMIT;
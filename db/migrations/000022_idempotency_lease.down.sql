@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS locked_until;
+COMMIT;
@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS rejected_status_updates(
+    id BIGSERIAL PRIMARY KEY,
+    order_number VARCHAR(64),
+    from_status VARCHAR(64),
+    to_status VARCHAR(64),
+    rejected_at TIMESTAMP WITH TIME ZONE
+);
+COMMIT;
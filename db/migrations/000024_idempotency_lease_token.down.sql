@@ -0,0 +1,5 @@
+BEGIN;
+DROP INDEX IF EXISTS withdrawals_idempotency_key_id_idx;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS idempotency_key_id;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS lease_token;
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS lease_token VARCHAR(64);
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS idempotency_key_id BIGINT;
+CREATE UNIQUE INDEX IF NOT EXISTS withdrawals_idempotency_key_id_idx ON withdrawals(idempotency_key_id);
+COMMIT;
@@ -6,38 +6,67 @@ import (
 	"github.com/go-resty/resty/v2"
 	log "github.com/sirupsen/logrus"
 	"github.com/yurchenkosv/gofermart/internal/dto"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"net/http"
 )
 
+// AccrualProvider returns order with status mapped from accrual system. Other outcomes are
+// returned as AccrualOrderNotRegisteredError, AccrualRateLimitError, AccrualServerError
+// and AccrualUnknownStatusError.
 type AccrualProvider interface {
-	GetOrderStatusByOrderNum(orderNum int) (*dto.AccrualStatus, error)
+	GetOrderStatusByOrderNum(orderNum string) (*model.Order, error)
 }
 
 type AccrualClient struct {
 	accruaSysAddress string
+	limiter          *AccrualLimiter
 }
 
-func NewAccrualClient(accrualAddress string) *AccrualClient {
-	return &AccrualClient{accruaSysAddress: accrualAddress}
+// NewAccrualClient returns client, which waits for limiter before every request. Limiter should
+// be shared by all clients, so that 429 response received by one of them pauses the others.
+func NewAccrualClient(accrualAddress string, limiter *AccrualLimiter) *AccrualClient {
+	return &AccrualClient{
+		accruaSysAddress: accrualAddress,
+		limiter:          limiter,
+	}
 }
 
-func (c AccrualClient) GetOrderStatusByOrderNum(orderNum int) (*dto.AccrualStatus, error) {
+func (c AccrualClient) GetOrderStatusByOrderNum(orderNum string) (*model.Order, error) {
 	var (
 		accrualStatus = dto.AccrualStatus{}
 	)
 	client := resty.New().
 		SetBaseURL(c.accruaSysAddress).
-		SetRetryCount(3)
+		SetRetryCount(3).
+		// hook runs before every attempt, so retries are paced by limiter too
+		OnBeforeRequest(func(*resty.Client, *resty.Request) error {
+			c.limiter.Wait()
+			return nil
+		})
 	resp, err := client.R().
-		Get(fmt.Sprintf("/api/orders/%d", orderNum))
+		Get(fmt.Sprintf("/api/orders/%s", orderNum))
 	if err != nil {
 		log.Error("error sending request to accrual system", err)
 		return nil, err
 	}
+	switch resp.StatusCode() {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, &errors.AccrualOrderNotRegisteredError{OrderNumber: orderNum}
+	case http.StatusTooManyRequests:
+		retryAfter := parseRetryAfter(resp.Header().Get("Retry-After"))
+		c.limiter.Pause(retryAfter)
+		log.Warnf("accrual system limits requests, pausing polling for %s", retryAfter)
+		return nil, &errors.AccrualRateLimitError{RetryAfter: retryAfter}
+	default:
+		return nil, &errors.AccrualServerError{StatusCode: resp.StatusCode()}
+	}
 	log.Info("received responce from accrual system: ", string(resp.Body()))
 	err = json.Unmarshal(resp.Body(), &accrualStatus)
 	if err != nil {
 		log.Error("error unmarshalling json: ", err)
 		return nil, err
 	}
-	return &accrualStatus, nil
+	return orderFromAccrualStatus(orderNum, &accrualStatus)
 }
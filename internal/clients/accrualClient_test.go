@@ -0,0 +1,128 @@
+package clients
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAccrualClient_GetOrderStatusByOrderNum(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("No more than 1 requests per minute allowed"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"order":"79927398713","status":"PROCESSED","accrual":500}`))
+	}))
+	defer server.Close()
+
+	limiter := NewAccrualLimiter(0)
+	client := NewAccrualClient(server.URL, limiter)
+	_, err := client.GetOrderStatusByOrderNum("79927398713")
+	require.IsType(t, &errors.AccrualRateLimitError{}, err)
+	assert.Equal(t, time.Second, err.(*errors.AccrualRateLimitError).RetryAfter)
+
+	// the second client shares limiter, so it waits for the pause too
+	start := time.Now()
+	status, err := NewAccrualClient(server.URL, limiter).GetOrderStatusByOrderNum("79927398713")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+	assert.Equal(t, "PROCESSED", status.Status)
+	assert.Equal(t, float32(500), *status.Accrual)
+}
+
+func TestAccrualClient_GetOrderStatusByOrderNum_Results(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantStatus  string
+		wantAccrual bool
+		wantErrType error
+	}{
+		{
+			name:       "should map REGISTERED to NEW",
+			status:     http.StatusOK,
+			body:       `{"order":"79927398713","status":"REGISTERED"}`,
+			wantStatus: model.OrderStatusNew,
+		},
+		{
+			name:       "should drop accrual of order in processing",
+			status:     http.StatusOK,
+			body:       `{"order":"79927398713","status":"PROCESSING","accrual":10}`,
+			wantStatus: model.OrderStatusProcessing,
+		},
+		{
+			name:        "should keep accrual of processed order",
+			status:      http.StatusOK,
+			body:        `{"order":"79927398713","status":"PROCESSED","accrual":10}`,
+			wantStatus:  model.OrderStatusProcessed,
+			wantAccrual: true,
+		},
+		{
+			name:        "should reject unknown status",
+			status:      http.StatusOK,
+			body:        `{"order":"79927398713","status":"LOST"}`,
+			wantErrType: &errors.AccrualUnknownStatusError{},
+		},
+		{
+			name:        "should return not registered for 204",
+			status:      http.StatusNoContent,
+			wantErrType: &errors.AccrualOrderNotRegisteredError{},
+		},
+		{
+			name:        "should return server error for 500",
+			status:      http.StatusInternalServerError,
+			body:        "internal error",
+			wantErrType: &errors.AccrualServerError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/orders/79927398713", r.URL.Path)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			order, err := NewAccrualClient(server.URL, NewAccrualLimiter(0)).GetOrderStatusByOrderNum("79927398713")
+			if tt.wantErrType != nil {
+				assert.IsType(t, tt.wantErrType, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "79927398713", order.Number)
+			assert.Equal(t, tt.wantStatus, order.Status)
+			assert.Equal(t, tt.wantAccrual, order.Accrual != nil)
+		})
+	}
+}
+
+func TestAccrualLimiter_Wait(t *testing.T) {
+	limiter := NewAccrualLimiter(20)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		limiter.Wait()
+	}
+	// the first request goes immediately, the others are spaced by 50ms
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	assert.Equal(t, 60*time.Second, parseRetryAfter("60"))
+	assert.Equal(t, defaultAccrualRetryAfter, parseRetryAfter("soon"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Mon, 02 Jan 2006 15:04:05 GMT"))
+	retryAfter := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.InDelta(t, time.Hour, retryAfter, float64(2*time.Second))
+}
@@ -0,0 +1,84 @@
+package clients
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultAccrualRetryAfter is pause after 429 response without valid Retry-After header.
+const defaultAccrualRetryAfter = time.Minute
+
+// AccrualLimiter paces requests of all accrual clients sharing it. Requests are spaced evenly
+// to stay under rps, and all of them are held back after accrual system answers 429.
+type AccrualLimiter struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	next        time.Time
+	pausedUntil time.Time
+}
+
+// NewAccrualLimiter returns limiter allowing rps requests per second, zero rps removes the cap
+// leaving only pauses requested by accrual system.
+func NewAccrualLimiter(rps float64) *AccrualLimiter {
+	limiter := &AccrualLimiter{}
+	if rps > 0 {
+		limiter.interval = time.Duration(float64(time.Second) / rps)
+	}
+	return limiter
+}
+
+// Wait blocks until request may be sent.
+func (l *AccrualLimiter) Wait() {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		slot := now
+		if l.next.After(slot) {
+			slot = l.next
+		}
+		if l.pausedUntil.After(slot) {
+			slot = l.pausedUntil
+		}
+		if !slot.After(now) {
+			l.next = now.Add(l.interval)
+			l.mu.Unlock()
+			return
+		}
+		l.mu.Unlock()
+		// slot is not reserved, as pause may be prolonged meanwhile
+		time.Sleep(slot.Sub(now))
+	}
+}
+
+// Pause holds back all requests until retryAfter passes.
+func (l *AccrualLimiter) Pause(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until := time.Now().Add(retryAfter)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// PausedUntil returns time, until which requests are held back after 429 response.
+func (l *AccrualLimiter) PausedUntil() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pausedUntil
+}
+
+// parseRetryAfter reads Retry-After header given either in seconds or as HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if retryAfter := time.Until(date); retryAfter > 0 {
+			return retryAfter
+		}
+		return 0
+	}
+	return defaultAccrualRetryAfter
+}
@@ -0,0 +1,36 @@
+package clients
+
+import (
+	"github.com/yurchenkosv/gofermart/internal/dto"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+)
+
+// accrualOrderStatuses maps statuses of accrual system to order statuses. REGISTERED order is
+// known to accrual system, but its processing has not started, so for gofermart it is still NEW.
+var accrualOrderStatuses = map[string]string{
+	dto.AccrualStatusRegistered: model.OrderStatusNew,
+	dto.AccrualStatusProcessing: model.OrderStatusProcessing,
+	dto.AccrualStatusInvalid:    model.OrderStatusInvalid,
+	dto.AccrualStatusProcessed:  model.OrderStatusProcessed,
+}
+
+// orderFromAccrualStatus converts response of accrual system to order with domain status.
+// Accrual is kept only for processed order.
+func orderFromAccrualStatus(orderNum string, accrualStatus *dto.AccrualStatus) (*model.Order, error) {
+	status, ok := accrualOrderStatuses[accrualStatus.Status]
+	if !ok {
+		return nil, &errors.AccrualUnknownStatusError{
+			OrderNumber: orderNum,
+			Status:      accrualStatus.Status,
+		}
+	}
+	order := model.Order{
+		Number: orderNum,
+		Status: status,
+	}
+	if status == model.OrderStatusProcessed {
+		order.Accrual = accrualStatus.Accrual
+	}
+	return &order, nil
+}
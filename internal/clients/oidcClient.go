@@ -0,0 +1,212 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/dto"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+	oidcClockSkew     = time.Minute
+)
+
+type OIDCProvider interface {
+	// AuthCodeURL returns URL user is sent to for authorization code flow with PKCE S256 challenge.
+	AuthCodeURL(state string, nonce string, codeChallenge string) (string, error)
+	// Exchange redeems authorization code and returns claims of verified ID token.
+	Exchange(code string, codeVerifier string, nonce string) (*dto.OIDCClaims, error)
+}
+
+// OIDCClient talks to OpenID Connect provider. Discovery document and signing keys
+// are fetched on first use, keys are fetched again when token is signed by unknown key.
+type OIDCClient struct {
+	discoveryURL string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	http         *resty.Client
+
+	mu       sync.Mutex
+	metadata *dto.OIDCProviderMetadata
+	keys     jwk.Set
+}
+
+func NewOIDCClient(discoveryURL, clientID, clientSecret, redirectURL string, scopes []string) *OIDCClient {
+	discoveryURL = strings.TrimSuffix(discoveryURL, "/")
+	if !strings.HasSuffix(discoveryURL, oidcDiscoveryPath) {
+		discoveryURL += oidcDiscoveryPath
+	}
+	return &OIDCClient{
+		discoveryURL: discoveryURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		http:         resty.New().SetTimeout(10 * time.Second),
+	}
+}
+
+func (c *OIDCClient) AuthCodeURL(state string, nonce string, codeChallenge string) (string, error) {
+	metadata, err := c.getMetadata()
+	if err != nil {
+		return "", err
+	}
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", c.clientID)
+	query.Set("redirect_uri", c.redirectURL)
+	query.Set("scope", strings.Join(c.scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+func (c *OIDCClient) Exchange(code string, codeVerifier string, nonce string) (*dto.OIDCClaims, error) {
+	metadata, err := c.getMetadata()
+	if err != nil {
+		return nil, err
+	}
+	var tokenResponse dto.OIDCTokenResponse
+	request := c.http.R().
+		SetFormData(map[string]string{
+			"grant_type":    "authorization_code",
+			"code":          code,
+			"redirect_uri":  c.redirectURL,
+			"client_id":     c.clientID,
+			"code_verifier": codeVerifier,
+		})
+	if c.clientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+	}
+	resp, err := request.Post(metadata.TokenEndpoint)
+	if err != nil {
+		log.Error("error sending request to openid connect provider ", err)
+		return nil, err
+	}
+	err = json.Unmarshal(resp.Body(), &tokenResponse)
+	if err != nil && resp.IsSuccess() {
+		log.Error("error unmarshalling json: ", err)
+		return nil, err
+	}
+	if resp.IsError() || tokenResponse.IDToken == "" {
+		reason := tokenResponse.Error
+		if reason == "" {
+			reason = fmt.Sprintf("token endpoint responded with %d and no id token", resp.StatusCode())
+		}
+		return nil, &errors.OIDCAuthenticationError{Reason: reason}
+	}
+	return c.verifyIDToken(metadata, tokenResponse.IDToken, nonce)
+}
+
+func (c *OIDCClient) verifyIDToken(metadata *dto.OIDCProviderMetadata, idToken string, nonce string) (*dto.OIDCClaims, error) {
+	keys, err := c.getKeys(metadata, false)
+	if err != nil {
+		return nil, err
+	}
+	options := []jwt.ParseOption{
+		jwt.WithValidate(true),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(c.clientID),
+		jwt.WithAcceptableSkew(oidcClockSkew),
+	}
+	token, err := jwt.ParseString(idToken, append(options, jwt.WithKeySet(keys))...)
+	if err != nil {
+		// provider may have rotated keys since they were fetched
+		keys, err = c.getKeys(metadata, true)
+		if err != nil {
+			return nil, err
+		}
+		token, err = jwt.ParseString(idToken, append(options, jwt.WithKeySet(keys))...)
+		if err != nil {
+			return nil, &errors.OIDCAuthenticationError{Reason: err.Error()}
+		}
+	}
+	tokenNonce, _ := token.Get("nonce")
+	if tokenNonce != nonce {
+		return nil, &errors.OIDCAuthenticationError{Reason: "nonce mismatch"}
+	}
+	if token.Subject() == "" {
+		return nil, &errors.OIDCAuthenticationError{Reason: "no subject in id token"}
+	}
+	claims := dto.OIDCClaims{
+		Issuer:  token.Issuer(),
+		Subject: token.Subject(),
+	}
+	claims.Email, _ = stringClaim(token, "email")
+	claims.PreferredUsername, _ = stringClaim(token, "preferred_username")
+	if verified, ok := token.Get("email_verified"); ok {
+		claims.EmailVerified, _ = verified.(bool)
+	}
+	return &claims, nil
+}
+
+func (c *OIDCClient) getMetadata() (*dto.OIDCProviderMetadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+	var metadata dto.OIDCProviderMetadata
+	resp, err := c.http.R().Get(c.discoveryURL)
+	if err != nil {
+		log.Error("error fetching openid connect discovery document ", err)
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("openid connect discovery responded with %d", resp.StatusCode())
+	}
+	err = json.Unmarshal(resp.Body(), &metadata)
+	if err != nil {
+		log.Error("error unmarshalling json: ", err)
+		return nil, err
+	}
+	if metadata.Issuer == "" || metadata.AuthorizationEndpoint == "" ||
+		metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("incomplete openid connect discovery document at %s", c.discoveryURL)
+	}
+	c.metadata = &metadata
+	return c.metadata, nil
+}
+
+func (c *OIDCClient) getKeys(metadata *dto.OIDCProviderMetadata, refresh bool) (jwk.Set, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys != nil && !refresh {
+		return c.keys, nil
+	}
+	keys, err := jwk.Fetch(context.Background(), metadata.JWKSURI)
+	if err != nil {
+		log.Error("error fetching openid connect provider keys ", err)
+		return nil, err
+	}
+	c.keys = keys
+	return c.keys, nil
+}
+
+func stringClaim(token jwt.Token, name string) (string, bool) {
+	value, ok := token.Get(name)
+	if !ok {
+		return "", false
+	}
+	s, ok := value.(string)
+	return s, ok
+}
@@ -0,0 +1,170 @@
+package clients
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/keys"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// standInIdP is minimal OpenID Connect provider, which issues ID token for a single
+// authorization code and checks PKCE verifier of the token request.
+type standInIdP struct {
+	server        *httptest.Server
+	keySet        *keys.KeySet
+	clientID      string
+	clientSecret  string
+	code          string
+	codeChallenge string
+	nonce         string
+	subject       string
+	audience      string
+}
+
+func newStandInIdP(t *testing.T) *standInIdP {
+	keySet, err := keys.GenerateKeySet()
+	require.NoError(t, err)
+	idp := &standInIdP{
+		keySet:       keySet,
+		clientID:     "gofermart",
+		clientSecret: "secret",
+		code:         "auth-code",
+		subject:      "subject-1",
+		audience:     "gofermart",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(idp.keySet.PublicKeys())
+	})
+	mux.HandleFunc("/token", idp.handleToken)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// authorize plays user signing in at provider.
+func (idp *standInIdP) authorize(t *testing.T, authCodeURL string) {
+	parsed, err := url.Parse(authCodeURL)
+	require.NoError(t, err)
+	query := parsed.Query()
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	idp.codeChallenge = query.Get("code_challenge")
+	idp.nonce = query.Get("nonce")
+}
+
+func (idp *standInIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	if clientID != idp.clientID || clientSecret != idp.clientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if r.PostFormValue("code") != idp.code ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != idp.codeChallenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	idToken, _ := idp.keySet.Encode(map[string]interface{}{
+		"iss":                idp.server.URL,
+		"sub":                idp.subject,
+		"aud":                idp.audience,
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"nonce":              idp.nonce,
+		"preferred_username": "jdoe",
+		"email":              "jdoe@example.com",
+		"email_verified":     true,
+	})
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func TestOIDCClient_Exchange(t *testing.T) {
+	verifier := "verifier-of-at-least-forty-three-characters-long"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		name        string
+		prepare     func(idp *standInIdP)
+		verifier    string
+		nonce       string
+		wantErr     assert.ErrorAssertionFunc
+		wantErrType error
+	}{
+		{
+			name:        "should return claims of verified id token",
+			prepare:     func(idp *standInIdP) {},
+			verifier:    verifier,
+			nonce:       "nonce",
+			wantErr:     assert.NoError,
+			wantErrType: nil,
+		},
+		{
+			name:        "should reject wrong code verifier",
+			prepare:     func(idp *standInIdP) {},
+			verifier:    "another-verifier-of-at-least-forty-three-characters",
+			nonce:       "nonce",
+			wantErr:     assert.Error,
+			wantErrType: &errors.OIDCAuthenticationError{},
+		},
+		{
+			name:        "should reject nonce mismatch",
+			prepare:     func(idp *standInIdP) { idp.nonce = "replayed" },
+			verifier:    verifier,
+			nonce:       "nonce",
+			wantErr:     assert.Error,
+			wantErrType: &errors.OIDCAuthenticationError{},
+		},
+		{
+			name:        "should reject token issued for another client",
+			prepare:     func(idp *standInIdP) { idp.audience = "another" },
+			verifier:    verifier,
+			nonce:       "nonce",
+			wantErr:     assert.Error,
+			wantErrType: &errors.OIDCAuthenticationError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newStandInIdP(t)
+			c := NewOIDCClient(idp.server.URL, idp.clientID, idp.clientSecret, "http://localhost/callback", []string{"openid"})
+
+			authCodeURL, err := c.AuthCodeURL("state", "nonce", challenge)
+			require.NoError(t, err)
+			idp.authorize(t, authCodeURL)
+			tt.prepare(idp)
+
+			claims, err := c.Exchange(idp.code, tt.verifier, tt.nonce)
+			tt.wantErr(t, err)
+			assert.IsType(t, tt.wantErrType, err)
+			if err == nil {
+				assert.Equal(t, idp.server.URL, claims.Issuer)
+				assert.Equal(t, "subject-1", claims.Subject)
+				assert.Equal(t, "jdoe", claims.PreferredUsername)
+				assert.True(t, claims.EmailVerified)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package clients
+
+import (
+	"context"
+	"github.com/go-resty/resty/v2"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// sharedAddressSpace is carrier-grade NAT range (RFC 6598), often used for cluster networks.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// WebhookSender posts webhook event to user endpoint and returns status of the response.
+type WebhookSender interface {
+	// CheckHost returns WebhookDestinationError when host resolves to address, which is not
+	// public, e.g. loopback, private or link-local one.
+	CheckHost(host string) error
+	Send(url string, headers map[string]string, body []byte) (int, error)
+}
+
+type WebhookClient struct {
+	client *resty.Client
+}
+
+// NewWebhookClient returns client, which neither retries nor follows redirects: retries are
+// scheduled by caller and the endpoint is exactly the one registered by user. Connections
+// to non-public addresses are refused when dialing, so that host cannot be rebound to
+// internal address after it was checked.
+func NewWebhookClient(timeout time.Duration) *WebhookClient {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !publicIP(ip) {
+				return &errors.WebhookDestinationError{Host: host}
+			}
+			return nil
+		},
+	}
+	return &WebhookClient{
+		client: resty.New().
+			SetTimeout(timeout).
+			SetRedirectPolicy(resty.NoRedirectPolicy()).
+			// proxy is not used, as dialer would check address of the proxy instead of endpoint
+			SetTransport(&http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+			}),
+	}
+}
+
+func (c WebhookClient) CheckHost(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addresses) == 0 {
+		return &errors.WebhookDestinationError{Host: host}
+	}
+	for _, address := range addresses {
+		if !publicIP(address.IP) {
+			return &errors.WebhookDestinationError{Host: host}
+		}
+	}
+	return nil
+}
+
+func (c WebhookClient) Send(url string, headers map[string]string, body []byte) (int, error) {
+	resp, err := c.client.R().
+		SetHeaders(headers).
+		SetBody(body).
+		Post(url)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode(), nil
+}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified() &&
+		!sharedAddressSpace.Contains(ip)
+}
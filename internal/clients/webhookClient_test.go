@@ -0,0 +1,50 @@
+package clients
+
+import (
+	errors2 "errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookClient_Send(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request to loopback address must not be sent")
+	}))
+	defer server.Close()
+
+	_, err := NewWebhookClient(time.Second).Send(server.URL, nil, []byte("{}"))
+	var destinationErr *errors.WebhookDestinationError
+	assert.True(t, errors2.As(err, &destinationErr))
+}
+
+func TestWebhookClient_CheckHost(t *testing.T) {
+	client := NewWebhookClient(time.Second)
+	assert.IsType(t, &errors.WebhookDestinationError{}, client.CheckHost("localhost"))
+	assert.IsType(t, &errors.WebhookDestinationError{}, client.CheckHost("169.254.169.254"))
+	assert.NoError(t, client.CheckHost("93.184.216.34"))
+}
+
+func Test_publicIP(t *testing.T) {
+	for address, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:2800::1":    true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		assert.Equal(t, want, publicIP(net.ParseIP(address)), address)
+	}
+}
@@ -4,13 +4,122 @@ import (
 	"flag"
 	"github.com/caarlos0/env/v6"
 	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	RetentionPolicyRetain  = "retain"
+	RetentionPolicyCascade = "cascade"
 )
 
 type ServerConfig struct {
 	RunAddress           string `env:"RUN_ADDRESS" envDefault:"0.0.0.0:8080"`
 	DatabaseURI          string `env:"DATABASE_URI"`
 	AccrualSystemAddress string `env:"ACCRUAL_SYSTEM_ADDRESS"`
-	InitialTokenSecret   string `env:"TOKEN_SECRET" envDefault:"secret"`
+	// AccrualRPS caps requests per second to accrual system, zero removes the cap.
+	AccrualRPS float64 `env:"ACCRUAL_RPS" envDefault:"10"`
+	// TokenSigningKeyFile is PEM encoded RSA, Ed25519 or ECDSA private key used to sign tokens.
+	// When empty, ephemeral key is generated on start.
+	TokenSigningKeyFile string `env:"TOKEN_SIGNING_KEY_FILE"`
+	// TokenVerifyKeyFiles are keys of previous rotations, still accepted for token verification.
+	TokenVerifyKeyFiles []string `env:"TOKEN_VERIFY_KEY_FILES" envSeparator:","`
+
+	AccessTokenTTL  time.Duration `env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL"`
+	CookieName      string        `env:"COOKIE_NAME"`
+	CookieDomain    string        `env:"COOKIE_DOMAIN"`
+	CookiePath      string        `env:"COOKIE_PATH"`
+	CookieSecure    bool          `env:"COOKIE_SECURE"`
+	CookieHTTPOnly  bool          `env:"COOKIE_HTTP_ONLY"`
+	// CookieSameSite is one of lax, strict or none.
+	CookieSameSite string `env:"COOKIE_SAME_SITE"`
+
+	// LoginDelayAfter is number of failed attempts for a login, after which every next attempt
+	// is allowed only after exponentially growing delay.
+	LoginDelayAfter int `env:"LOGIN_DELAY_AFTER" envDefault:"3"`
+	// LoginLockoutAfter is number of failed attempts for a login, which locks it for LoginLockoutDuration.
+	LoginLockoutAfter int `env:"LOGIN_LOCKOUT_AFTER" envDefault:"10"`
+	// LoginIPLockoutAfter is number of failed attempts from a single client address, which locks it.
+	LoginIPLockoutAfter  int           `env:"LOGIN_IP_LOCKOUT_AFTER" envDefault:"100"`
+	LoginLockoutDuration time.Duration `env:"LOGIN_LOCKOUT_DURATION" envDefault:"15m"`
+	// LoginFailureWindow is period after last failure, after which failures counter starts over.
+	LoginFailureWindow time.Duration `env:"LOGIN_FAILURE_WINDOW" envDefault:"15m"`
+
+	PasswordMinLength int `env:"PASSWORD_MIN_LENGTH" envDefault:"8"`
+	// PasswordRequireMixed requires passwords to contain both letters and digits.
+	PasswordRequireMixed bool `env:"PASSWORD_REQUIRE_MIXED" envDefault:"true"`
+	// AccountRetentionPolicy defines what happens to orders, balance and withdrawals of deleted account:
+	// "retain" keeps them bound to anonymized user, "cascade" deletes them.
+	AccountRetentionPolicy string `env:"ACCOUNT_RETENTION_POLICY" envDefault:"retain"`
+	// PasswordResetTTL is lifetime of one-time password reset tokens.
+	PasswordResetTTL time.Duration `env:"PASSWORD_RESET_TTL" envDefault:"30m"`
+
+	// TwoFactorIssuer is shown by authenticator apps next to the account name.
+	TwoFactorIssuer string `env:"TWO_FACTOR_ISSUER" envDefault:"Gophermart"`
+	// TwoFactorChallengeTTL is time given to enter TOTP code after correct password.
+	TwoFactorChallengeTTL time.Duration `env:"TWO_FACTOR_CHALLENGE_TTL" envDefault:"5m"`
+	// TwoFactorWithdrawThreshold is withdrawal sum, above which users with enabled two-factor
+	// authentication have to confirm withdrawal with TOTP code. Zero disables confirmation.
+	TwoFactorWithdrawThreshold float64 `env:"TWO_FACTOR_WITHDRAW_THRESHOLD" envDefault:"0"`
+
+	// NotificationFile is file notifications are appended to when SMTP is not configured.
+	NotificationFile string `env:"NOTIFICATION_FILE"`
+	// NotificationLog enables writing notifications to the log, when neither SMTP nor notification
+	// file is configured. It is meant for development only, as notifications contain reset tokens.
+	NotificationLog bool `env:"NOTIFICATION_LOG"`
+	// NotificationTimeout is time delivery of notification is waited for before it is reported as failed.
+	NotificationTimeout time.Duration `env:"NOTIFICATION_TIMEOUT" envDefault:"30s"`
+	// SMTPAddress is host:port of mail server used to deliver notifications to users.
+	SMTPAddress  string `env:"SMTP_ADDRESS"`
+	SMTPUsername string `env:"SMTP_USERNAME"`
+	SMTPPassword string `env:"SMTP_PASSWORD"`
+	SMTPFrom     string `env:"SMTP_FROM" envDefault:"gophermart@localhost"`
+
+	// OIDCDiscoveryURL is issuer URL of OpenID Connect provider or full URL of its discovery
+	// document. Sign in with the provider is disabled when empty.
+	OIDCDiscoveryURL string   `env:"OIDC_DISCOVERY_URL"`
+	OIDCClientID     string   `env:"OIDC_CLIENT_ID"`
+	OIDCClientSecret string   `env:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL  string   `env:"OIDC_REDIRECT_URL"`
+	OIDCScopes       []string `env:"OIDC_SCOPES" envSeparator:"," envDefault:"openid,profile,email"`
+	// OIDCStateTTL is time given to user to sign in at provider.
+	OIDCStateTTL time.Duration `env:"OIDC_STATE_TTL" envDefault:"10m"`
+
+	// IdempotencyKeyTTL is period, during which response to request with Idempotency-Key header
+	// is replayed for retries with the same key.
+	IdempotencyKeyTTL time.Duration `env:"IDEMPOTENCY_KEY_TTL" envDefault:"24h"`
+	// IdempotencyLease is lease of request with Idempotency-Key header, renewed while it runs. Retry
+	// with the same key takes request over, when lease was not renewed, e.g. after crash of the
+	// instance processing it.
+	IdempotencyLease time.Duration `env:"IDEMPOTENCY_LEASE" envDefault:"1m"`
+
+	// WebhookMaxAttempts is number of attempts to deliver webhook event, after which delivery fails.
+	WebhookMaxAttempts int `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"8"`
+	// WebhookRetryInterval is delay before the first retry, every next retry waits twice longer.
+	WebhookRetryInterval time.Duration `env:"WEBHOOK_RETRY_INTERVAL" envDefault:"30s"`
+	WebhookTimeout       time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"10s"`
+	// WebhookAllowHTTP allows plain http webhook URLs, e.g. for development. Only https is accepted by default.
+	WebhookAllowHTTP bool `env:"WEBHOOK_ALLOW_HTTP"`
+
+	// TrustProxyHeaders enables taking client address from X-Forwarded-For and X-Real-IP headers.
+	TrustProxyHeaders bool `env:"TRUST_PROXY_HEADERS"`
+	// AdminToken allows assigning roles via /api/admin/token endpoints with X-Admin-Token header,
+	// e.g. to grant admin role to the first operator. These endpoints are disabled when empty.
+	AdminToken string `env:"ADMIN_TOKEN"`
+}
+
+// SameSite converts CookieSameSite to http.SameSite, defaulting to lax mode.
+func (config *ServerConfig) SameSite() http.SameSite {
+	switch strings.ToLower(config.CookieSameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
 }
 
 func (config *ServerConfig) Parse() error {
@@ -32,6 +141,68 @@ func (config *ServerConfig) Parse() error {
 		"",
 		"-r https://<address>:<port>",
 	)
+	flag.StringVar(
+		&config.TokenSigningKeyFile,
+		"k",
+		"",
+		"-k <path to PEM private key for token signing>",
+	)
+	flag.Func(
+		"kv",
+		"-kv <path to PEM key accepted for token verification>, can be repeated",
+		func(file string) error {
+			config.TokenVerifyKeyFiles = append(config.TokenVerifyKeyFiles, file)
+			return nil
+		},
+	)
+	flag.DurationVar(
+		&config.AccessTokenTTL,
+		"token-ttl",
+		5*time.Minute,
+		"-token-ttl <access token lifetime>, default 5m",
+	)
+	flag.DurationVar(
+		&config.RefreshTokenTTL,
+		"refresh-token-ttl",
+		30*24*time.Hour,
+		"-refresh-token-ttl <refresh token lifetime>, default 720h",
+	)
+	flag.StringVar(
+		&config.CookieName,
+		"cookie-name",
+		"jwt",
+		"-cookie-name <name of access token cookie>, default jwt",
+	)
+	flag.StringVar(
+		&config.CookieDomain,
+		"cookie-domain",
+		"",
+		"-cookie-domain <domain of cookies>",
+	)
+	flag.StringVar(
+		&config.CookiePath,
+		"cookie-path",
+		"/",
+		"-cookie-path <path of access token cookie>, default /",
+	)
+	flag.BoolVar(
+		&config.CookieSecure,
+		"cookie-secure",
+		false,
+		"-cookie-secure, send cookies only over https",
+	)
+	flag.BoolVar(
+		&config.CookieHTTPOnly,
+		"cookie-http-only",
+		true,
+		"-cookie-http-only, hide access token cookie from javascript, default true",
+	)
+	flag.StringVar(
+		&config.CookieSameSite,
+		"cookie-same-site",
+		"lax",
+		"-cookie-same-site <lax|strict|none>, default lax",
+	)
 	flag.Parse()
 
 	err := env.Parse(config)
@@ -8,25 +8,42 @@ import (
 	"github.com/yurchenkosv/gofermart/internal/errors"
 	"github.com/yurchenkosv/gofermart/internal/model"
 	"github.com/yurchenkosv/gofermart/internal/service"
-	"strconv"
+	"github.com/yurchenkosv/gofermart/internal/validator"
+	"sync"
+	"time"
 )
 
-func UpdateOrderStatusFromAccrualSys(order int, repo dao.Repository, client clients.AccrualProvider) error {
-	accrualStatus, err := client.GetOrderStatusByOrderNum(order)
+func UpdateOrderStatusFromAccrualSys(order string, repo dao.Repository, client clients.AccrualProvider) error {
+	orderToUpdate, err := client.GetOrderStatusByOrderNum(order)
+	if err != nil {
+		switch err.(type) {
+		case *errors.AccrualOrderNotRegisteredError:
+			// order may be registered by merchant later, it is polled again in the next loop
+			log.Infof("order %s is not registered in accrual system yet", order)
+			return nil
+		case *errors.AccrualRateLimitError:
+			// polling is already paused by shared limiter
+		case *errors.AccrualServerError, *errors.AccrualUnknownStatusError:
+			log.Errorf("cannot get status of order %s: %s", order, err)
+		default:
+			log.Error(err)
+		}
+		return err
+	}
+	// order known to accrual system cannot be deleted by user anymore, as it may be processed already
+	err = repo.MarkOrderPolled(order)
 	if err != nil {
 		log.Error(err)
 		return err
 	}
-
-	orderToUpdate := model.Order{
-		Number:  accrualStatus.OrderNum,
-		Accrual: accrualStatus.Accrual,
-		Status:  accrualStatus.Status,
+	if orderToUpdate.Status == model.OrderStatusNew {
+		log.Infof("order %s is registered in accrual system, waiting for processing", order)
+		return nil
 	}
 
-	orderService := service.NewOrderService(repo)
+	orderService := service.NewOrderService(repo, validator.NewRegistry())
 
-	err = orderService.UpdateOrderStatus(orderToUpdate)
+	err = orderService.UpdateOrderStatus(*orderToUpdate)
 	if err != nil {
 		switch err.(type) {
 		case *errors.NoOrdersError:
@@ -34,6 +51,8 @@ func UpdateOrderStatusFromAccrualSys(order int, repo dao.Repository, client clie
 			return err
 		case *errors.OrderNoChangeError:
 			log.Warnf("order %s status not updated yet %s", orderToUpdate.Number, err)
+		case *errors.OrderStatusTransitionError:
+			// rejected update is already logged and counted by order service
 		default:
 			log.Error("error updating order: ", err)
 			return err
@@ -50,20 +69,27 @@ func GetOrdersForStatusCheck(repository dao.Repository) []*model.Order {
 	return orders
 }
 
-func StatusCheckLoop(cfg *config.ServerConfig, repo dao.Repository) {
+// StatusCheckLoop polls accrual system for orders waiting for status update. Requests of all
+// orders share limiter, so the loop is skipped while accrual system asked to pause.
+func StatusCheckLoop(cfg *config.ServerConfig, repo dao.Repository, limiter *clients.AccrualLimiter) {
+	if pausedUntil := limiter.PausedUntil(); time.Now().Before(pausedUntil) {
+		log.Infof("accrual polling is paused until %s", pausedUntil.Format(time.RFC3339))
+		return
+	}
 	orders := GetOrdersForStatusCheck(repo)
+	accrualClient := clients.NewAccrualClient(cfg.AccrualSystemAddress, limiter)
+	var wg sync.WaitGroup
 	for i := range orders {
-		orderNum, err := strconv.Atoi(orders[i].Number)
-		if err != nil {
-			log.Error("cannot parse order num", err)
-			continue
-		}
+		orderNum := orders[i].Number
+		wg.Add(1)
 		go func() {
-			accrualClient := clients.NewAccrualClient(cfg.AccrualSystemAddress)
-			err = UpdateOrderStatusFromAccrualSys(orderNum, repo, accrualClient)
+			defer wg.Done()
+			err := UpdateOrderStatusFromAccrualSys(orderNum, repo, accrualClient)
 			if err != nil {
 				return
 			}
 		}()
 	}
+	// next loop must not start before this one is done, or the same orders are polled twice
+	wg.Wait()
 }
@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/service"
+)
+
+func PurgeExpiredIdempotencyKeys(cfg *config.ServerConfig, repo dao.Repository) {
+	idempotencyService := service.NewIdempotencyService(repo, cfg)
+	err := idempotencyService.PurgeExpired()
+	if err != nil {
+		log.Error("error purging expired idempotency keys: ", err)
+	}
+}
@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/service"
+)
+
+func PurgeExpiredTokens(cfg *config.ServerConfig, repo dao.Repository) {
+	tokenService := service.NewTokenService(repo, nil, cfg)
+	err := tokenService.PurgeExpired()
+	if err != nil {
+		log.Error("error purging expired tokens: ", err)
+	}
+}
@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/clients"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/service"
+)
+
+func DeliverWebhooks(cfg *config.ServerConfig, repo dao.Repository) {
+	webhookService := service.NewWebhookService(repo, clients.NewWebhookClient(cfg.WebhookTimeout), cfg)
+	err := webhookService.DeliverPending()
+	if err != nil {
+		log.Error("error delivering webhooks: ", err)
+	}
+}
@@ -3,6 +3,7 @@ package dao
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	errors2 "errors"
 	"fmt"
 	"github.com/golang-migrate/migrate/v4"
@@ -10,10 +11,20 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/jackc/pgx/v4"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 	"github.com/yurchenkosv/gofermart/internal/model"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// OrderEventsChannel is notified with user id, when status of order of the user changes.
+const OrderEventsChannel = "order_events"
+
+// orderEventsLockClass is the first key of advisory locks serializing status transitions of user orders.
+const orderEventsLockClass = 1
+
 type QueryAble interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
 	Prepare(query string) (*sql.Stmt, error)
@@ -150,6 +161,33 @@ func (repo *PostgresRepository) GetBalanceByUserID(userID int) (*model.Balance,
 	return balance, nil
 }
 
+// LockBalanceByUserID returns balance of user locked until end of transaction, so it can be
+// changed without losing concurrent update. Empty balance is created, when user has none yet.
+func (repo *PostgresRepository) LockBalanceByUserID(userID int) (*model.Balance, error) {
+	var balance = &model.Balance{
+		User: model.User{ID: &userID},
+	}
+
+	query := `
+		INSERT INTO balance(user_id, balance, spent_all_time)
+		VALUES ($1, 0, 0)
+		ON CONFLICT (user_id) DO UPDATE
+		    SET user_id=EXCLUDED.user_id
+		RETURNING id, balance, spent_all_time;
+	`
+
+	err := repo.db.QueryRow(query, userID).Scan(
+		&balance.ID,
+		&balance.Balance,
+		&balance.SpentAllTime,
+	)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return balance, nil
+}
+
 func (repo *PostgresRepository) GetOrdersByUserID(userID int) ([]model.Order, error) {
 	var orders []model.Order
 
@@ -191,6 +229,77 @@ func (repo *PostgresRepository) GetOrdersByUserID(userID int) ([]model.Order, er
 	return orders, nil
 }
 
+// GetOrdersPage returns up to filter.Limit orders of user after filter.After position.
+func (repo *PostgresRepository) GetOrdersPage(userID int, filter model.OrderFilter) ([]model.Order, error) {
+	var orders []model.Order
+	conditions := []string{"user_id=$1"}
+	args := []interface{}{userID}
+	addCondition := func(condition string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	if len(filter.Statuses) > 0 {
+		addCondition("status = ANY($%d)", pq.Array(filter.Statuses))
+	}
+	if filter.UploadedFrom != nil {
+		addCondition("upload_time >= $%d", *filter.UploadedFrom)
+	}
+	if filter.UploadedTo != nil {
+		addCondition("upload_time < $%d", *filter.UploadedTo)
+	}
+	direction, comparison := "ASC", ">"
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if filter.After != nil {
+		addCondition("(upload_time, id) "+comparison+" ($%d, $%d)", filter.After.UploadTime, filter.After.ID)
+	}
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT id, number, upload_time, accrual, status
+		FROM orders
+		WHERE %s
+		ORDER BY upload_time %s, id %s
+		LIMIT $%d;
+	`, strings.Join(conditions, " AND "), direction, direction, len(args))
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		order := model.Order{
+			User: &model.User{ID: &userID},
+		}
+		err = rows.Scan(
+			&order.ID,
+			&order.Number,
+			&order.UploadTime,
+			&order.Accrual,
+			&order.Status,
+		)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return orders, nil
+}
+
 func (repo *PostgresRepository) GetOrdersForStatusUpdate() ([]*model.Order, error) {
 	var orders []*model.Order
 
@@ -230,12 +339,6 @@ func (repo *PostgresRepository) GetOrdersForStatusUpdate() ([]*model.Order, erro
 }
 
 func (repo *PostgresRepository) GetOrderByNumber(orderNumber string) (*model.Order, error) {
-	var (
-		order  = model.Order{Number: orderNumber}
-		user   = model.User{}
-		userID *int
-	)
-
 	query := `
 		SELECT
 		    id,
@@ -247,6 +350,56 @@ func (repo *PostgresRepository) GetOrderByNumber(orderNumber string) (*model.Ord
 		FROM orders 
 		WHERE number=$1;
 	`
+	return repo.queryOrderByNumber(query, orderNumber)
+}
+
+// LockOrderByNumber returns order locked until end of transaction, so that concurrent status
+// updates of the order are applied one after another.
+func (repo *PostgresRepository) LockOrderByNumber(orderNumber string) (*model.Order, error) {
+	query := `
+		SELECT
+		    id,
+		    number,
+		    upload_time,
+		    status,
+		    accrual,
+		    user_id
+		FROM orders
+		WHERE number=$1
+		FOR UPDATE;
+	`
+	return repo.queryOrderByNumber(query, orderNumber)
+}
+
+func (repo *PostgresRepository) SaveRejectedStatusUpdate(update *model.RejectedStatusUpdate) error {
+	query := `
+		INSERT INTO rejected_status_updates(order_number, from_status, to_status, rejected_at)
+		VALUES ($1, $2, $3, $4);
+	`
+	_, err := repo.db.Exec(query, update.OrderNumber, update.FromStatus, update.ToStatus, update.RejectedAt)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) CountRejectedStatusUpdates() (uint64, error) {
+	var count uint64
+	err := repo.db.QueryRow(`SELECT COUNT(*) FROM rejected_status_updates;`).Scan(&count)
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (repo *PostgresRepository) queryOrderByNumber(query string, orderNumber string) (*model.Order, error) {
+	var (
+		order  = model.Order{Number: orderNumber}
+		user   = model.User{}
+		userID *int
+	)
 	err := repo.db.QueryRow(query, orderNumber).
 		Scan(
 			&order.ID,
@@ -265,21 +418,32 @@ func (repo *PostgresRepository) GetOrderByNumber(orderNumber string) (*model.Ord
 	return &order, nil
 }
 
-func (repo *PostgresRepository) GetUser(user *model.User) (*model.User, error) {
-	var userID *int
+func (repo *PostgresRepository) GetUserByLogin(login string) (*model.User, error) {
+	var (
+		user     = model.User{Login: login}
+		userID   *int
+		password *string
+		role     *string
+	)
 	query := `
-		SELECT id FROM users WHERE username=$1 and password=$2;
+		SELECT id, password, role FROM users WHERE username=$1;
 	`
 	err := repo.db.
-		QueryRow(query, user.Login, user.Password).
-		Scan(&userID)
+		QueryRow(query, login).
+		Scan(&userID, &password, &role)
 	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
 		log.Error(err)
-		return user, err
+		return &user, err
 	}
 	user.ID = userID
+	if password != nil {
+		user.Password = *password
+	}
+	if role != nil {
+		user.Role = *role
+	}
 
-	return user, nil
+	return &user, nil
 }
 
 func (repo *PostgresRepository) SaveWithdraw(withdraw *model.Withdraw) error {
@@ -288,15 +452,17 @@ func (repo *PostgresRepository) SaveWithdraw(withdraw *model.Withdraw) error {
 		                        order_num, 
 		                        sum, 
 		                        processed_at,
-		                        user_id
+		                        user_id,
+		                        idempotency_key_id
 		                   )
-		VALUES ($1, $2, $3, $4);
+		VALUES ($1, $2, $3, $4, $5);
 	`
 	_, err := repo.db.Exec(query,
 		withdraw.Order,
 		withdraw.Sum,
 		withdraw.ProcessedAt,
 		withdraw.User.ID,
+		withdraw.IdempotencyKeyID,
 	)
 	if err != nil {
 		log.Error(err)
@@ -305,6 +471,20 @@ func (repo *PostgresRepository) SaveWithdraw(withdraw *model.Withdraw) error {
 	return nil
 }
 
+// IsWithdrawnByIdempotencyKey reports whether request with the key has already made withdrawal.
+func (repo *PostgresRepository) IsWithdrawnByIdempotencyKey(keyID int) (bool, error) {
+	var withdrawn bool
+	query := `
+		SELECT EXISTS(SELECT 1 FROM withdrawals WHERE idempotency_key_id=$1);
+	`
+	err := repo.db.QueryRow(query, keyID).Scan(&withdrawn)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return withdrawn, nil
+}
+
 func (repo *PostgresRepository) SaveBalance(balance *model.Balance) error {
 	query := `
 		INSERT INTO balance(
@@ -336,9 +516,10 @@ func (repo *PostgresRepository) SaveOrder(order *model.Order) error {
 		                   number, 
 		                   status,
 		                   upload_time,
-		                   accrual
+		                   accrual,
+		                   merchant_id
 		                   )
-		VALUES ($1, $2, $3, $4, $5)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		ON CONFLICT (number) DO 
 		    UPDATE SET 	user_id=$1,
 		            	status=$3,
@@ -351,14 +532,189 @@ func (repo *PostgresRepository) SaveOrder(order *model.Order) error {
 		order.Status,
 		order.UploadTime,
 		order.Accrual,
+		order.MerchantID,
+	)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) MarkOrderPolled(orderNumber string) error {
+	query := `
+		UPDATE orders SET polled_at=now() WHERE number=$1 AND polled_at IS NULL;
+	`
+	_, err := repo.db.Exec(query, orderNumber)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteUnpolledOrder deletes order of user only if it is NEW and accrual system never reported it.
+func (repo *PostgresRepository) DeleteUnpolledOrder(orderID int, userID int) (bool, error) {
+	query := `
+		DELETE FROM orders
+		WHERE id=$1 AND user_id=$2 AND status='NEW' AND polled_at IS NULL;
+	`
+	result, err := repo.db.Exec(query, orderID, userID)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SaveOrderStatusTransition records transition in history of order and notifies OrderEventsChannel
+// listeners with id of the order owner. It has to be called in transaction: transitions of orders
+// of the same user are serialized until commit, so that their ids follow commit order and
+// GetOrderEvents can't pass over event committed after the later one.
+func (repo *PostgresRepository) SaveOrderStatusTransition(order *model.Order, transition *model.OrderStatusTransition) error {
+	_, err := repo.db.Exec(`SELECT pg_advisory_xact_lock($1, $2);`, orderEventsLockClass, *order.User.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	query := `
+		INSERT INTO order_status_history(
+		                                 order_id,
+		                                 user_id,
+		                                 order_number,
+		                                 from_status,
+		                                 to_status,
+		                                 accrual,
+		                                 balance_credited,
+		                                 changed_at
+		                                 )
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	`
+	_, err = repo.db.Exec(query,
+		order.ID,
+		order.User.ID,
+		order.Number,
+		transition.FromStatus,
+		transition.ToStatus,
+		transition.Accrual,
+		transition.BalanceCredited,
+		transition.ChangedAt,
 	)
 	if err != nil {
 		log.Error(err)
 		return err
 	}
+	_, err = repo.db.Exec(`SELECT pg_notify($1, $2);`, OrderEventsChannel, strconv.Itoa(*order.User.ID))
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	return nil
 }
 
+func (repo *PostgresRepository) GetOrderStatusHistory(orderID int) ([]model.OrderStatusTransition, error) {
+	var history []model.OrderStatusTransition
+	query := `
+		SELECT COALESCE(from_status, ''), to_status, accrual, changed_at
+		FROM order_status_history
+		WHERE order_id=$1
+		ORDER BY changed_at, id;
+	`
+	rows, err := repo.db.Query(query, orderID)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transition model.OrderStatusTransition
+		err = rows.Scan(
+			&transition.FromStatus,
+			&transition.ToStatus,
+			&transition.Accrual,
+			&transition.ChangedAt,
+		)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		history = append(history, transition)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return history, nil
+}
+
+// GetOrderEvents returns up to limit status transitions of orders of user with id greater than afterID.
+func (repo *PostgresRepository) GetOrderEvents(userID int, afterID int, limit int) ([]model.OrderEvent, error) {
+	var orderEvents []model.OrderEvent
+	query := `
+		SELECT id,
+		       COALESCE(order_number, ''),
+		       COALESCE(from_status, ''),
+		       to_status,
+		       accrual,
+		       COALESCE(balance_credited, false),
+		       changed_at
+		FROM order_status_history
+		WHERE user_id=$1 AND id>$2
+		ORDER BY id
+		LIMIT $3;
+	`
+	rows, err := repo.db.Query(query, userID, afterID, limit)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderEvent model.OrderEvent
+		err = rows.Scan(
+			&orderEvent.ID,
+			&orderEvent.Number,
+			&orderEvent.FromStatus,
+			&orderEvent.ToStatus,
+			&orderEvent.Accrual,
+			&orderEvent.BalanceCredited,
+			&orderEvent.ChangedAt,
+		)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		orderEvents = append(orderEvents, orderEvent)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return orderEvents, nil
+}
+
+func (repo *PostgresRepository) GetLastOrderEventID(userID int) (int, error) {
+	var lastID int
+	query := `
+		SELECT COALESCE(MAX(id), 0) FROM order_status_history WHERE user_id=$1;
+	`
+	err := repo.db.QueryRow(query, userID).Scan(&lastID)
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+	return lastID, nil
+}
+
 func (repo *PostgresRepository) SaveUser(user *model.User) error {
 	query := `
 		INSERT INTO users(
@@ -374,3 +730,1708 @@ func (repo *PostgresRepository) SaveUser(user *model.User) error {
 	}
 	return nil
 }
+
+func (repo *PostgresRepository) UpdateUserPassword(user *model.User) error {
+	query := `
+		UPDATE users SET password=$2 WHERE id=$1;
+	`
+	_, err := repo.db.Exec(query, user.ID, user.Password)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) SaveSession(session *model.Session) error {
+	query := `
+		INSERT INTO sessions(
+		                     user_id,
+		                     user_agent,
+		                     ip,
+		                     created_at,
+		                     last_seen_at
+		                     )
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;
+	`
+	err := repo.db.QueryRow(query,
+		session.User.ID,
+		session.UserAgent,
+		session.IP,
+		session.CreatedAt,
+		session.LastSeenAt,
+	).Scan(&session.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) GetSessionByID(sessionID int) (*model.Session, error) {
+	var (
+		session = model.Session{}
+		userID  *int
+	)
+	query := `
+		SELECT id,
+		       user_id,
+		       COALESCE(user_agent, ''),
+		       COALESCE(ip, ''),
+		       created_at,
+		       COALESCE(last_seen_at, created_at),
+		       revoked_at
+		FROM sessions
+		WHERE id=$1;
+	`
+	err := repo.db.QueryRow(query, sessionID).Scan(
+		&session.ID,
+		&userID,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.RevokedAt,
+	)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	session.User = &model.User{ID: userID}
+	return &session, nil
+}
+
+// GetActiveSessionsByUserID returns not revoked sessions of user, recently used first.
+func (repo *PostgresRepository) GetActiveSessionsByUserID(userID int) ([]model.Session, error) {
+	var sessions []model.Session
+	query := `
+		SELECT id,
+		       COALESCE(user_agent, ''),
+		       COALESCE(ip, ''),
+		       created_at,
+		       COALESCE(last_seen_at, created_at) AS last_seen
+		FROM sessions
+		WHERE user_id=$1 AND revoked_at IS NULL
+		ORDER BY last_seen DESC, id DESC;
+	`
+	rows, err := repo.db.Query(query, userID)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session := model.Session{User: &model.User{ID: &userID}}
+		err = rows.Scan(
+			&session.ID,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+		)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (repo *PostgresRepository) TouchSession(sessionID int) error {
+	query := `
+		UPDATE sessions SET last_seen_at=now() WHERE id=$1;
+	`
+	_, err := repo.db.Exec(query, sessionID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// RevokeUserSession revokes session only if it belongs to user and is still active.
+func (repo *PostgresRepository) RevokeUserSession(userID int, sessionID int) (bool, error) {
+	query := `
+		UPDATE sessions SET revoked_at=now()
+		WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL;
+	`
+	result, err := repo.db.Exec(query, sessionID, userID)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (repo *PostgresRepository) RevokeSession(sessionID int) error {
+	query := `
+		UPDATE sessions SET revoked_at=now() WHERE id=$1 AND revoked_at IS NULL;
+	`
+	_, err := repo.db.Exec(query, sessionID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) SaveRefreshToken(token *model.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens(
+		                           session_id,
+		                           token_hash,
+		                           expires_at
+		                           )
+		VALUES ($1, $2, $3)
+		RETURNING id;
+	`
+	err := repo.db.QueryRow(query,
+		token.Session.ID,
+		token.TokenHash,
+		token.ExpiresAt,
+	).Scan(&token.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) GetRefreshTokenByHash(tokenHash string) (*model.RefreshToken, error) {
+	var (
+		token     = model.RefreshToken{TokenHash: tokenHash}
+		sessionID *int
+	)
+	query := `
+		SELECT id, session_id, expires_at, used_at
+		FROM refresh_tokens
+		WHERE token_hash=$1;
+	`
+	err := repo.db.QueryRow(query, tokenHash).Scan(
+		&token.ID,
+		&sessionID,
+		&token.ExpiresAt,
+		&token.UsedAt,
+	)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	token.Session = &model.Session{ID: sessionID}
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed returns false when token was already used, concurrent update waits
+// for the first one to commit and then finds the token used.
+func (repo *PostgresRepository) MarkRefreshTokenUsed(tokenID int) (bool, error) {
+	query := `
+		UPDATE refresh_tokens SET used_at=now() WHERE id=$1 AND used_at IS NULL;
+	`
+	result, err := repo.db.Exec(query, tokenID)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (repo *PostgresRepository) SaveRevokedToken(jti string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens(jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING;
+	`
+	_, err := repo.db.Exec(query, jti, expiresAt)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) IsTokenRevoked(jti string) (bool, error) {
+	var revoked bool
+	query := `
+		SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti=$1);
+	`
+	err := repo.db.QueryRow(query, jti).Scan(&revoked)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return revoked, nil
+}
+
+func (repo *PostgresRepository) DeleteExpiredTokens() error {
+	query := `
+		DELETE FROM revoked_tokens WHERE expires_at < now();
+		DELETE FROM refresh_tokens WHERE expires_at < now();
+		DELETE FROM password_reset_tokens WHERE expires_at < now();
+		DELETE FROM login_challenges WHERE expires_at < now();
+		DELETE FROM oidc_states WHERE expires_at < now();
+	`
+	_, err := repo.db.Exec(query)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) GetLoginAttempt(key string) (*model.LoginAttempt, error) {
+	var (
+		attempt       = model.LoginAttempt{Key: key}
+		lastFailureAt *time.Time
+	)
+	query := `
+		SELECT failures, last_failure_at, locked_until
+		FROM login_attempts
+		WHERE key=$1;
+	`
+	err := repo.db.QueryRow(query, key).Scan(
+		&attempt.Failures,
+		&lastFailureAt,
+		&attempt.LockedUntil,
+	)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	if lastFailureAt != nil {
+		attempt.LastFailureAt = *lastFailureAt
+	}
+	return &attempt, nil
+}
+
+// CountLoginAttempt counts attempt for key unless the key is locked. Attempt is counted and checked
+// in one statement, concurrent attempts wait for each other, so they can't pass the check together.
+// Counted attempt locks key for next ones according to policy. It returns false for locked key.
+func (repo *PostgresRepository) CountLoginAttempt(key string, policy model.LoginAttemptPolicy) (bool, error) {
+	var failures int
+	query := fmt.Sprintf(`
+		INSERT INTO login_attempts(
+		                           key,
+		                           failures,
+		                           last_failure_at,
+		                           locked_until
+		                           )
+		VALUES ($1, 1, now(), %s)
+		ON CONFLICT (key) DO UPDATE
+		    SET failures = %s,
+		        last_failure_at = now(),
+		        locked_until = %s
+		    WHERE login_attempts.locked_until IS NULL OR login_attempts.locked_until <= now()
+		RETURNING failures;
+	`, loginLockUntil("1"), loginFailures, loginLockUntil(loginFailures))
+	err := repo.db.QueryRow(query,
+		key,
+		policy.WindowStart,
+		policy.DelayAfter,
+		policy.DelayBase.Seconds(),
+		policy.DelayMax.Seconds(),
+		policy.LockoutAfter,
+		policy.LockoutDuration.Seconds(),
+	).Scan(&failures)
+	if errors2.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return true, nil
+}
+
+// loginFailures is number of failures with attempt being counted, see CountLoginAttempt.
+const loginFailures = `CASE
+		            WHEN login_attempts.last_failure_at < $2 THEN 1
+		            ELSE login_attempts.failures + 1
+		        END`
+
+// loginLockUntil is end of lock set by attempt, which makes number of failures, see CountLoginAttempt.
+// Exponent is limited, so that delay is not computed out of float range.
+func loginLockUntil(failures string) string {
+	return fmt.Sprintf(`CASE
+		            WHEN $6 > 0 AND %[1]s >= $6 THEN now() + make_interval(secs => $7)
+		            WHEN $3 > 0 AND %[1]s >= $3
+		                THEN now() + make_interval(secs => LEAST($4 * power(2, LEAST(%[1]s - $3, 32)), $5))
+		        END`, failures)
+}
+
+// ForgiveLoginAttempt uncounts attempt, which turned out not to be a failure.
+func (repo *PostgresRepository) ForgiveLoginAttempt(key string) error {
+	query := `
+		UPDATE login_attempts SET failures=GREATEST(failures - 1, 0) WHERE key=$1;
+	`
+	_, err := repo.db.Exec(query, key)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) DeleteLoginAttempt(key string) error {
+	query := `
+		DELETE FROM login_attempts WHERE key=$1;
+	`
+	_, err := repo.db.Exec(query, key)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) GetUserByID(userID int) (*model.User, error) {
+	var (
+		user     = model.User{}
+		id       *int
+		login    *string
+		password *string
+		role     *string
+	)
+	query := `
+		SELECT id, username, password, role FROM users WHERE id=$1 AND deleted_at IS NULL;
+	`
+	err := repo.db.QueryRow(query, userID).Scan(&id, &login, &password, &role)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	user.ID = id
+	if login != nil {
+		user.Login = *login
+	}
+	if password != nil {
+		user.Password = *password
+	}
+	if role != nil {
+		user.Role = *role
+	}
+	return &user, nil
+}
+
+func (repo *PostgresRepository) UpdateUserRole(userID int, role string) error {
+	query := `
+		UPDATE users SET role=$2 WHERE id=$1;
+	`
+	_, err := repo.db.Exec(query, userID, role)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// AnonymizeUser frees login of deleted user and makes password unusable,
+// the row itself is kept for data retained after deletion to refer to.
+// Replacement login contains ':', which is not allowed in logins, so it can't
+// collide with login of any registered user.
+func (repo *PostgresRepository) AnonymizeUser(userID int) error {
+	query := `
+		UPDATE users
+		SET username='deleted:' || id,
+		    password='',
+		    role='user',
+		    deleted_at=now()
+		WHERE id=$1;
+	`
+	_, err := repo.db.Exec(query, userID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) DeleteUserData(userID int) error {
+	queries := []string{
+		`DELETE FROM order_status_history WHERE user_id=$1 OR order_id IN (SELECT id FROM orders WHERE user_id=$1);`,
+		`DELETE FROM orders WHERE user_id=$1;`,
+		`DELETE FROM balance WHERE user_id=$1;`,
+		`DELETE FROM withdrawals WHERE user_id=$1;`,
+	}
+	for _, query := range queries {
+		_, err := repo.db.Exec(query, userID)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) RevokeUserSessions(userID int) error {
+	query := `
+		UPDATE sessions SET revoked_at=now() WHERE user_id=$1 AND revoked_at IS NULL;
+	`
+	_, err := repo.db.Exec(query, userID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) SavePasswordResetToken(token *model.PasswordResetToken) error {
+	query := `
+		INSERT INTO password_reset_tokens(
+		                                  user_id,
+		                                  token_hash,
+		                                  created_at,
+		                                  expires_at
+		                                  )
+		VALUES ($1, $2, $3, $4)
+		RETURNING id;
+	`
+	err := repo.db.QueryRow(query,
+		token.User.ID,
+		token.TokenHash,
+		token.CreatedAt,
+		token.ExpiresAt,
+	).Scan(&token.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// UsePasswordResetToken marks token as used and returns it. Token, which is unknown, expired
+// or already used, is returned empty, so concurrent requests can't use the same token twice.
+func (repo *PostgresRepository) UsePasswordResetToken(tokenHash string) (*model.PasswordResetToken, error) {
+	var (
+		token  = model.PasswordResetToken{TokenHash: tokenHash}
+		userID *int
+	)
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at=now()
+		WHERE token_hash=$1 AND used_at IS NULL AND expires_at > now()
+		RETURNING id, user_id, created_at, expires_at, used_at;
+	`
+	err := repo.db.QueryRow(query, tokenHash).Scan(
+		&token.ID,
+		&userID,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.UsedAt,
+	)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	token.User = &model.User{ID: userID}
+	return &token, nil
+}
+
+// InvalidatePasswordResetTokens marks all outstanding reset tokens of user as used.
+func (repo *PostgresRepository) InvalidatePasswordResetTokens(userID int) error {
+	query := `
+		UPDATE password_reset_tokens SET used_at=now() WHERE user_id=$1 AND used_at IS NULL;
+	`
+	_, err := repo.db.Exec(query, userID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) GetTwoFactor(userID int) (*model.TwoFactor, error) {
+	var twoFactor = model.TwoFactor{User: &model.User{ID: &userID}}
+	query := `
+		SELECT secret, enabled, last_used_step
+		FROM two_factor
+		WHERE user_id=$1;
+	`
+	err := repo.db.QueryRow(query, userID).Scan(
+		&twoFactor.Secret,
+		&twoFactor.Enabled,
+		&twoFactor.LastUsedStep,
+	)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	return &twoFactor, nil
+}
+
+func (repo *PostgresRepository) SaveTwoFactor(twoFactor *model.TwoFactor) error {
+	query := `
+		INSERT INTO two_factor(
+		                       user_id,
+		                       secret,
+		                       enabled,
+		                       last_used_step
+		                       )
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		    SET secret=$2,
+		        enabled=$3,
+		        last_used_step=$4;
+	`
+	_, err := repo.db.Exec(query,
+		twoFactor.User.ID,
+		twoFactor.Secret,
+		twoFactor.Enabled,
+		twoFactor.LastUsedStep,
+	)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) DeleteTwoFactor(userID int) error {
+	queries := []string{
+		`DELETE FROM two_factor WHERE user_id=$1;`,
+		`DELETE FROM recovery_codes WHERE user_id=$1;`,
+	}
+	for _, query := range queries {
+		_, err := repo.db.Exec(query, userID)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// UseTOTPStep records step as the last used one. It returns false when code of
+// the same or a later step was already accepted.
+func (repo *PostgresRepository) UseTOTPStep(userID int, step int64) (bool, error) {
+	query := `
+		UPDATE two_factor SET last_used_step=$2 WHERE user_id=$1 AND last_used_step < $2;
+	`
+	result, err := repo.db.Exec(query, userID, step)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SaveRecoveryCodes replaces all recovery codes of the user.
+func (repo *PostgresRepository) SaveRecoveryCodes(userID int, codeHashes []string) error {
+	_, err := repo.db.Exec(`DELETE FROM recovery_codes WHERE user_id=$1;`, userID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	query := `
+		INSERT INTO recovery_codes(user_id, code_hash)
+		VALUES ($1, $2);
+	`
+	for _, codeHash := range codeHashes {
+		_, err = repo.db.Exec(query, userID, codeHash)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	query := `
+		UPDATE recovery_codes SET used_at=now() WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL;
+	`
+	result, err := repo.db.Exec(query, userID, codeHash)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (repo *PostgresRepository) SaveLoginChallenge(challenge *model.LoginChallenge) error {
+	query := `
+		INSERT INTO login_challenges(
+		                             user_id,
+		                             token_hash,
+		                             expires_at
+		                             )
+		VALUES ($1, $2, $3)
+		RETURNING id;
+	`
+	err := repo.db.QueryRow(query,
+		challenge.User.ID,
+		challenge.TokenHash,
+		challenge.ExpiresAt,
+	).Scan(&challenge.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) GetLoginChallengeByHash(tokenHash string) (*model.LoginChallenge, error) {
+	var (
+		challenge = model.LoginChallenge{TokenHash: tokenHash}
+		userID    *int
+	)
+	query := `
+		SELECT id, user_id, expires_at, attempts, used_at
+		FROM login_challenges
+		WHERE token_hash=$1;
+	`
+	err := repo.db.QueryRow(query, tokenHash).Scan(
+		&challenge.ID,
+		&userID,
+		&challenge.ExpiresAt,
+		&challenge.Attempts,
+		&challenge.UsedAt,
+	)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	challenge.User = &model.User{ID: userID}
+	return &challenge, nil
+}
+
+func (repo *PostgresRepository) IncrementLoginChallengeAttempts(challengeID int) error {
+	query := `
+		UPDATE login_challenges SET attempts=attempts + 1 WHERE id=$1;
+	`
+	_, err := repo.db.Exec(query, challengeID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// UseLoginChallenge marks challenge as passed, it returns false when it already was.
+func (repo *PostgresRepository) UseLoginChallenge(challengeID int) (bool, error) {
+	query := `
+		UPDATE login_challenges SET used_at=now() WHERE id=$1 AND used_at IS NULL;
+	`
+	result, err := repo.db.Exec(query, challengeID)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SearchUsersByLogin returns active users with login containing login, ordered by login.
+func (repo *PostgresRepository) SearchUsersByLogin(login string, limit int) ([]model.User, error) {
+	var users []model.User
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(login) + "%"
+	query := `
+		SELECT id, username, role
+		FROM users
+		WHERE username ILIKE $1 AND deleted_at IS NULL
+		ORDER BY username
+		LIMIT $2;
+	`
+	rows, err := repo.db.Query(query, pattern, limit)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user := model.User{}
+		err = rows.Scan(&user.ID, &user.Login, &user.Role)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return users, nil
+}
+
+func (repo *PostgresRepository) SaveAuditEntry(entry *model.AuditEntry) error {
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO admin_audit(
+		                        actor_id,
+		                        action,
+		                        target_user_id,
+		                        details,
+		                        created_at
+		                        )
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;
+	`
+	err = repo.db.QueryRow(query,
+		entry.ActorID,
+		entry.Action,
+		entry.TargetUserID,
+		string(details),
+		entry.CreatedAt,
+	).Scan(&entry.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// GetAuditEntries returns latest audit entries, only ones targeting user when targetUserID is set.
+func (repo *PostgresRepository) GetAuditEntries(targetUserID *int, limit int) ([]model.AuditEntry, error) {
+	var entries []model.AuditEntry
+	query := `
+		SELECT id, actor_id, action, target_user_id, details, created_at
+		FROM admin_audit
+		WHERE $1::BIGINT IS NULL OR target_user_id=$1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2;
+	`
+	rows, err := repo.db.Query(query, targetUserID, limit)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entry   model.AuditEntry
+			details *string
+		)
+		err = rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.TargetUserID,
+			&details,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		if details != nil {
+			if err := json.Unmarshal([]byte(*details), &entry.Details); err != nil {
+				log.Error("cannot parse audit entry details: ", err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (repo *PostgresRepository) GetUserByLoyaltyCard(loyaltyCard string) (*model.User, error) {
+	var (
+		user  = model.User{}
+		login *string
+		role  *string
+	)
+	query := `
+		SELECT id, username, role FROM users WHERE loyalty_card=$1 AND deleted_at IS NULL;
+	`
+	err := repo.db.QueryRow(query, loyaltyCard).Scan(&user.ID, &login, &role)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	if login != nil {
+		user.Login = *login
+	}
+	if role != nil {
+		user.Role = *role
+	}
+	return &user, nil
+}
+
+// UpdateUserLoyaltyCard binds card to user, empty loyaltyCard unbinds it.
+func (repo *PostgresRepository) UpdateUserLoyaltyCard(userID int, loyaltyCard string) error {
+	query := `
+		UPDATE users SET loyalty_card=NULLIF($2, '') WHERE id=$1;
+	`
+	_, err := repo.db.Exec(query, userID, loyaltyCard)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) SaveMerchant(merchant *model.Merchant) error {
+	query := `
+		INSERT INTO merchants(name, created_at)
+		VALUES ($1, $2)
+		RETURNING id;
+	`
+	err := repo.db.QueryRow(query, merchant.Name, merchant.CreatedAt).Scan(&merchant.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) GetMerchantByID(merchantID int) (*model.Merchant, error) {
+	var (
+		merchant      = model.Merchant{}
+		name          *string
+		numberPattern *string
+		numberLuhn    bool
+		createdAt     *time.Time
+	)
+	query := `
+		SELECT id, name, number_pattern, number_luhn, created_at FROM merchants WHERE id=$1;
+	`
+	err := repo.db.QueryRow(query, merchantID).Scan(&merchant.ID, &name, &numberPattern, &numberLuhn, &createdAt)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	if name != nil {
+		merchant.Name = *name
+	}
+	if numberPattern != nil {
+		merchant.NumberFormat = &model.NumberFormat{Pattern: *numberPattern, Luhn: numberLuhn}
+	}
+	if createdAt != nil {
+		merchant.CreatedAt = *createdAt
+	}
+	return &merchant, nil
+}
+
+// SetMerchantNumberFormat stores own number format of merchant, nil format removes it.
+func (repo *PostgresRepository) SetMerchantNumberFormat(merchantID int, format *model.NumberFormat) error {
+	var (
+		numberPattern *string
+		numberLuhn    bool
+	)
+	if format != nil {
+		numberPattern = &format.Pattern
+		numberLuhn = format.Luhn
+	}
+	query := `
+		UPDATE merchants SET number_pattern=$2, number_luhn=$3 WHERE id=$1;
+	`
+	_, err := repo.db.Exec(query, merchantID, numberPattern, numberLuhn)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) GetMerchantsWithNumberFormat() ([]model.Merchant, error) {
+	var merchants []model.Merchant
+	query := `
+		SELECT id, name, number_pattern, number_luhn, created_at
+		FROM merchants
+		WHERE number_pattern IS NOT NULL;
+	`
+	rows, err := repo.db.Query(query)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			merchant  = model.Merchant{NumberFormat: &model.NumberFormat{}}
+			name      *string
+			createdAt *time.Time
+		)
+		err = rows.Scan(&merchant.ID, &name, &merchant.NumberFormat.Pattern, &merchant.NumberFormat.Luhn, &createdAt)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		if name != nil {
+			merchant.Name = *name
+		}
+		if createdAt != nil {
+			merchant.CreatedAt = *createdAt
+		}
+		merchants = append(merchants, merchant)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return merchants, nil
+}
+
+func (repo *PostgresRepository) SaveAPIKey(key *model.APIKey) error {
+	query := `
+		INSERT INTO merchant_api_keys(
+		                              merchant_id,
+		                              prefix,
+		                              key_hash,
+		                              scopes,
+		                              created_at
+		                              )
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;
+	`
+	err := repo.db.QueryRow(query,
+		key.Merchant.ID,
+		key.Prefix,
+		key.KeyHash,
+		strings.Join(key.Scopes, ","),
+		key.CreatedAt,
+	).Scan(&key.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// GetAPIKeyByHash returns key together with its merchant.
+func (repo *PostgresRepository) GetAPIKeyByHash(keyHash string) (*model.APIKey, error) {
+	var (
+		key       = model.APIKey{KeyHash: keyHash, Merchant: &model.Merchant{}}
+		scopes    *string
+		name      *string
+		createdAt *time.Time
+	)
+	query := `
+		SELECT k.id, k.prefix, k.scopes, k.created_at, k.last_used_at, k.revoked_at,
+		       m.id, m.name
+		FROM merchant_api_keys k
+		JOIN merchants m ON m.id = k.merchant_id
+		WHERE k.key_hash=$1;
+	`
+	err := repo.db.QueryRow(query, keyHash).Scan(
+		&key.ID,
+		&key.Prefix,
+		&scopes,
+		&createdAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+		&key.Merchant.ID,
+		&name,
+	)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	if scopes != nil && *scopes != "" {
+		key.Scopes = strings.Split(*scopes, ",")
+	}
+	if name != nil {
+		key.Merchant.Name = *name
+	}
+	if createdAt != nil {
+		key.CreatedAt = *createdAt
+	}
+	return &key, nil
+}
+
+func (repo *PostgresRepository) GetAPIKeysByMerchantID(merchantID int) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	query := `
+		SELECT id, prefix, scopes, created_at, last_used_at, revoked_at
+		FROM merchant_api_keys
+		WHERE merchant_id=$1
+		ORDER BY id;
+	`
+	rows, err := repo.db.Query(query, merchantID)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key    = model.APIKey{Merchant: &model.Merchant{ID: &merchantID}}
+			scopes string
+		)
+		err = rows.Scan(
+			&key.ID,
+			&key.Prefix,
+			&scopes,
+			&key.CreatedAt,
+			&key.LastUsedAt,
+			&key.RevokedAt,
+		)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		if scopes != "" {
+			key.Scopes = strings.Split(scopes, ",")
+		}
+		keys = append(keys, key)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey returns false when merchant has no active key with keyID.
+func (repo *PostgresRepository) RevokeAPIKey(merchantID int, keyID int) (bool, error) {
+	query := `
+		UPDATE merchant_api_keys SET revoked_at=now()
+		WHERE id=$1 AND merchant_id=$2 AND revoked_at IS NULL;
+	`
+	result, err := repo.db.Exec(query, keyID, merchantID)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (repo *PostgresRepository) TouchAPIKey(keyID int) error {
+	query := `
+		UPDATE merchant_api_keys SET last_used_at=now() WHERE id=$1;
+	`
+	_, err := repo.db.Exec(query, keyID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) SaveOIDCState(state *model.OIDCState) error {
+	var userID *int
+	if state.User != nil {
+		userID = state.User.ID
+	}
+	query := `
+		INSERT INTO oidc_states(
+		                        state_hash,
+		                        binding_hash,
+		                        code_verifier,
+		                        nonce,
+		                        user_id,
+		                        expires_at
+		                        )
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id;
+	`
+	err := repo.db.QueryRow(query,
+		state.StateHash,
+		state.BindingHash,
+		state.CodeVerifier,
+		state.Nonce,
+		userID,
+		state.ExpiresAt,
+	).Scan(&state.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// TakeOIDCState deletes state and returns it, so every state can be used only once.
+func (repo *PostgresRepository) TakeOIDCState(stateHash string) (*model.OIDCState, error) {
+	var (
+		state  = model.OIDCState{StateHash: stateHash}
+		userID *int
+	)
+	query := `
+		DELETE FROM oidc_states
+		WHERE state_hash=$1
+		RETURNING id, COALESCE(binding_hash, ''), code_verifier, nonce, user_id, expires_at;
+	`
+	err := repo.db.QueryRow(query, stateHash).Scan(
+		&state.ID,
+		&state.BindingHash,
+		&state.CodeVerifier,
+		&state.Nonce,
+		&userID,
+		&state.ExpiresAt,
+	)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	if userID != nil {
+		state.User = &model.User{ID: userID}
+	}
+	return &state, nil
+}
+
+func (repo *PostgresRepository) GetOIDCIdentity(issuer string, subject string) (*model.OIDCIdentity, error) {
+	var (
+		identity = model.OIDCIdentity{Issuer: issuer, Subject: subject}
+		userID   *int
+	)
+	query := `
+		SELECT id, user_id, created_at
+		FROM oidc_identities
+		WHERE issuer=$1 AND subject=$2;
+	`
+	err := repo.db.QueryRow(query, issuer, subject).Scan(
+		&identity.ID,
+		&userID,
+		&identity.CreatedAt,
+	)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	identity.User = &model.User{ID: userID}
+	return &identity, nil
+}
+
+func (repo *PostgresRepository) SaveOIDCIdentity(identity *model.OIDCIdentity) error {
+	query := `
+		INSERT INTO oidc_identities(
+		                            user_id,
+		                            issuer,
+		                            subject,
+		                            created_at
+		                            )
+		VALUES ($1, $2, $3, $4)
+		RETURNING id;
+	`
+	err := repo.db.QueryRow(query,
+		identity.User.ID,
+		identity.Issuer,
+		identity.Subject,
+		identity.CreatedAt,
+	).Scan(&identity.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) DeleteOIDCIdentities(userID int) error {
+	query := `
+		DELETE FROM oidc_identities WHERE user_id=$1;
+	`
+	_, err := repo.db.Exec(query, userID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// CreateIdempotencyKey reserves key for request of user. Expired key is taken over, so it returns
+// false only if the key is already used within its window. Reused expired key gets new id, as it
+// belongs to another request, while key taken over after lease keeps id of the same request.
+func (repo *PostgresRepository) CreateIdempotencyKey(key *model.IdempotencyKey) (bool, error) {
+	query := `
+		INSERT INTO idempotency_keys(
+		                             user_id,
+		                             idempotency_key,
+		                             fingerprint,
+		                             created_at,
+		                             expires_at,
+		                             locked_until,
+		                             lease_token
+		                             )
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, idempotency_key) DO
+		    UPDATE SET id=CASE WHEN idempotency_keys.expires_at < EXCLUDED.created_at
+		                       THEN nextval(pg_get_serial_sequence('idempotency_keys', 'id'))
+		                       ELSE idempotency_keys.id END,
+		               fingerprint=EXCLUDED.fingerprint,
+		               status_code=NULL,
+		               content_type=NULL,
+		               response_body=NULL,
+		               created_at=EXCLUDED.created_at,
+		               expires_at=EXCLUDED.expires_at,
+		               completed_at=NULL,
+		               locked_until=EXCLUDED.locked_until,
+		               lease_token=EXCLUDED.lease_token
+		    WHERE idempotency_keys.expires_at < EXCLUDED.created_at
+		       OR (idempotency_keys.completed_at IS NULL
+		           AND idempotency_keys.fingerprint=EXCLUDED.fingerprint
+		           AND COALESCE(idempotency_keys.locked_until, idempotency_keys.created_at) < EXCLUDED.created_at)
+		RETURNING id;
+	`
+	err := repo.db.QueryRow(query,
+		key.UserID,
+		key.Key,
+		key.Fingerprint,
+		key.CreatedAt,
+		key.ExpiresAt,
+		key.LockedUntil,
+		key.LeaseToken,
+	).Scan(&key.ID)
+	if errors2.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (repo *PostgresRepository) GetIdempotencyKey(userID int, key string) (*model.IdempotencyKey, error) {
+	var (
+		record      = model.IdempotencyKey{UserID: userID, Key: key}
+		statusCode  *int
+		contentType *string
+	)
+	query := `
+		SELECT id, fingerprint, status_code, content_type, response_body, created_at, expires_at, completed_at, locked_until
+		FROM idempotency_keys
+		WHERE user_id=$1 AND idempotency_key=$2;
+	`
+	err := repo.db.QueryRow(query, userID, key).Scan(
+		&record.ID,
+		&record.Fingerprint,
+		&statusCode,
+		&contentType,
+		&record.Body,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+		&record.CompletedAt,
+		&record.LockedUntil,
+	)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	if statusCode != nil {
+		record.StatusCode = *statusCode
+	}
+	if contentType != nil {
+		record.ContentType = *contentType
+	}
+	return &record, nil
+}
+
+// RenewIdempotencyKey extends lease of request, it returns false when the lease was taken over.
+func (repo *PostgresRepository) RenewIdempotencyKey(key *model.IdempotencyKey) (bool, error) {
+	query := `
+		UPDATE idempotency_keys SET locked_until=$3
+		WHERE id=$1 AND lease_token=$2 AND completed_at IS NULL;
+	`
+	result, err := repo.db.Exec(query, key.ID, key.LeaseToken, key.LockedUntil)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// CompleteIdempotencyKey stores response only if the request still holds the lease.
+func (repo *PostgresRepository) CompleteIdempotencyKey(key *model.IdempotencyKey) (bool, error) {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code=$3, content_type=$4, response_body=$5, completed_at=$6
+		WHERE id=$1 AND lease_token=$2 AND completed_at IS NULL;
+	`
+	result, err := repo.db.Exec(query,
+		key.ID,
+		key.LeaseToken,
+		key.StatusCode,
+		key.ContentType,
+		key.Body,
+		key.CompletedAt,
+	)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// DeleteIdempotencyKey deletes key only if the request still holds the lease.
+func (repo *PostgresRepository) DeleteIdempotencyKey(key *model.IdempotencyKey) (bool, error) {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE id=$1 AND lease_token=$2 AND completed_at IS NULL;
+	`
+	result, err := repo.db.Exec(query, key.ID, key.LeaseToken)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (repo *PostgresRepository) DeleteExpiredIdempotencyKeys() error {
+	query := `
+		DELETE FROM idempotency_keys WHERE expires_at < now();
+	`
+	_, err := repo.db.Exec(query)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) SaveWebhook(webhook *model.Webhook) error {
+	query := `
+		INSERT INTO webhooks(
+		                     user_id,
+		                     url,
+		                     secret,
+		                     event_types,
+		                     created_at
+		                     )
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;
+	`
+	err := repo.db.QueryRow(query,
+		webhook.UserID,
+		webhook.URL,
+		webhook.Secret,
+		strings.Join(webhook.EventTypes, ","),
+		webhook.CreatedAt,
+	).Scan(&webhook.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// GetWebhookByID returns webhook with its secret.
+func (repo *PostgresRepository) GetWebhookByID(webhookID int) (*model.Webhook, error) {
+	var (
+		webhook    = model.Webhook{}
+		eventTypes string
+	)
+	query := `
+		SELECT id, user_id, url, secret, event_types, created_at
+		FROM webhooks
+		WHERE id=$1;
+	`
+	err := repo.db.QueryRow(query, webhookID).Scan(
+		&webhook.ID,
+		&webhook.UserID,
+		&webhook.URL,
+		&webhook.Secret,
+		&eventTypes,
+		&webhook.CreatedAt,
+	)
+	if err != nil && !errors2.Is(err, sql.ErrNoRows) {
+		log.Error(err)
+		return nil, err
+	}
+	if eventTypes != "" {
+		webhook.EventTypes = strings.Split(eventTypes, ",")
+	}
+	return &webhook, nil
+}
+
+// GetWebhooksByUserID returns webhooks of user without secrets.
+func (repo *PostgresRepository) GetWebhooksByUserID(userID int) ([]model.Webhook, error) {
+	query := `
+		SELECT id, url, event_types, created_at
+		FROM webhooks
+		WHERE user_id=$1
+		ORDER BY id;
+	`
+	return repo.queryWebhooks(query, userID)
+}
+
+// GetWebhooksByEvent returns webhooks of user subscribed to event type, without secrets.
+func (repo *PostgresRepository) GetWebhooksByEvent(userID int, eventType string) ([]model.Webhook, error) {
+	query := `
+		SELECT id, url, event_types, created_at
+		FROM webhooks
+		WHERE user_id=$1 AND $2 = ANY(string_to_array(event_types, ','))
+		ORDER BY id;
+	`
+	return repo.queryWebhooks(query, userID, eventType)
+}
+
+func (repo *PostgresRepository) queryWebhooks(query string, userID int, args ...interface{}) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	rows, err := repo.db.Query(query, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			webhook    = model.Webhook{UserID: userID}
+			eventTypes string
+		)
+		err = rows.Scan(
+			&webhook.ID,
+			&webhook.URL,
+			&eventTypes,
+			&webhook.CreatedAt,
+		)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		if eventTypes != "" {
+			webhook.EventTypes = strings.Split(eventTypes, ",")
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes webhook of user together with its delivery log. It returns false
+// when user has no webhook with webhookID.
+func (repo *PostgresRepository) DeleteWebhook(userID int, webhookID int) (bool, error) {
+	result, err := repo.db.Exec(`DELETE FROM webhooks WHERE id=$1 AND user_id=$2;`, webhookID, userID)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	_, err = repo.db.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id=$1;`, webhookID)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (repo *PostgresRepository) DeleteWebhooksByUserID(userID int) error {
+	queries := []string{
+		`DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE user_id=$1);`,
+		`DELETE FROM webhooks WHERE user_id=$1;`,
+	}
+	for _, query := range queries {
+		_, err := repo.db.Exec(query, userID)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) SaveWebhookDelivery(delivery *model.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries(
+		                               webhook_id,
+		                               event_type,
+		                               payload,
+		                               status,
+		                               attempts,
+		                               next_attempt_at,
+		                               created_at
+		                               )
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id;
+	`
+	err := repo.db.QueryRow(query,
+		delivery.WebhookID,
+		delivery.EventType,
+		string(delivery.Payload),
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.CreatedAt,
+	).Scan(&delivery.ID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// UpdateWebhookDelivery saves outcome of delivery attempt.
+func (repo *PostgresRepository) UpdateWebhookDelivery(delivery *model.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status=$2,
+		    attempts=$3,
+		    next_attempt_at=$4,
+		    last_attempt_at=$5,
+		    response_status=$6,
+		    last_error=$7
+		WHERE id=$1;
+	`
+	_, err := repo.db.Exec(query,
+		delivery.ID,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastAttemptAt,
+		delivery.ResponseStatus,
+		delivery.LastError,
+	)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries due for attempt and postpones them
+// until leaseUntil, so that other replicas do not pick them up meanwhile.
+func (repo *PostgresRepository) ClaimWebhookDeliveries(limit int, leaseUntil time.Time) ([]model.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries SET next_attempt_at=$2
+		WHERE id IN (
+		    SELECT id FROM webhook_deliveries
+		    WHERE status='pending' AND next_attempt_at <= now()
+		    ORDER BY next_attempt_at
+		    LIMIT $1
+		    FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, webhook_id, event_type, payload, status, attempts,
+		          next_attempt_at, last_attempt_at, response_status, COALESCE(last_error, ''), created_at;
+	`
+	return repo.queryWebhookDeliveries(query, limit, leaseUntil)
+}
+
+// GetWebhookDeliveries returns up to limit latest deliveries of webhook, the newest first.
+func (repo *PostgresRepository) GetWebhookDeliveries(webhookID int, limit int) ([]model.WebhookDelivery, error) {
+	query := `
+		SELECT id, webhook_id, event_type, payload, status, attempts,
+		       next_attempt_at, last_attempt_at, response_status, COALESCE(last_error, ''), created_at
+		FROM webhook_deliveries
+		WHERE webhook_id=$1
+		ORDER BY id DESC
+		LIMIT $2;
+	`
+	return repo.queryWebhookDeliveries(query, webhookID, limit)
+}
+
+func (repo *PostgresRepository) queryWebhookDeliveries(query string, args ...interface{}) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			delivery model.WebhookDelivery
+			payload  string
+		)
+		err = rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.EventType,
+			&payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastAttemptAt,
+			&delivery.ResponseStatus,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+		)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		delivery.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, delivery)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ReplayWebhookDelivery schedules failed delivery for immediate attempt with fresh attempt budget.
+func (repo *PostgresRepository) ReplayWebhookDelivery(webhookID int, deliveryID int) (bool, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET status='pending', attempts=0, next_attempt_at=now()
+		WHERE id=$1 AND webhook_id=$2 AND status='failed';
+	`
+	result, err := repo.db.Exec(query, deliveryID, webhookID)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -402,13 +402,13 @@ func TestPostgresRepository_GetOrdersForStatusUpdate(t *testing.T) {
 	}
 }
 
-func TestPostgresRepository_GetUser(t *testing.T) {
+func TestPostgresRepository_GetUserByLogin(t *testing.T) {
 	type fields struct {
 		Conn  *sqlx.DB
 		DBURI string
 	}
 	type args struct {
-		user *model.User
+		login string
 	}
 	tests := []struct {
 		name    string
@@ -425,11 +425,11 @@ func TestPostgresRepository_GetUser(t *testing.T) {
 				Conn:  tt.fields.Conn,
 				DBURI: tt.fields.DBURI,
 			}
-			got, err := repo.GetUser(tt.args.user)
-			if !tt.wantErr(t, err, fmt.Sprintf("GetUser(%v)", tt.args.user)) {
+			got, err := repo.GetUserByLogin(tt.args.login)
+			if !tt.wantErr(t, err, fmt.Sprintf("GetUserByLogin(%v)", tt.args.login)) {
 				return
 			}
-			assert.Equalf(t, tt.want, got, "GetUser(%v)", tt.args.user)
+			assert.Equalf(t, tt.want, got, "GetUserByLogin(%v)", tt.args.login)
 		})
 	}
 }
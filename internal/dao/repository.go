@@ -3,19 +3,103 @@ package dao
 import (
 	"context"
 	"github.com/yurchenkosv/gofermart/internal/model"
+	"time"
 )
 
 type Repository interface {
-	GetUser(user *model.User) (*model.User, error)
+	GetUserByLogin(login string) (*model.User, error)
 	GetOrderByNumber(orderNumber string) (*model.Order, error)
+	LockOrderByNumber(orderNumber string) (*model.Order, error)
+	SaveRejectedStatusUpdate(update *model.RejectedStatusUpdate) error
+	CountRejectedStatusUpdates() (uint64, error)
 	GetOrdersForStatusUpdate() ([]*model.Order, error)
 	GetOrdersByUserID(userID int) ([]model.Order, error)
+	GetOrdersPage(userID int, filter model.OrderFilter) ([]model.Order, error)
 	GetBalanceByUserID(userID int) (*model.Balance, error)
+	LockBalanceByUserID(userID int) (*model.Balance, error)
 	GetWithdrawalsByUserID(userID int) ([]*model.Withdraw, error)
 	SaveWithdraw(withdraw *model.Withdraw) error
+	IsWithdrawnByIdempotencyKey(keyID int) (bool, error)
 	SaveBalance(balance *model.Balance) error
 	SaveOrder(order *model.Order) error
+	MarkOrderPolled(orderNumber string) error
+	DeleteUnpolledOrder(orderID int, userID int) (bool, error)
+	SaveOrderStatusTransition(order *model.Order, transition *model.OrderStatusTransition) error
+	GetOrderStatusHistory(orderID int) ([]model.OrderStatusTransition, error)
+	GetOrderEvents(userID int, afterID int, limit int) ([]model.OrderEvent, error)
+	GetLastOrderEventID(userID int) (int, error)
 	SaveUser(user *model.User) error
+	UpdateUserPassword(user *model.User) error
+	GetUserByID(userID int) (*model.User, error)
+	UpdateUserRole(userID int, role string) error
+	SearchUsersByLogin(login string, limit int) ([]model.User, error)
+	SaveAuditEntry(entry *model.AuditEntry) error
+	GetAuditEntries(targetUserID *int, limit int) ([]model.AuditEntry, error)
+	GetUserByLoyaltyCard(loyaltyCard string) (*model.User, error)
+	UpdateUserLoyaltyCard(userID int, loyaltyCard string) error
+	SaveMerchant(merchant *model.Merchant) error
+	GetMerchantByID(merchantID int) (*model.Merchant, error)
+	SetMerchantNumberFormat(merchantID int, format *model.NumberFormat) error
+	GetMerchantsWithNumberFormat() ([]model.Merchant, error)
+	SaveAPIKey(key *model.APIKey) error
+	GetAPIKeyByHash(keyHash string) (*model.APIKey, error)
+	GetAPIKeysByMerchantID(merchantID int) ([]model.APIKey, error)
+	RevokeAPIKey(merchantID int, keyID int) (bool, error)
+	TouchAPIKey(keyID int) error
+	CreateIdempotencyKey(key *model.IdempotencyKey) (bool, error)
+	GetIdempotencyKey(userID int, key string) (*model.IdempotencyKey, error)
+	RenewIdempotencyKey(key *model.IdempotencyKey) (bool, error)
+	CompleteIdempotencyKey(key *model.IdempotencyKey) (bool, error)
+	DeleteIdempotencyKey(key *model.IdempotencyKey) (bool, error)
+	DeleteExpiredIdempotencyKeys() error
+	SaveWebhook(webhook *model.Webhook) error
+	GetWebhookByID(webhookID int) (*model.Webhook, error)
+	GetWebhooksByUserID(userID int) ([]model.Webhook, error)
+	GetWebhooksByEvent(userID int, eventType string) ([]model.Webhook, error)
+	DeleteWebhook(userID int, webhookID int) (bool, error)
+	DeleteWebhooksByUserID(userID int) error
+	SaveWebhookDelivery(delivery *model.WebhookDelivery) error
+	UpdateWebhookDelivery(delivery *model.WebhookDelivery) error
+	ClaimWebhookDeliveries(limit int, leaseUntil time.Time) ([]model.WebhookDelivery, error)
+	GetWebhookDeliveries(webhookID int, limit int) ([]model.WebhookDelivery, error)
+	ReplayWebhookDelivery(webhookID int, deliveryID int) (bool, error)
+	AnonymizeUser(userID int) error
+	DeleteUserData(userID int) error
+	RevokeUserSessions(userID int) error
+	SaveSession(session *model.Session) error
+	GetSessionByID(sessionID int) (*model.Session, error)
+	GetActiveSessionsByUserID(userID int) ([]model.Session, error)
+	TouchSession(sessionID int) error
+	RevokeSession(sessionID int) error
+	RevokeUserSession(userID int, sessionID int) (bool, error)
+	SaveOIDCState(state *model.OIDCState) error
+	TakeOIDCState(stateHash string) (*model.OIDCState, error)
+	GetOIDCIdentity(issuer string, subject string) (*model.OIDCIdentity, error)
+	SaveOIDCIdentity(identity *model.OIDCIdentity) error
+	DeleteOIDCIdentities(userID int) error
+	SaveRefreshToken(token *model.RefreshToken) error
+	GetRefreshTokenByHash(tokenHash string) (*model.RefreshToken, error)
+	MarkRefreshTokenUsed(tokenID int) (bool, error)
+	SaveRevokedToken(jti string, expiresAt time.Time) error
+	IsTokenRevoked(jti string) (bool, error)
+	DeleteExpiredTokens() error
+	SavePasswordResetToken(token *model.PasswordResetToken) error
+	UsePasswordResetToken(tokenHash string) (*model.PasswordResetToken, error)
+	InvalidatePasswordResetTokens(userID int) error
+	GetTwoFactor(userID int) (*model.TwoFactor, error)
+	SaveTwoFactor(twoFactor *model.TwoFactor) error
+	DeleteTwoFactor(userID int) error
+	UseTOTPStep(userID int, step int64) (bool, error)
+	SaveRecoveryCodes(userID int, codeHashes []string) error
+	UseRecoveryCode(userID int, codeHash string) (bool, error)
+	SaveLoginChallenge(challenge *model.LoginChallenge) error
+	GetLoginChallengeByHash(tokenHash string) (*model.LoginChallenge, error)
+	IncrementLoginChallengeAttempts(challengeID int) error
+	UseLoginChallenge(challengeID int) (bool, error)
+	GetLoginAttempt(key string) (*model.LoginAttempt, error)
+	CountLoginAttempt(key string, policy model.LoginAttemptPolicy) (bool, error)
+	ForgiveLoginAttempt(key string) error
+	DeleteLoginAttempt(key string) error
 	Atomic(ctx context.Context, fn func(r Repository) error) (err error)
 	Shutdown()
 }
@@ -1,5 +1,13 @@
 package dto
 
+// Statuses of order in accrual system.
+const (
+	AccrualStatusRegistered = "REGISTERED"
+	AccrualStatusInvalid    = "INVALID"
+	AccrualStatusProcessing = "PROCESSING"
+	AccrualStatusProcessed  = "PROCESSED"
+)
+
 type AccrualStatus struct {
 	OrderNum string   `json:"order"`
 	Status   string   `json:"status"`
@@ -0,0 +1,40 @@
+package errors
+
+import (
+	"fmt"
+	"time"
+)
+
+type AccrualRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (err *AccrualRateLimitError) Error() string {
+	return fmt.Sprintf("accrual system limits requests, retry after %s", err.RetryAfter)
+}
+
+type AccrualOrderNotRegisteredError struct {
+	OrderNumber string
+}
+
+func (err *AccrualOrderNotRegisteredError) Error() string {
+	return fmt.Sprintf("order %s is not registered in accrual system", err.OrderNumber)
+}
+
+// AccrualServerError is server error or any other unexpected response of accrual system.
+type AccrualServerError struct {
+	StatusCode int
+}
+
+func (err *AccrualServerError) Error() string {
+	return fmt.Sprintf("accrual system responded with status %d", err.StatusCode)
+}
+
+type AccrualUnknownStatusError struct {
+	OrderNumber string
+	Status      string
+}
+
+func (err *AccrualUnknownStatusError) Error() string {
+	return fmt.Sprintf("accrual system returned unknown status %s of order %s", err.Status, err.OrderNumber)
+}
@@ -0,0 +1,19 @@
+package errors
+
+import "fmt"
+
+type IdempotencyKeyMismatchError struct {
+	Key string
+}
+
+func (err *IdempotencyKeyMismatchError) Error() string {
+	return fmt.Sprintf("idempotency key %s was already used for different request", err.Key)
+}
+
+type IdempotencyKeyInProgressError struct {
+	Key string
+}
+
+func (err *IdempotencyKeyInProgressError) Error() string {
+	return fmt.Sprintf("request with idempotency key %s is still in progress", err.Key)
+}
@@ -0,0 +1,33 @@
+package errors
+
+import "fmt"
+
+type MerchantNotFoundError struct {
+	MerchantID int
+}
+
+func (err *MerchantNotFoundError) Error() string {
+	return fmt.Sprintf("merchant %d not found", err.MerchantID)
+}
+
+type APIKeyNotFoundError struct {
+	KeyID int
+}
+
+func (err *APIKeyNotFoundError) Error() string {
+	return fmt.Sprintf("active api key %d not found", err.KeyID)
+}
+
+type InvalidAPIKeyError struct{}
+
+func (err *InvalidAPIKeyError) Error() string {
+	return "invalid or revoked api key"
+}
+
+type LoyaltyCardTakenError struct {
+	LoyaltyCard string
+}
+
+func (err *LoyaltyCardTakenError) Error() string {
+	return fmt.Sprintf("loyalty card %s is bound to another user", err.LoyaltyCard)
+}
@@ -0,0 +1,7 @@
+package errors
+
+type NotifierNotConfiguredError struct{}
+
+func (err *NotifierNotConfiguredError) Error() string {
+	return "notifications are not delivered, neither SMTP server nor notification file or log is configured"
+}
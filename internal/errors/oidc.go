@@ -0,0 +1,21 @@
+package errors
+
+import "fmt"
+
+// OIDCAuthenticationError is returned when provider rejected authorization code
+// or returned ID token which cannot be trusted.
+type OIDCAuthenticationError struct {
+	Reason string
+}
+
+type OIDCIdentityLinkedError struct {
+	Subject string
+}
+
+func (err *OIDCAuthenticationError) Error() string {
+	return fmt.Sprintf("openid connect authentication failed: %s", err.Reason)
+}
+
+func (err *OIDCIdentityLinkedError) Error() string {
+	return fmt.Sprintf("openid connect identity %s is linked to another user", err.Subject)
+}
@@ -22,6 +22,16 @@ type NoOrdersError struct {
 type OrderNoChangeError struct {
 }
 
+type OrderNotDeletableError struct {
+	OrderNumber string
+}
+
+type OrderStatusTransitionError struct {
+	OrderNumber string
+	From        string
+	To          string
+}
+
 func (err *OrderAlreadyAcceptedCurrentUserError) Error() string {
 	return fmt.Sprintf("order with number %s already accepted from user %d", err.OrderNumber, err.UserID)
 }
@@ -41,3 +51,11 @@ func (err *NoOrdersError) Error() string {
 func (o *OrderNoChangeError) Error() string {
 	return "order no change"
 }
+
+func (err *OrderStatusTransitionError) Error() string {
+	return fmt.Sprintf("order %s cannot move from status %s to %s", err.OrderNumber, err.From, err.To)
+}
+
+func (err *OrderNotDeletableError) Error() string {
+	return fmt.Sprintf("order %s is already being processed and cannot be deleted", err.OrderNumber)
+}
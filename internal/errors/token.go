@@ -0,0 +1,17 @@
+package errors
+
+import "fmt"
+
+type InvalidTokenError struct{}
+
+func (err *InvalidTokenError) Error() string {
+	return "invalid or expired token"
+}
+
+type SessionNotFoundError struct {
+	SessionID int
+}
+
+func (err *SessionNotFoundError) Error() string {
+	return fmt.Sprintf("session %d not found", err.SessionID)
+}
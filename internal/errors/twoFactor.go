@@ -0,0 +1,25 @@
+package errors
+
+type TwoFactorRequiredError struct{}
+
+func (err *TwoFactorRequiredError) Error() string {
+	return "two-factor authentication code required"
+}
+
+type InvalidTwoFactorCodeError struct{}
+
+func (err *InvalidTwoFactorCodeError) Error() string {
+	return "invalid two-factor authentication code"
+}
+
+type TwoFactorAlreadyEnabledError struct{}
+
+func (err *TwoFactorAlreadyEnabledError) Error() string {
+	return "two-factor authentication is already enabled"
+}
+
+type TwoFactorNotEnrolledError struct{}
+
+func (err *TwoFactorNotEnrolledError) Error() string {
+	return "two-factor authentication is not enrolled"
+}
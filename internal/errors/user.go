@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type UserAlreadyExistsError struct {
 	User string
@@ -15,3 +18,19 @@ type InvalidUserError struct{}
 func (err *InvalidUserError) Error() string {
 	return "invalid username or password"
 }
+
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (err *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %s", err.RetryAfter)
+}
+
+type UserNotFoundError struct {
+	User string
+}
+
+func (err *UserNotFoundError) Error() string {
+	return fmt.Sprintf("user %s not found", err.User)
+}
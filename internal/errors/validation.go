@@ -0,0 +1,31 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (err *ValidationError) Error() string {
+	messages := make([]string, 0, len(err.Fields))
+	for _, field := range err.Fields {
+		messages = append(messages, fmt.Sprintf("%s: %s", field.Field, field.Message))
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (err *ValidationError) Add(field string, message string) {
+	err.Fields = append(err.Fields, FieldError{Field: field, Message: message})
+}
+
+func (err *ValidationError) HasErrors() bool {
+	return len(err.Fields) > 0
+}
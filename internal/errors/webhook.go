@@ -0,0 +1,28 @@
+package errors
+
+import "fmt"
+
+type WebhookNotFoundError struct {
+	WebhookID int
+}
+
+func (err *WebhookNotFoundError) Error() string {
+	return fmt.Sprintf("webhook %d not found", err.WebhookID)
+}
+
+type WebhookDeliveryNotReplayableError struct {
+	DeliveryID int
+}
+
+func (err *WebhookDeliveryNotReplayableError) Error() string {
+	return fmt.Sprintf("webhook delivery %d not found or has not failed", err.DeliveryID)
+}
+
+// WebhookDestinationError is returned for webhook host, which does not resolve to public address.
+type WebhookDestinationError struct {
+	Host string
+}
+
+func (err *WebhookDestinationError) Error() string {
+	return fmt.Sprintf("webhook host %s does not resolve to public address", err.Host)
+}
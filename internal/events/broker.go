@@ -0,0 +1,61 @@
+package events
+
+import "sync"
+
+// Broker wakes up subscribers of user, when there are new events of the user. Events themselves
+// are read from the database, so a missed wake up only delays them until the next one.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[int]map[chan struct{}]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[int]map[chan struct{}]struct{})}
+}
+
+// Subscribe returns channel signalled on new events of user and function to unsubscribe.
+func (b *Broker) Subscribe(userID int) (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan struct{}]struct{})
+	}
+	b.subscribers[userID][wake] = struct{}{}
+	return wake, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[userID], wake)
+		if len(b.subscribers[userID]) == 0 {
+			delete(b.subscribers, userID)
+		}
+	}
+}
+
+// Publish wakes up subscribers of user.
+func (b *Broker) Publish(userID int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for wake := range b.subscribers[userID] {
+		signal(wake)
+	}
+}
+
+// PublishAll wakes up all subscribers, e.g. after notifications could have been lost.
+func (b *Broker) PublishAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, subscribers := range b.subscribers {
+		for wake := range subscribers {
+			signal(wake)
+		}
+	}
+}
+
+// signal does not block, pending signal already wakes up subscriber.
+func signal(wake chan struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
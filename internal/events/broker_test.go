@@ -0,0 +1,48 @@
+package events
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func woken(wake <-chan struct{}) bool {
+	select {
+	case <-wake:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestBroker_Publish(t *testing.T) {
+	broker := NewBroker()
+	first, unsubscribeFirst := broker.Subscribe(1)
+	second, unsubscribeSecond := broker.Subscribe(1)
+	other, unsubscribeOther := broker.Subscribe(2)
+	defer unsubscribeSecond()
+	defer unsubscribeOther()
+
+	broker.Publish(1)
+	broker.Publish(1)
+	assert.True(t, woken(first))
+	assert.False(t, woken(first), "pending signals should be coalesced")
+	assert.True(t, woken(second))
+	assert.False(t, woken(other))
+
+	unsubscribeFirst()
+	broker.Publish(1)
+	assert.False(t, woken(first))
+	assert.True(t, woken(second))
+}
+
+func TestBroker_PublishAll(t *testing.T) {
+	broker := NewBroker()
+	first, unsubscribeFirst := broker.Subscribe(1)
+	second, unsubscribeSecond := broker.Subscribe(2)
+	defer unsubscribeFirst()
+	defer unsubscribeSecond()
+
+	broker.PublishAll()
+	assert.True(t, woken(first))
+	assert.True(t, woken(second))
+}
@@ -0,0 +1,50 @@
+package events
+
+import (
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"strconv"
+	"time"
+)
+
+const (
+	listenerMinReconnectInterval = time.Second
+	listenerMaxReconnectInterval = time.Minute
+)
+
+// ListenPostgres relays notifications of dao.OrderEventsChannel to broker, so events written by
+// any replica reach subscribers of this one. Listener reconnects by itself, after reconnect all
+// subscribers are woken up, as notifications could have been lost meanwhile.
+func ListenPostgres(dbURI string, broker *Broker) (*pq.Listener, error) {
+	listener := pq.NewListener(
+		dbURI,
+		listenerMinReconnectInterval,
+		listenerMaxReconnectInterval,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Error("order events listener: ", err)
+			}
+		},
+	)
+	err := listener.Listen(dao.OrderEventsChannel)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	go func() {
+		for notification := range listener.Notify {
+			if notification == nil {
+				broker.PublishAll()
+				continue
+			}
+			userID, err := strconv.Atoi(notification.Extra)
+			if err != nil {
+				log.Errorf("malformed order event notification %q", notification.Extra)
+				continue
+			}
+			broker.Publish(userID)
+		}
+	}()
+	return listener, nil
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+type AdminHandler struct {
+	adminService service.Admin
+}
+
+func NewAdminHandler(adminService *service.Admin) AdminHandler {
+	return AdminHandler{adminService: *adminService}
+}
+
+func (h AdminHandler) HandleSearchUsers(writer http.ResponseWriter, request *http.Request) {
+	actorID := GetUserIDFromToken(request.Context())
+	users, err := h.adminService.SearchUsers(actorID, request.URL.Query().Get("login"))
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, users)
+}
+
+func (h AdminHandler) HandleGetUserAccount(writer http.ResponseWriter, request *http.Request) {
+	actorID := GetUserIDFromToken(request.Context())
+	userID, ok := parseUserID(writer, request)
+	if !ok {
+		return
+	}
+	account, err := h.adminService.GetUserAccount(actorID, userID)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, account)
+}
+
+func (h AdminHandler) HandleAdjustBalance(writer http.ResponseWriter, request *http.Request) {
+	var adjustment model.BalanceAdjustment
+	actorID := GetUserIDFromToken(request.Context())
+	userID, ok := parseUserID(writer, request)
+	if !ok {
+		return
+	}
+	if !parseJSONBody(writer, request, &adjustment, "must be valid json object with amount and reason") {
+		return
+	}
+	balance, err := h.adminService.AdjustBalance(actorID, userID, adjustment)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Warnf("balance of user %d adjusted by %f by user %d", userID, adjustment.Amount, actorID)
+	writeJSON(writer, http.StatusOK, balance)
+}
+
+func (h AdminHandler) HandleChangeOrderStatus(writer http.ResponseWriter, request *http.Request) {
+	var change model.OrderStatusChange
+	actorID := GetUserIDFromToken(request.Context())
+	number := chi.URLParam(request, "number")
+	if !parseJSONBody(writer, request, &change, "must be valid json object with status and reason") {
+		return
+	}
+	order, err := h.adminService.ChangeOrderStatus(actorID, number, change)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Warnf("status of order %s set to %s by user %d", number, change.Status, actorID)
+	writeJSON(writer, http.StatusOK, order)
+}
+
+func (h AdminHandler) HandleReassignOrder(writer http.ResponseWriter, request *http.Request) {
+	var reassignment model.OrderReassignment
+	actorID := GetUserIDFromToken(request.Context())
+	number := chi.URLParam(request, "number")
+	if !parseJSONBody(writer, request, &reassignment, "must be valid json object with login and reason") {
+		return
+	}
+	order, err := h.adminService.ReassignOrder(actorID, number, reassignment)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Warnf("order %s reassigned to %s by user %d", number, reassignment.Login, actorID)
+	writeJSON(writer, http.StatusOK, order)
+}
+
+func (h AdminHandler) HandleSetRole(writer http.ResponseWriter, request *http.Request) {
+	var roleChange model.RoleChange
+	login := chi.URLParam(request, "login")
+	if !parseJSONBody(writer, request, &roleChange, "must be valid json object with role") {
+		return
+	}
+	err := h.adminService.SetRole(GetActorIDFromToken(request.Context()), login, roleChange.Role)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Warnf("role of %s set to %s", login, roleChange.Role)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h AdminHandler) HandleClearLockout(writer http.ResponseWriter, request *http.Request) {
+	actorID := GetUserIDFromToken(request.Context())
+	login := chi.URLParam(request, "login")
+	err := h.adminService.ClearLockout(actorID, login)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Warnf("login lockout cleared for %s", login)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h AdminHandler) HandleSetLoyaltyCard(writer http.ResponseWriter, request *http.Request) {
+	var change model.LoyaltyCardChange
+	actorID := GetUserIDFromToken(request.Context())
+	userID, ok := parseUserID(writer, request)
+	if !ok {
+		return
+	}
+	if !parseJSONBody(writer, request, &change, "must be valid json object with loyalty_card") {
+		return
+	}
+	err := h.adminService.SetLoyaltyCard(actorID, userID, change.LoyaltyCard)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Warnf("loyalty card of user %d changed by user %d", userID, actorID)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h AdminHandler) HandleGetAuditTrail(writer http.ResponseWriter, request *http.Request) {
+	var userID *int
+	if param := request.URL.Query().Get("user_id"); param != "" {
+		id, err := strconv.Atoi(param)
+		if err != nil {
+			validationErr := &errors.ValidationError{}
+			validationErr.Add("user_id", "must be integer")
+			writeValidationError(writer, validationErr)
+			return
+		}
+		userID = &id
+	}
+	entries, err := h.adminService.GetAuditTrail(GetUserIDFromToken(request.Context()), userID)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, entries)
+}
+
+func (h AdminHandler) HandleGetStats(writer http.ResponseWriter, request *http.Request) {
+	stats, err := h.adminService.GetStats(GetUserIDFromToken(request.Context()))
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, stats)
+}
+
+func (h AdminHandler) writeError(writer http.ResponseWriter, err error) {
+	switch e := err.(type) {
+	case *errors.ValidationError:
+		log.Error(err)
+		writeValidationError(writer, e)
+	case *errors.UserNotFoundError, *errors.NoOrdersError:
+		log.Error(err)
+		writer.WriteHeader(http.StatusNotFound)
+	case *errors.LowBalanceError, *errors.LoyaltyCardTakenError:
+		log.Error(err)
+		writer.WriteHeader(http.StatusConflict)
+	default:
+		log.Error("error during admin action ", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func parseUserID(writer http.ResponseWriter, request *http.Request) (int, bool) {
+	return parseIntParam(writer, request, "id")
+}
+
+// parseJSONBody decodes limited request body into value, responding with validation error on failure.
+func parseJSONBody(writer http.ResponseWriter, request *http.Request, value interface{}, description string) bool {
+	data, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxUserBodySize))
+	if err == nil {
+		err = json.Unmarshal(data, value)
+	}
+	if err != nil {
+		log.Error(err)
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("body", description)
+		writeValidationError(writer, validationErr)
+		return false
+	}
+	return true
+}
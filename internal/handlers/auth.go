@@ -2,8 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
-	"github.com/go-chi/jwtauth/v5"
+	"fmt"
 	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
 	"github.com/yurchenkosv/gofermart/internal/errors"
 	"github.com/yurchenkosv/gofermart/internal/model"
 	"github.com/yurchenkosv/gofermart/internal/service"
@@ -11,28 +12,53 @@ import (
 	"net/http"
 )
 
+const maxUserBodySize = 4 << 10
+
 type AuthHandler struct {
-	authService service.Auth
-	jwtAuth     *jwtauth.JWTAuth
+	authService   service.Auth
+	tokenService  service.Token
+	loginThrottle service.LoginThrottle
+	twoFactor     service.TwoFactor
+	cfg           *config.ServerConfig
 }
 
-func NewAuthHanler(authService *service.Auth, jwtAuth *jwtauth.JWTAuth) AuthHandler {
+func NewAuthHanler(
+	authService *service.Auth,
+	tokenService *service.Token,
+	loginThrottle *service.LoginThrottle,
+	twoFactor *service.TwoFactor,
+	cfg *config.ServerConfig,
+) AuthHandler {
 	return AuthHandler{
-		authService: *authService,
-		jwtAuth:     jwtAuth,
+		authService:   *authService,
+		tokenService:  *tokenService,
+		loginThrottle: *loginThrottle,
+		twoFactor:     *twoFactor,
+		cfg:           cfg,
 	}
 }
 
 func (h AuthHandler) HandleUserRegistration(writer http.ResponseWriter, request *http.Request) {
-	user, err := h.parseForUser(request)
+	user, err := h.parseForUser(writer, request)
 	if err != nil {
-		log.Error(err)
-		writer.WriteHeader(http.StatusInternalServerError)
-		return
+		switch e := err.(type) {
+		case *errors.ValidationError:
+			log.Error(err)
+			writeValidationError(writer, e)
+			return
+		default:
+			log.Error(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 	}
 	updatedUser, err := h.authService.RegisterUser(user)
 	if err != nil {
 		switch e := err.(type) {
+		case *errors.ValidationError:
+			log.Error(err)
+			writeValidationError(writer, e)
+			return
 		case *errors.UserAlreadyExistsError:
 			log.Error(err)
 			writer.WriteHeader(http.StatusConflict)
@@ -43,21 +69,49 @@ func (h AuthHandler) HandleUserRegistration(writer http.ResponseWriter, request
 			return
 		}
 	}
-	writer = SetToken(writer, *updatedUser, h.jwtAuth)
+	if err := SetToken(writer, request, *updatedUser, h.tokenService, h.cfg); err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 	writer.WriteHeader(http.StatusOK)
 }
 
 func (h AuthHandler) HanldeUserLogin(writer http.ResponseWriter, request *http.Request) {
-	user, err := h.parseForUser(request)
+	user, err := h.parseForUser(writer, request)
 	if err != nil {
-		log.Error(err)
-		writer.WriteHeader(http.StatusInternalServerError)
-		return
+		switch e := err.(type) {
+		case *errors.ValidationError:
+			log.Error(err)
+			writeValidationError(writer, e)
+			return
+		default:
+			log.Error(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	ip := clientIP(request)
+	err = h.loginThrottle.Check(user.Login, ip)
+	if err != nil {
+		switch e := err.(type) {
+		case *errors.LoginLockedError:
+			log.Warn(err)
+			setRetryAfter(writer, e.RetryAfter)
+			writer.WriteHeader(http.StatusTooManyRequests)
+			return
+		default:
+			log.Error("error checking login attempts ", e)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 	}
 	updatedUser, err := h.authService.AuthenticateUser(user)
 	if err != nil {
 		switch e := err.(type) {
-
+		case *errors.ValidationError:
+			log.Error(err)
+			writeValidationError(writer, e)
+			return
 		case *errors.InvalidUserError:
 			log.Error(err)
 			writer.WriteHeader(http.StatusUnauthorized)
@@ -68,20 +122,240 @@ func (h AuthHandler) HanldeUserLogin(writer http.ResponseWriter, request *http.R
 			return
 		}
 	}
-	writer = SetToken(writer, *updatedUser, h.jwtAuth)
+	twoFactorEnabled, err := h.twoFactor.IsEnabled(*updatedUser.ID)
+	if err != nil {
+		log.Error("error checking two-factor authentication ", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// attempt stays counted until the second factor is verified, so that password alone
+	// doesn't reset failures of login
+	if twoFactorEnabled {
+		writeLoginChallenge(writer, h.twoFactor, *updatedUser, h.cfg)
+		return
+	}
+	if err := h.loginThrottle.RegisterSuccess(user.Login, ip); err != nil {
+		log.Error("error resetting failed logins ", err)
+	}
+	if err := SetToken(writer, request, *updatedUser, h.tokenService, h.cfg); err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 	writer.WriteHeader(http.StatusOK)
 }
 
-func (h AuthHandler) parseForUser(request *http.Request) (*model.User, error) {
+// HandleTwoFactorLogin completes login of user with enabled two-factor authentication,
+// exchanging challenge token returned by HanldeUserLogin and TOTP or recovery code for tokens.
+func (h AuthHandler) HandleTwoFactorLogin(writer http.ResponseWriter, request *http.Request) {
+	var twoFactorLogin model.TwoFactorLogin
+
+	data, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxUserBodySize))
+	if err == nil {
+		err = json.Unmarshal(data, &twoFactorLogin)
+	}
+	if err != nil {
+		log.Error(err)
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("body", "must be valid json object with challenge_token and code")
+		writeValidationError(writer, validationErr)
+		return
+	}
+
+	ip := clientIP(request)
+	user, err := h.twoFactor.CompleteChallenge(twoFactorLogin.ChallengeToken, twoFactorLogin.Code, ip)
+	if err != nil {
+		switch e := err.(type) {
+		case *errors.InvalidTokenError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		case *errors.LoginLockedError:
+			log.Warn(err)
+			setRetryAfter(writer, e.RetryAfter)
+			writer.WriteHeader(http.StatusTooManyRequests)
+			return
+		case *errors.InvalidTwoFactorCodeError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		default:
+			log.Error("error during two-factor authentication ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := h.loginThrottle.RegisterSuccess(user.Login, ip); err != nil {
+		log.Error("error resetting failed logins ", err)
+	}
+	if err := SetToken(writer, request, *user, h.tokenService, h.cfg); err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (h AuthHandler) HandleTokenRefresh(writer http.ResponseWriter, request *http.Request) {
+	refreshToken, err := h.parseForRefreshToken(writer, request)
+	if err != nil {
+		log.Error(err)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if refreshToken == "" {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	tokens, err := h.tokenService.RefreshTokens(refreshToken)
+	if err != nil {
+		switch err.(type) {
+		case *errors.InvalidTokenError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		default:
+			log.Error("error refreshing token ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := writeTokens(writer, tokens, h.cfg); err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (h AuthHandler) HandleUserLogout(writer http.ResponseWriter, request *http.Request) {
+	jti, sessionID, expiresAt := GetSessionFromToken(request.Context())
+	err := h.tokenService.Logout(jti, sessionID, expiresAt)
+	if err != nil {
+		log.Error("error during logout ", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	clearTokens(writer, h.cfg)
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (h AuthHandler) HandleChangePassword(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	var change model.PasswordChange
+
+	data, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxUserBodySize))
+	if err == nil {
+		err = json.Unmarshal(data, &change)
+	}
+	if err != nil {
+		log.Error(err)
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("body", "must be valid json object with current_password and new_password")
+		writeValidationError(writer, validationErr)
+		return
+	}
+
+	err = h.authService.ChangePassword(userID, change)
+	if err != nil {
+		switch e := err.(type) {
+		case *errors.ValidationError:
+			log.Error(err)
+			writeValidationError(writer, e)
+			return
+		case *errors.InvalidUserError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusForbidden)
+			return
+		default:
+			log.Error("error changing password ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	// all sessions were terminated, current client continues with a new one
+	if err := SetToken(writer, request, model.User{ID: &userID}, h.tokenService, h.cfg); err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (h AuthHandler) HandleDeleteUser(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	err := h.authService.DeleteUser(userID)
+	if err != nil {
+		log.Error("error deleting user ", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	log.Infof("user %d deleted", userID)
+	clearTokens(writer, h.cfg)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// writeLoginChallenge responds to a sign in of user with enabled two-factor authentication
+// with challenge token, which is exchanged for tokens at /api/user/login/2fa.
+func writeLoginChallenge(writer http.ResponseWriter, twoFactor service.TwoFactor, user model.User, cfg *config.ServerConfig) {
+	challengeToken, err := twoFactor.StartChallenge(user)
+	if err != nil {
+		log.Error("error starting two-factor challenge ", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	body, err := json.Marshal(struct {
+		ChallengeToken string `json:"challenge_token"`
+		ExpiresIn      int    `json:"expires_in"`
+	}{
+		ChallengeToken: challengeToken,
+		ExpiresIn:      int(cfg.TwoFactorChallengeTTL.Seconds()),
+	})
+	if err != nil {
+		log.Error("error marshalling to json", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Add("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusAccepted)
+	writer.Write(body)
+}
+
+func (h AuthHandler) parseForUser(writer http.ResponseWriter, request *http.Request) (*model.User, error) {
 	var user model.User
 
-	data, err := io.ReadAll(request.Body)
+	data, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxUserBodySize))
 	if err != nil {
-		return nil, err
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("body", fmt.Sprintf("must not exceed %d bytes", maxUserBodySize))
+		return nil, validationErr
 	}
 	err = json.Unmarshal(data, &user)
 	if err != nil {
-		log.Error(err)
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("body", "must be valid json object with login and password")
+		return nil, validationErr
 	}
 	return &user, nil
 }
+
+// parseForRefreshToken takes refresh token from json body, falling back to cookie.
+func (h AuthHandler) parseForRefreshToken(writer http.ResponseWriter, request *http.Request) (string, error) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxUserBodySize))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &body)
+		if err != nil {
+			return "", err
+		}
+	}
+	if body.RefreshToken != "" {
+		return body.RefreshToken, nil
+	}
+	if cookie, err := request.Cookie(RefreshTokenCookie); err == nil {
+		return cookie.Value, nil
+	}
+	return "", nil
+}
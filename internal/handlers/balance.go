@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	log "github.com/sirupsen/logrus"
 	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/middlewares"
 	"github.com/yurchenkosv/gofermart/internal/model"
 	"github.com/yurchenkosv/gofermart/internal/service"
 	"io"
@@ -14,12 +15,18 @@ import (
 type BalanceHandler struct {
 	balanceService  service.Balance
 	withdrawService service.Withdraw
+	twoFactor       service.TwoFactor
 }
 
-func NewBalanceHandler(balanceService *service.Balance, withdrawService *service.Withdraw) BalanceHandler {
+func NewBalanceHandler(
+	balanceService *service.Balance,
+	withdrawService *service.Withdraw,
+	twoFactor *service.TwoFactor,
+) BalanceHandler {
 	return BalanceHandler{
 		balanceService:  *balanceService,
 		withdrawService: *withdrawService,
+		twoFactor:       *twoFactor,
 	}
 }
 
@@ -46,6 +53,9 @@ func (h BalanceHandler) HandleBalanceWithdraw(writer http.ResponseWriter, reques
 		ProcessedAt: time.Now(),
 		User:        model.User{ID: &userID},
 	}
+	if attempt := middlewares.IdempotencyKeyFromContext(request.Context()); attempt != nil {
+		withdraw.IdempotencyKeyID = attempt.ID
+	}
 
 	body, err := io.ReadAll(request.Body)
 	if err != nil {
@@ -58,6 +68,25 @@ func (h BalanceHandler) HandleBalanceWithdraw(writer http.ResponseWriter, reques
 		return
 	}
 
+	err = h.twoFactor.AuthorizeWithdrawal(userID, withdraw.Sum, request.Header.Get(TOTPHeaderName))
+	if err != nil {
+		switch e := err.(type) {
+		case *errors.TwoFactorRequiredError, *errors.InvalidTwoFactorCodeError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusForbidden)
+			return
+		case *errors.LoginLockedError:
+			log.Warn(err)
+			setRetryAfter(writer, e.RetryAfter)
+			writer.WriteHeader(http.StatusTooManyRequests)
+			return
+		default:
+			log.Error("error authorizing withdraw", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
 	err = h.withdrawService.ProcessWithdraw(withdraw)
 	if err != nil {
 		switch err.(type) {
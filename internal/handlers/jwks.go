@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/keys"
+	"net/http"
+)
+
+type JWKSHandler struct {
+	keySet *keys.KeySet
+}
+
+func NewJWKSHandler(keySet *keys.KeySet) JWKSHandler {
+	return JWKSHandler{keySet: keySet}
+}
+
+func (h JWKSHandler) HandleGetJWKS(writer http.ResponseWriter, request *http.Request) {
+	body, err := json.Marshal(h.keySet.PublicKeys())
+	if err != nil {
+		log.Error("error marshalling jwks ", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Add("Content-Type", "application/json")
+	writer.Header().Add("Cache-Control", "public, max-age=300")
+	writer.Write(body)
+}
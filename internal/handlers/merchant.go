@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"net/http"
+	"strconv"
+)
+
+type MerchantHandler struct {
+	merchantService service.Merchant
+}
+
+func NewMerchantHandler(merchantService *service.Merchant) MerchantHandler {
+	return MerchantHandler{merchantService: *merchantService}
+}
+
+func (h MerchantHandler) HandleCreateMerchant(writer http.ResponseWriter, request *http.Request) {
+	var merchant model.Merchant
+	actorID := GetUserIDFromToken(request.Context())
+	if !parseJSONBody(writer, request, &merchant, "must be valid json object with name") {
+		return
+	}
+	created, err := h.merchantService.CreateMerchant(actorID, merchant.Name)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Warnf("merchant %d created by user %d", *created.ID, actorID)
+	writeJSON(writer, http.StatusCreated, created)
+}
+
+func (h MerchantHandler) HandleIssueAPIKey(writer http.ResponseWriter, request *http.Request) {
+	var keyRequest model.APIKeyRequest
+	actorID := GetUserIDFromToken(request.Context())
+	merchantID, ok := parseIntParam(writer, request, "id")
+	if !ok {
+		return
+	}
+	if request.ContentLength != 0 &&
+		!parseJSONBody(writer, request, &keyRequest, "must be valid json object with scopes") {
+		return
+	}
+	key, err := h.merchantService.IssueAPIKey(actorID, merchantID, keyRequest.Scopes)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Warnf("api key %s issued for merchant %d by user %d", key.Prefix, merchantID, actorID)
+	writeJSON(writer, http.StatusCreated, key)
+}
+
+func (h MerchantHandler) HandleGetAPIKeys(writer http.ResponseWriter, request *http.Request) {
+	merchantID, ok := parseIntParam(writer, request, "id")
+	if !ok {
+		return
+	}
+	keys, err := h.merchantService.ListAPIKeys(merchantID)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, keys)
+}
+
+func (h MerchantHandler) HandleRevokeAPIKey(writer http.ResponseWriter, request *http.Request) {
+	actorID := GetUserIDFromToken(request.Context())
+	merchantID, ok := parseIntParam(writer, request, "id")
+	if !ok {
+		return
+	}
+	keyID, ok := parseIntParam(writer, request, "keyID")
+	if !ok {
+		return
+	}
+	err := h.merchantService.RevokeAPIKey(actorID, merchantID, keyID)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Warnf("api key %d of merchant %d revoked by user %d", keyID, merchantID, actorID)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSetNumberFormat sets own order number format of merchant, empty pattern removes it.
+func (h MerchantHandler) HandleSetNumberFormat(writer http.ResponseWriter, request *http.Request) {
+	var format model.NumberFormat
+	actorID := GetUserIDFromToken(request.Context())
+	merchantID, ok := parseIntParam(writer, request, "id")
+	if !ok {
+		return
+	}
+	if !parseJSONBody(writer, request, &format, "must be valid json object with pattern") {
+		return
+	}
+	merchant, err := h.merchantService.SetNumberFormat(actorID, merchantID, format)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Warnf("number format of merchant %d set by user %d", merchantID, actorID)
+	writeJSON(writer, http.StatusOK, merchant)
+}
+
+func (h MerchantHandler) writeError(writer http.ResponseWriter, err error) {
+	switch e := err.(type) {
+	case *errors.ValidationError:
+		log.Error(err)
+		writeValidationError(writer, e)
+	case *errors.MerchantNotFoundError, *errors.APIKeyNotFoundError:
+		log.Error(err)
+		writer.WriteHeader(http.StatusNotFound)
+	default:
+		log.Error("error during merchant action ", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func parseIntParam(writer http.ResponseWriter, request *http.Request, name string) (int, bool) {
+	value, err := strconv.Atoi(chi.URLParam(request, name))
+	if err != nil {
+		validationErr := &errors.ValidationError{}
+		validationErr.Add(name, "must be integer")
+		writeValidationError(writer, validationErr)
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"github.com/go-chi/jwtauth/v5"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"net/http"
+)
+
+// oidcBindingCookie ties state of authorization request to the browser, which started it.
+const (
+	oidcBindingCookie     = "oidc_binding"
+	oidcBindingCookiePath = "/api/user/oidc"
+)
+
+type OIDCHandler struct {
+	oidcService  service.OIDC
+	tokenService service.Token
+	twoFactor    service.TwoFactor
+	cfg          *config.ServerConfig
+}
+
+func NewOIDCHandler(
+	oidcService *service.OIDC,
+	tokenService *service.Token,
+	twoFactor *service.TwoFactor,
+	cfg *config.ServerConfig,
+) OIDCHandler {
+	return OIDCHandler{
+		oidcService:  *oidcService,
+		tokenService: *tokenService,
+		twoFactor:    *twoFactor,
+		cfg:          cfg,
+	}
+}
+
+// HandleLogin redirects user to provider to sign in.
+func (h OIDCHandler) HandleLogin(writer http.ResponseWriter, request *http.Request) {
+	authorizationURL, binding, err := h.oidcService.StartLogin(nil)
+	if err != nil {
+		log.Error("error starting openid connect login ", err)
+		writer.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	h.setBinding(writer, binding)
+	http.Redirect(writer, request, authorizationURL, http.StatusFound)
+}
+
+// HandleLink returns provider URL, signing in there links provider identity to current user.
+// Callback has to be authenticated as the same user, so access token cookie must be sent
+// on redirect from provider, which is not the case with strict same-site cookies.
+func (h OIDCHandler) HandleLink(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	authorizationURL, binding, err := h.oidcService.StartLogin(&userID)
+	if err != nil {
+		log.Error("error starting openid connect link ", err)
+		writer.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	h.setBinding(writer, binding)
+	writeJSON(writer, http.StatusOK, model.OIDCAuthorization{AuthorizationURL: authorizationURL})
+}
+
+// HandleCallback is redirect URL registered at provider. It completes sign in the same
+// way as password login does, including two-factor challenge.
+func (h OIDCHandler) HandleCallback(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Errorf("openid connect provider returned error %s: %s", providerErr, query.Get("error_description"))
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if query.Get("state") == "" || query.Get("code") == "" {
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("code", "state and code must be present")
+		writeValidationError(writer, validationErr)
+		return
+	}
+
+	var binding string
+	if cookie, err := request.Cookie(oidcBindingCookie); err == nil {
+		binding = cookie.Value
+	}
+	h.clearBinding(writer)
+
+	user, err := h.oidcService.CompleteLogin(query.Get("state"), query.Get("code"), binding, h.callerID(request))
+	if err != nil {
+		switch err.(type) {
+		case *errors.InvalidTokenError, *errors.OIDCAuthenticationError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		case *errors.OIDCIdentityLinkedError, *errors.UserAlreadyExistsError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusConflict)
+			return
+		default:
+			log.Error("error completing openid connect login ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	twoFactorEnabled, err := h.twoFactor.IsEnabled(*user.ID)
+	if err != nil {
+		log.Error("error checking two-factor authentication ", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if twoFactorEnabled {
+		writeLoginChallenge(writer, h.twoFactor, *user, h.cfg)
+		return
+	}
+	if err := SetToken(writer, request, *user, h.tokenService, h.cfg); err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (h OIDCHandler) setBinding(writer http.ResponseWriter, binding string) {
+	cookie := newCookie(h.cfg, oidcBindingCookie, binding, h.cfg.OIDCStateTTL)
+	cookie.Path = oidcBindingCookiePath
+	cookie.HttpOnly = true
+	// strict cookie would not be sent on redirect back from provider
+	cookie.SameSite = http.SameSiteLaxMode
+	http.SetCookie(writer, cookie)
+}
+
+func (h OIDCHandler) clearBinding(writer http.ResponseWriter) {
+	cookie := newCookie(h.cfg, oidcBindingCookie, "", 0)
+	cookie.Path = oidcBindingCookiePath
+	cookie.HttpOnly = true
+	cookie.MaxAge = -1
+	http.SetCookie(writer, cookie)
+}
+
+// callerID returns user authenticated by valid access token of active session, if any.
+func (h OIDCHandler) callerID(request *http.Request) *int {
+	token, _, err := jwtauth.FromContext(request.Context())
+	if err != nil || token == nil {
+		return nil
+	}
+	jti, sessionID, _ := GetSessionFromToken(request.Context())
+	revoked, err := h.tokenService.IsRevoked(jti, sessionID)
+	if err != nil {
+		log.Error("cannot check token revocation: ", err)
+		return nil
+	}
+	if revoked {
+		return nil
+	}
+	return GetActorIDFromToken(request.Context())
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/events"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	orderEventName = "order_status"
+	// orderEventsKeepAlive is interval of comments keeping idle stream open through proxies,
+	// events are also rechecked then, in case notification was lost, and so is revocation of token.
+	orderEventsKeepAlive = 15 * time.Second
+	lastEventIDHeader    = "Last-Event-ID"
+)
+
+type OrderEventsHandler struct {
+	orderService service.Order
+	tokenService service.Token
+	broker       *events.Broker
+}
+
+func NewOrderEventsHandler(orderService *service.Order, tokenService *service.Token, broker *events.Broker) OrderEventsHandler {
+	return OrderEventsHandler{
+		orderService: *orderService,
+		tokenService: *tokenService,
+		broker:       broker,
+	}
+}
+
+// HandleOrderEvents streams status changes of user orders as server-sent events. Stream resumes
+// after the event in Last-Event-ID header, without it only new events are sent. Stream is closed,
+// when access token expires or is revoked, so client has to reconnect with the refreshed one.
+func (h OrderEventsHandler) HandleOrderEvents(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		log.Error("streaming is not supported by response writer")
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	userID := GetUserIDFromToken(request.Context())
+	jti, sessionID, expiresAt := GetSessionFromToken(request.Context())
+
+	// subscribe before reading events, so that no event is missed in between
+	wake, unsubscribe := h.broker.Subscribe(userID)
+	defer unsubscribe()
+
+	var (
+		lastID int
+		err    error
+	)
+	if value := request.Header.Get(lastEventIDHeader); value != "" {
+		lastID, err = strconv.Atoi(value)
+		if err != nil || lastID < 0 {
+			validationErr := &errors.ValidationError{}
+			validationErr.Add(lastEventIDHeader, "must be id of event")
+			writeValidationError(writer, validationErr)
+			return
+		}
+	} else {
+		lastID, err = h.orderService.GetLastOrderEventID(userID)
+		if err != nil {
+			log.Error("error getting last order event ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(orderEventsKeepAlive)
+	defer keepAlive.Stop()
+	expired := time.NewTimer(time.Until(expiresAt))
+	defer expired.Stop()
+	for {
+		lastID, err = h.writeOrderEvents(writer, userID, lastID)
+		if err != nil {
+			log.Error("error streaming order events ", err)
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-request.Context().Done():
+			return
+		case <-wake:
+		case <-expired.C:
+			return
+		case <-keepAlive.C:
+			revoked, err := h.tokenService.IsRevoked(jti, sessionID)
+			if err != nil {
+				log.Error("cannot check token revocation: ", err)
+				return
+			}
+			if revoked {
+				return
+			}
+			if _, err = fmt.Fprint(writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeOrderEvents writes all events of user after lastID and returns id of the last written one.
+func (h OrderEventsHandler) writeOrderEvents(writer http.ResponseWriter, userID int, lastID int) (int, error) {
+	for {
+		orderEvents, err := h.orderService.GetOrderEvents(userID, lastID)
+		if err != nil || len(orderEvents) == 0 {
+			return lastID, err
+		}
+		for _, orderEvent := range orderEvents {
+			data, err := json.Marshal(orderEvent)
+			if err != nil {
+				return lastID, err
+			}
+			_, err = fmt.Fprintf(writer, "id: %d\nevent: %s\ndata: %s\n\n", orderEvent.ID, orderEventName, data)
+			if err != nil {
+				return lastID, err
+			}
+			lastID = orderEvent.ID
+		}
+	}
+}
@@ -1,17 +1,28 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
 	log "github.com/sirupsen/logrus"
 	"github.com/yurchenkosv/gofermart/internal/errors"
 	"github.com/yurchenkosv/gofermart/internal/model"
 	"github.com/yurchenkosv/gofermart/internal/service"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
+const (
+	maxOrderBatchBodySize = 64 << 10
+	nextCursorHeader      = "X-Next-Cursor"
+)
+
 type OrdersHanlder struct {
 	orderService service.Order
 }
@@ -66,12 +77,71 @@ func (h OrdersHanlder) HandleCreateOrder(writer http.ResponseWriter, request *ht
 	writer.WriteHeader(http.StatusAccepted)
 }
 
+// HandleCreateOrders uploads batch of orders. Body is JSON array of numbers or plain text
+// with numbers separated by newlines or commas.
+func (h OrdersHanlder) HandleCreateOrders(writer http.ResponseWriter, request *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxOrderBatchBodySize))
+	if err != nil {
+		log.Error(err)
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("body", fmt.Sprintf("must not exceed %d bytes", maxOrderBatchBodySize))
+		writeValidationError(writer, validationErr)
+		return
+	}
+
+	var numbers []string
+	if strings.HasPrefix(request.Header.Get("Content-Type"), "application/json") {
+		numbers, err = parseOrderNumbersJSON(body)
+	} else {
+		numbers, err = parseOrderNumbersCSV(body)
+	}
+	if err != nil {
+		log.Error(err)
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("body", "must be json array or list of order numbers separated by newlines or commas")
+		writeValidationError(writer, validationErr)
+		return
+	}
+
+	userID := GetUserIDFromToken(request.Context())
+	log.Infof("creating batch of %d orders, by user %d", len(numbers), userID)
+
+	results, err := h.orderService.CreateOrders(userID, numbers)
+	if err != nil {
+		switch e := err.(type) {
+		case *errors.ValidationError:
+			log.Error(err)
+			writeValidationError(writer, e)
+			return
+		default:
+			log.Error("error creating orders ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(writer, http.StatusOK, results)
+}
+
+// HandleGetOrders returns page of orders. Query parameters: status (repeated or comma
+// separated), uploaded_from and uploaded_to in RFC3339, sort (uploaded_at or -uploaded_at),
+// limit and cursor. When there are more orders, cursor of the next page is returned in
+// X-Next-Cursor header and Link header with rel="next".
 func (h OrdersHanlder) HandleGetOrders(writer http.ResponseWriter, request *http.Request) {
 	userID := GetUserIDFromToken(request.Context())
-	log.Infof("getting all orders with user %d", userID)
-	orders, err := h.orderService.GetUploadedOrdersForUser(userID)
+	query := request.URL.Query()
+	filter, validationErr := parseOrderFilter(query)
+	if validationErr.HasErrors() {
+		writeValidationError(writer, validationErr)
+		return
+	}
+	log.Infof("getting orders with user %d", userID)
+	page, err := h.orderService.GetOrdersPage(userID, filter, query.Get("cursor"))
 	if err != nil {
-		switch err.(type) {
+		switch e := err.(type) {
+		case *errors.ValidationError:
+			log.Error(err)
+			writeValidationError(writer, e)
+			return
 		case *errors.NoOrdersError:
 			log.Error(err)
 			writer.WriteHeader(http.StatusNoContent)
@@ -82,10 +152,139 @@ func (h OrdersHanlder) HandleGetOrders(writer http.ResponseWriter, request *http
 			return
 		}
 	}
-	result, err := json.Marshal(orders)
+	if page.NextCursor != "" {
+		query.Set("cursor", page.NextCursor)
+		nextURL := url.URL{Path: request.URL.Path, RawQuery: query.Encode()}
+		writer.Header().Set(nextCursorHeader, page.NextCursor)
+		writer.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextURL.String()))
+	}
+	writeJSON(writer, http.StatusOK, page.Orders)
+}
+
+// HandleGetOrder returns order of user with timeline of its status transitions.
+func (h OrdersHanlder) HandleGetOrder(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	number := chi.URLParam(request, "number")
+	details, err := h.orderService.GetOrder(userID, number)
 	if err != nil {
-		log.Error(err)
+		switch err.(type) {
+		case *errors.NoOrdersError:
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		default:
+			log.Error("error getting order ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(writer, http.StatusOK, details)
+}
+
+// HandleDeleteOrder deletes order uploaded by mistake, while it is not processed yet.
+func (h OrdersHanlder) HandleDeleteOrder(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	number := chi.URLParam(request, "number")
+	err := h.orderService.DeleteOrder(userID, number)
+	if err != nil {
+		switch err.(type) {
+		case *errors.NoOrdersError:
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		case *errors.OrderNotDeletableError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusConflict)
+			return
+		default:
+			log.Error("error deleting order ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	log.Infof("order %s deleted by user %d", number, userID)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func parseOrderFilter(query url.Values) (model.OrderFilter, *errors.ValidationError) {
+	var filter model.OrderFilter
+	validationErr := &errors.ValidationError{}
+	for _, value := range query["status"] {
+		for _, status := range strings.Split(value, ",") {
+			if status = strings.ToUpper(strings.TrimSpace(status)); status != "" {
+				filter.Statuses = append(filter.Statuses, status)
+			}
+		}
+	}
+	for _, param := range []struct {
+		name   string
+		target **time.Time
+	}{
+		{name: "uploaded_from", target: &filter.UploadedFrom},
+		{name: "uploaded_to", target: &filter.UploadedTo},
+	} {
+		if value := query.Get(param.name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				validationErr.Add(param.name, "must be date in RFC3339 format")
+				continue
+			}
+			*param.target = &parsed
+		}
+	}
+	switch query.Get("sort") {
+	case "", "uploaded_at":
+	case "-uploaded_at":
+		filter.Descending = true
+	default:
+		validationErr.Add("sort", "must be uploaded_at or -uploaded_at")
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			validationErr.Add("limit", "must be positive integer")
+		}
+		filter.Limit = limit
+	}
+	return filter, validationErr
+}
+
+// parseOrderNumbersJSON accepts numbers both as strings and as json numbers.
+func parseOrderNumbersJSON(body []byte) ([]string, error) {
+	var items []interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	err := decoder.Decode(&items)
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]string, 0, len(items))
+	for _, item := range items {
+		switch number := item.(type) {
+		case string:
+			numbers = append(numbers, strings.TrimSpace(number))
+		case json.Number:
+			numbers = append(numbers, number.String())
+		default:
+			return nil, fmt.Errorf("unexpected order number %v", item)
+		}
+	}
+	return numbers, nil
+}
+
+func parseOrderNumbersCSV(body []byte) ([]string, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var numbers []string
+	for _, record := range records {
+		for _, field := range record {
+			if number := strings.TrimSpace(field); number != "" {
+				numbers = append(numbers, number)
+			}
+		}
 	}
-	writer.Header().Add("Content-Type", "application/json")
-	writer.Write(result)
+	return numbers, nil
 }
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/middlewares"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"net/http"
+)
+
+type PartnerHandler struct {
+	merchantService service.Merchant
+}
+
+func NewPartnerHandler(merchantService *service.Merchant) PartnerHandler {
+	return PartnerHandler{merchantService: *merchantService}
+}
+
+// HandleCreateOrder uploads order on behalf of user. Response codes are the same
+// as for orders uploaded by users, 404 is returned for unknown user.
+func (h PartnerHandler) HandleCreateOrder(writer http.ResponseWriter, request *http.Request) {
+	var partnerOrder model.PartnerOrder
+	merchant := middlewares.MerchantFromContext(request.Context())
+	if !parseJSONBody(writer, request, &partnerOrder, "must be valid json object with order and login or loyalty_card") {
+		return
+	}
+
+	log.Infof("creating order with number %s, by merchant %d", partnerOrder.Order, *merchant.ID)
+
+	order, err := h.merchantService.SubmitOrder(*merchant, partnerOrder)
+	if err != nil {
+		switch e := err.(type) {
+		case *errors.ValidationError:
+			log.Error(err)
+			writeValidationError(writer, e)
+		case *errors.UserNotFoundError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusNotFound)
+		case *errors.OrderAlreadyAcceptedDifferentUserError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusConflict)
+		case *errors.OrderAlreadyAcceptedCurrentUserError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusOK)
+		case *errors.OrderFormatError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusUnprocessableEntity)
+		default:
+			log.Error("error creating partner order ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	writeJSON(writer, http.StatusAccepted, order)
+}
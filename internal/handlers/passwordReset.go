@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"io"
+	"net/http"
+)
+
+type PasswordResetHandler struct {
+	passwordReset service.PasswordReset
+}
+
+func NewPasswordResetHandler(passwordReset *service.PasswordReset) PasswordResetHandler {
+	return PasswordResetHandler{passwordReset: *passwordReset}
+}
+
+// HandleRequestReset always answers 202 for a valid request, whether login exists or not,
+// and 429 when too many resets are requested for login or from client address.
+func (h PasswordResetHandler) HandleRequestReset(writer http.ResponseWriter, request *http.Request) {
+	var resetRequest model.PasswordResetRequest
+
+	data, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxUserBodySize))
+	if err == nil {
+		err = json.Unmarshal(data, &resetRequest)
+	}
+	if err != nil {
+		log.Error(err)
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("body", "must be valid json object with login")
+		writeValidationError(writer, validationErr)
+		return
+	}
+
+	err = h.passwordReset.RequestReset(resetRequest.Login, clientIP(request))
+	if err != nil {
+		switch e := err.(type) {
+		case *errors.ValidationError:
+			log.Error(err)
+			writeValidationError(writer, e)
+			return
+		case *errors.LoginLockedError:
+			log.Warn(err)
+			setRetryAfter(writer, e.RetryAfter)
+			writer.WriteHeader(http.StatusTooManyRequests)
+			return
+		default:
+			log.Error("error requesting password reset ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+func (h PasswordResetHandler) HandleResetPassword(writer http.ResponseWriter, request *http.Request) {
+	var reset model.PasswordReset
+
+	data, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxUserBodySize))
+	if err == nil {
+		err = json.Unmarshal(data, &reset)
+	}
+	if err != nil {
+		log.Error(err)
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("body", "must be valid json object with token and new_password")
+		writeValidationError(writer, validationErr)
+		return
+	}
+
+	err = h.passwordReset.ResetPassword(reset)
+	if err != nil {
+		switch e := err.(type) {
+		case *errors.ValidationError:
+			log.Error(err)
+			writeValidationError(writer, e)
+			return
+		case *errors.InvalidTokenError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		default:
+			log.Error("error resetting password ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	writer.WriteHeader(http.StatusOK)
+}
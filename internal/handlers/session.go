@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"net/http"
+)
+
+type SessionHandler struct {
+	tokenService service.Token
+	cfg          *config.ServerConfig
+}
+
+func NewSessionHandler(tokenService *service.Token, cfg *config.ServerConfig) SessionHandler {
+	return SessionHandler{
+		tokenService: *tokenService,
+		cfg:          cfg,
+	}
+}
+
+func (h SessionHandler) HandleGetSessions(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	_, sessionID, _ := GetSessionFromToken(request.Context())
+	sessions, err := h.tokenService.ListSessions(userID, sessionID)
+	if err != nil {
+		log.Error("error getting sessions ", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(writer, http.StatusOK, sessions)
+}
+
+// HandleDeleteSession signs user out of any of their sessions. Deleting current session
+// works as logout and clears token cookies.
+func (h SessionHandler) HandleDeleteSession(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	_, currentSessionID, _ := GetSessionFromToken(request.Context())
+	sessionID, ok := parseIntParam(writer, request, "id")
+	if !ok {
+		return
+	}
+	err := h.tokenService.RevokeSession(userID, sessionID)
+	if err != nil {
+		switch err.(type) {
+		case *errors.SessionNotFoundError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusNotFound)
+		default:
+			log.Error("error deleting session ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	log.Infof("session %d of user %d deleted", sessionID, userID)
+	if sessionID == currentSessionID {
+		clearTokens(writer, h.cfg)
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"io"
+	"net/http"
+)
+
+// TOTPHeaderName carries TOTP code confirming sensitive operations, like large withdrawals.
+const TOTPHeaderName = "X-TOTP-Code"
+
+type TwoFactorHandler struct {
+	twoFactor service.TwoFactor
+}
+
+func NewTwoFactorHandler(twoFactor *service.TwoFactor) TwoFactorHandler {
+	return TwoFactorHandler{twoFactor: *twoFactor}
+}
+
+func (h TwoFactorHandler) HandleEnroll(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	enrollment, err := h.twoFactor.Enroll(userID)
+	if err != nil {
+		switch err.(type) {
+		case *errors.TwoFactorAlreadyEnabledError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusConflict)
+			return
+		default:
+			log.Error("error enrolling two-factor authentication ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	body, err := json.Marshal(enrollment)
+	if err != nil {
+		log.Error("error marshalling to json", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Add("Content-Type", "application/json")
+	writer.Write(body)
+}
+
+func (h TwoFactorHandler) HandleConfirm(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	code, err := h.parseForCode(writer, request)
+	if err != nil {
+		log.Error(err)
+		writeValidationError(writer, err.(*errors.ValidationError))
+		return
+	}
+	recoveryCodes, err := h.twoFactor.Confirm(userID, code)
+	if err != nil {
+		switch err.(type) {
+		case *errors.TwoFactorAlreadyEnabledError, *errors.TwoFactorNotEnrolledError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusConflict)
+			return
+		case *errors.InvalidTwoFactorCodeError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusForbidden)
+			return
+		default:
+			log.Error("error confirming two-factor authentication ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	body, err := json.Marshal(recoveryCodes)
+	if err != nil {
+		log.Error("error marshalling to json", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Add("Content-Type", "application/json")
+	writer.Write(body)
+}
+
+func (h TwoFactorHandler) HandleDisable(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	code, err := h.parseForCode(writer, request)
+	if err != nil {
+		log.Error(err)
+		writeValidationError(writer, err.(*errors.ValidationError))
+		return
+	}
+	err = h.twoFactor.Disable(userID, code)
+	if err != nil {
+		switch e := err.(type) {
+		case *errors.TwoFactorNotEnrolledError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusConflict)
+			return
+		case *errors.InvalidTwoFactorCodeError:
+			log.Error(err)
+			writer.WriteHeader(http.StatusForbidden)
+			return
+		case *errors.LoginLockedError:
+			log.Warn(err)
+			setRetryAfter(writer, e.RetryAfter)
+			writer.WriteHeader(http.StatusTooManyRequests)
+			return
+		default:
+			log.Error("error disabling two-factor authentication ", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h TwoFactorHandler) parseForCode(writer http.ResponseWriter, request *http.Request) (string, error) {
+	var code model.TwoFactorCode
+
+	data, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxUserBodySize))
+	if err == nil {
+		err = json.Unmarshal(data, &code)
+	}
+	if err != nil || code.Code == "" {
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("code", "must be set")
+		return "", validationErr
+	}
+	return code.Code, nil
+}
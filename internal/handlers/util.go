@@ -2,31 +2,143 @@ package handlers
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"github.com/go-chi/jwtauth/v5"
 	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/middlewares"
 	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 )
 
-func SetToken(writer http.ResponseWriter, user model.User, auth *jwtauth.JWTAuth) http.ResponseWriter {
-	claims := map[string]interface{}{
-		"user_id": *user.ID,
+const (
+	refreshTokenHeader     = "Refresh-Token"
+	RefreshTokenCookie     = "refresh_token"
+	refreshTokenCookiePath = "/api/user/token"
+)
+
+// SetToken issues tokens for user and writes them to response headers and cookies. Error is
+// already logged, caller only has to answer with 500.
+func SetToken(
+	writer http.ResponseWriter,
+	request *http.Request,
+	user model.User,
+	tokenService service.Token,
+	cfg *config.ServerConfig,
+) error {
+	tokens, err := tokenService.IssueTokens(user, model.ClientInfo{
+		UserAgent: request.UserAgent(),
+		IP:        clientIP(request),
+	})
+	if err != nil {
+		log.Error("error setting token for user:", err)
+		return err
 	}
-	currentTime := time.Now()
+	return writeTokens(writer, tokens, cfg)
+}
 
-	jwtauth.SetIssuedAt(claims, currentTime)
-	jwtauth.SetExpiry(claims, currentTime.Add(5*time.Minute))
-	_, token, err := auth.Encode(claims)
+func writeTokens(writer http.ResponseWriter, tokens *model.Tokens, cfg *config.ServerConfig) error {
+	csrfToken, err := newCSRFToken()
+	if err != nil {
+		log.Error("error generating csrf token:", err)
+		return err
+	}
+
+	writer.Header().Add("jwt", tokens.AccessToken)
+	writer.Header().Add(refreshTokenHeader, tokens.RefreshToken)
+	writer.Header().Add(middlewares.CSRFHeaderName, csrfToken)
+
+	accessCookie := newCookie(cfg, cfg.CookieName, tokens.AccessToken, cfg.AccessTokenTTL)
+	accessCookie.HttpOnly = cfg.CookieHTTPOnly
+	http.SetCookie(writer, accessCookie)
 
+	refreshCookie := newCookie(cfg, RefreshTokenCookie, tokens.RefreshToken, cfg.RefreshTokenTTL)
+	refreshCookie.Path = refreshTokenCookiePath
+	refreshCookie.HttpOnly = true
+	http.SetCookie(writer, refreshCookie)
+
+	// csrf cookie is read by javascript to be sent back in header
+	csrfCookie := newCookie(cfg, middlewares.CSRFCookieName, csrfToken, cfg.RefreshTokenTTL)
+	csrfCookie.Path = "/"
+	http.SetCookie(writer, csrfCookie)
+	return nil
+}
+
+func clearTokens(writer http.ResponseWriter, cfg *config.ServerConfig) {
+	accessCookie := newCookie(cfg, cfg.CookieName, "", 0)
+	accessCookie.MaxAge = -1
+	http.SetCookie(writer, accessCookie)
+
+	refreshCookie := newCookie(cfg, RefreshTokenCookie, "", 0)
+	refreshCookie.Path = refreshTokenCookiePath
+	refreshCookie.MaxAge = -1
+	http.SetCookie(writer, refreshCookie)
+
+	csrfCookie := newCookie(cfg, middlewares.CSRFCookieName, "", 0)
+	csrfCookie.Path = "/"
+	csrfCookie.MaxAge = -1
+	http.SetCookie(writer, csrfCookie)
+}
+
+func newCookie(cfg *config.ServerConfig, name string, value string, ttl time.Duration) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     cfg.CookiePath,
+		Domain:   cfg.CookieDomain,
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   cfg.CookieSecure,
+		SameSite: cfg.SameSite(),
+	}
+}
+
+func newCSRFToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// clientIP returns address of request sender. When proxy headers are trusted,
+// middleware.RealIP has already put client address to RemoteAddr.
+func clientIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
 	if err != nil {
-		log.Error("error setting token for user:", err)
-		return writer
+		return request.RemoteAddr
 	}
+	return host
+}
+
+func setRetryAfter(writer http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	writer.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
 
-	writer.Header().Add("jwt", token)
-	writer.Header().Add("Set-Cookie", "jwt="+token)
-	return writer
+func writeValidationError(writer http.ResponseWriter, validationErr *errors.ValidationError) {
+	body, err := json.Marshal(struct {
+		Error  string              `json:"error"`
+		Fields []errors.FieldError `json:"fields"`
+	}{
+		Error:  "validation_failed",
+		Fields: validationErr.Fields,
+	})
+	if err != nil {
+		log.Error("error marshalling to json", err)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	writer.Header().Add("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusBadRequest)
+	writer.Write(body)
 }
 
 func GetUserIDFromToken(ctx context.Context) int {
@@ -37,3 +149,42 @@ func GetUserIDFromToken(ctx context.Context) int {
 	userID := claims["user_id"].(float64)
 	return int(userID)
 }
+
+// GetSessionFromToken returns jti, session id and expiration time of current access token.
+func GetSessionFromToken(ctx context.Context) (string, int, time.Time) {
+	token, claims, err := jwtauth.FromContext(ctx)
+	if err != nil {
+		log.Error(err)
+	}
+	jti, _ := claims["jti"].(string)
+	sessionID, _ := claims["sid"].(float64)
+	var expiresAt time.Time
+	if token != nil {
+		expiresAt = token.Expiration()
+	}
+	return jti, int(sessionID), expiresAt
+}
+
+// GetActorIDFromToken returns id of authenticated user, or nil when request was
+// authorized otherwise, e.g. by admin token.
+func GetActorIDFromToken(ctx context.Context) *int {
+	_, claims, _ := jwtauth.FromContext(ctx)
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil
+	}
+	actorID := int(userID)
+	return &actorID
+}
+
+func writeJSON(writer http.ResponseWriter, status int, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		log.Error("error marshalling to json", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Add("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	writer.Write(body)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"net/http"
+)
+
+type WebhookHandler struct {
+	webhookService service.Webhook
+}
+
+func NewWebhookHandler(webhookService *service.Webhook) WebhookHandler {
+	return WebhookHandler{webhookService: *webhookService}
+}
+
+// HandleCreateWebhook subscribes user to events. Response contains the secret used to sign
+// deliveries, it is not shown again.
+func (h WebhookHandler) HandleCreateWebhook(writer http.ResponseWriter, request *http.Request) {
+	var webhook model.Webhook
+	userID := GetUserIDFromToken(request.Context())
+	if !parseJSONBody(writer, request, &webhook, "must be valid json object with url and event_types") {
+		return
+	}
+	created, err := h.webhookService.CreateWebhook(userID, webhook)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Infof("webhook %d created by user %d", *created.ID, userID)
+	writeJSON(writer, http.StatusCreated, created)
+}
+
+func (h WebhookHandler) HandleGetWebhooks(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	webhooks, err := h.webhookService.ListWebhooks(userID)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, webhooks)
+}
+
+func (h WebhookHandler) HandleDeleteWebhook(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	webhookID, ok := parseIntParam(writer, request, "id")
+	if !ok {
+		return
+	}
+	err := h.webhookService.DeleteWebhook(userID, webhookID)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Infof("webhook %d of user %d deleted", webhookID, userID)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h WebhookHandler) HandleGetDeliveries(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	webhookID, ok := parseIntParam(writer, request, "id")
+	if !ok {
+		return
+	}
+	deliveries, err := h.webhookService.ListDeliveries(userID, webhookID)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, deliveries)
+}
+
+// HandleReplayDelivery schedules failed delivery to be sent again, it is attempted asynchronously.
+func (h WebhookHandler) HandleReplayDelivery(writer http.ResponseWriter, request *http.Request) {
+	userID := GetUserIDFromToken(request.Context())
+	webhookID, ok := parseIntParam(writer, request, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseIntParam(writer, request, "deliveryID")
+	if !ok {
+		return
+	}
+	err := h.webhookService.ReplayDelivery(userID, webhookID, deliveryID)
+	if err != nil {
+		h.writeError(writer, err)
+		return
+	}
+	log.Infof("delivery %d of webhook %d replayed by user %d", deliveryID, webhookID, userID)
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+func (h WebhookHandler) writeError(writer http.ResponseWriter, err error) {
+	switch e := err.(type) {
+	case *errors.ValidationError:
+		log.Error(err)
+		writeValidationError(writer, e)
+	case *errors.WebhookNotFoundError:
+		log.Error(err)
+		writer.WriteHeader(http.StatusNotFound)
+	case *errors.WebhookDeliveryNotReplayableError:
+		log.Error(err)
+		writer.WriteHeader(http.StatusConflict)
+	default:
+		log.Error("error during webhook action ", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,198 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"os"
+)
+
+// KeySet signs tokens with a single private key and verifies them with any of
+// the active public keys, selecting one by the "kid" header. Key id is RFC 7638
+// thumbprint of the public key, so it is stable between restarts and replicas.
+type KeySet struct {
+	signKey    jwk.Key
+	publicKeys jwk.Set
+}
+
+// LoadKeySet reads PEM encoded signing private key and optional additional keys,
+// which are accepted for verification only. Keys of previous rotations should be
+// kept in verifyKeyFiles until all access tokens signed by them have expired.
+func LoadKeySet(signingKeyFile string, verifyKeyFiles []string) (*KeySet, error) {
+	signKey, err := readKey(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	var verifyKeys []interface{}
+	for _, file := range verifyKeyFiles {
+		if file == "" {
+			continue
+		}
+		key, err := readKey(file)
+		if err != nil {
+			return nil, err
+		}
+		verifyKeys = append(verifyKeys, key)
+	}
+	return NewKeySet(signKey, verifyKeys...)
+}
+
+// GenerateKeySet creates key set with ephemeral Ed25519 key. Tokens signed by it
+// become invalid after restart, so it is suitable only for development.
+func GenerateKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(private)
+}
+
+func NewKeySet(signKey interface{}, verifyKeys ...interface{}) (*KeySet, error) {
+	private, err := newKey(signKey)
+	if err != nil {
+		return nil, err
+	}
+	keySet := &KeySet{
+		signKey:    private,
+		publicKeys: jwk.NewSet(),
+	}
+	err = keySet.addPublicKey(private)
+	if err != nil {
+		return nil, err
+	}
+	for _, raw := range verifyKeys {
+		key, err := newKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		err = keySet.addPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keySet, nil
+}
+
+func (k *KeySet) Encode(claims map[string]interface{}) (string, error) {
+	token := jwt.New()
+	for name, value := range claims {
+		err := token.Set(name, value)
+		if err != nil {
+			return "", err
+		}
+	}
+	signed, err := jwt.Sign(token, jwa.SignatureAlgorithm(k.signKey.Algorithm()), k.signKey)
+	if err != nil {
+		return "", err
+	}
+	return string(signed), nil
+}
+
+// Decode verifies token signature and parses it. Registered claims, like exp,
+// are not validated here.
+func (k *KeySet) Decode(tokenString string) (jwt.Token, error) {
+	return jwt.ParseString(tokenString, jwt.WithKeySet(k.publicKeys))
+}
+
+// PublicKeys returns set of all active public keys to be published as JWKS.
+func (k *KeySet) PublicKeys() jwk.Set {
+	return k.publicKeys
+}
+
+func (k *KeySet) addPublicKey(key jwk.Key) error {
+	public, err := jwk.PublicKeyOf(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := k.publicKeys.LookupKeyID(public.KeyID()); ok {
+		return nil
+	}
+	k.publicKeys.Add(public)
+	return nil
+}
+
+func newKey(raw interface{}) (jwk.Key, error) {
+	alg, err := algorithmOf(raw)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwk.New(raw)
+	if err != nil {
+		return nil, err
+	}
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range map[string]interface{}{
+		jwk.KeyIDKey:     base64.RawURLEncoding.EncodeToString(thumbprint),
+		jwk.AlgorithmKey: alg,
+		jwk.KeyUsageKey:  jwk.ForSignature,
+	} {
+		if err := key.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+func algorithmOf(key interface{}) (jwa.SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return jwa.RS256, nil
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return jwa.EdDSA, nil
+	case *ecdsa.PrivateKey:
+		return ecdsaAlgorithm(k.Curve)
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(k.Curve)
+	default:
+		return "", fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+func ecdsaAlgorithm(curve elliptic.Curve) (jwa.SignatureAlgorithm, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwa.ES256, nil
+	case elliptic.P384():
+		return jwa.ES384, nil
+	case elliptic.P521():
+		return jwa.ES512, nil
+	default:
+		return "", fmt.Errorf("unsupported curve %s", curve.Params().Name)
+	}
+}
+
+func readKey(file string) (interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", file)
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %s in %s", block.Type, file)
+	}
+}
@@ -0,0 +1,95 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePEM(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "key.pem")
+	err = os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadKeySet(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name    string
+		signKey interface{}
+	}{
+		{
+			name:    "should sign and verify with RSA key",
+			signKey: rsaKey,
+		},
+		{
+			name:    "should sign and verify with Ed25519 key",
+			signKey: edKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keySet, err := LoadKeySet(writePEM(t, tt.signKey), nil)
+			assert.NoError(t, err)
+
+			tokenString, err := keySet.Encode(map[string]interface{}{"user_id": 1})
+			assert.NoError(t, err)
+			token, err := keySet.Decode(tokenString)
+			assert.NoError(t, err)
+			userID, _ := token.Get("user_id")
+			assert.Equal(t, float64(1), userID)
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	_, oldKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, newKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	oldKeySet, _ := NewKeySet(oldKey)
+	tokenString, err := oldKeySet.Encode(map[string]interface{}{"user_id": 1})
+	assert.NoError(t, err)
+
+	rotated, err := NewKeySet(newKey, oldKey)
+	assert.NoError(t, err)
+	_, err = rotated.Decode(tokenString)
+	assert.NoError(t, err, "token of previous key must be accepted")
+	assert.Equal(t, 2, rotated.PublicKeys().Len())
+
+	withoutOld, _ := NewKeySet(newKey)
+	_, err = withoutOld.Decode(tokenString)
+	assert.Error(t, err, "token of removed key must be rejected")
+}
+
+func TestKeySet_PublicKeys(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keySet, _ := NewKeySet(rsaKey)
+
+	body, err := json.Marshal(keySet.PublicKeys())
+	assert.NoError(t, err)
+
+	var jwks struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &jwks))
+	assert.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "RS256", jwks.Keys[0]["alg"])
+	assert.NotEmpty(t, jwks.Keys[0]["kid"])
+	assert.Nil(t, jwks.Keys[0]["d"], "private part must not be published")
+}
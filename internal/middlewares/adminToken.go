@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// AdminToken allows request only with X-Admin-Token header equal to token.
+// Empty token disables all routes behind the middleware.
+func AdminToken(token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			header := r.Header.Get("X-Admin-Token")
+			if subtle.ConstantTimeCompare([]byte(header), []byte(token)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
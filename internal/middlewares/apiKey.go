@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"net/http"
+)
+
+const APIKeyHeaderName = "X-API-Key"
+
+type merchantContextKey struct{}
+
+// APIKey authenticates merchant by X-API-Key header. It responds with 401 for unknown
+// or revoked keys and with 403 for keys without scope. Merchant of the key is stored
+// in request context.
+func APIKey(merchantService service.Merchant, scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key, err := merchantService.Authenticate(r.Header.Get(APIKeyHeaderName))
+			if err != nil {
+				switch err.(type) {
+				case *errors.InvalidAPIKeyError:
+					w.WriteHeader(http.StatusUnauthorized)
+				default:
+					log.Error("cannot check api key: ", err)
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+			if !key.HasScope(scope) {
+				log.Warnf("api key %s of merchant %d has no scope %s", key.Prefix, *key.Merchant.ID, scope)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			ctx := context.WithValue(r.Context(), merchantContextKey{}, key.Merchant)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// MerchantFromContext returns merchant authenticated by APIKey middleware.
+func MerchantFromContext(ctx context.Context) *model.Merchant {
+	merchant, _ := ctx.Value(merchantContextKey{}).(*model.Merchant)
+	return merchant
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+const (
+	CSRFCookieName = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// CSRF implements double-submit cookie protection. State-changing requests
+// authenticated by one of authCookies must repeat value of csrf cookie in
+// X-CSRF-Token header. Requests with Authorization header are not affected,
+// as browsers never add it on their own.
+func CSRF(authCookies ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.Header.Get("Authorization") != "" || !hasAnyCookie(r, authCookies) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cookie, err := r.Cookie(CSRFCookieName)
+			header := r.Header.Get(CSRFHeaderName)
+			if err != nil || cookie.Value == "" || header == "" ||
+				subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) != 1 {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func hasAnyCookie(r *http.Request, names []string) bool {
+	for _, name := range names {
+		if _, err := r.Cookie(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
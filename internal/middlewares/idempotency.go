@@ -0,0 +1,155 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/go-chi/jwtauth/v5"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"io"
+	"net/http"
+)
+
+const (
+	IdempotencyKeyHeaderName = "Idempotency-Key"
+	// IdempotentReplayHeaderName marks response replayed from the first request with the same key.
+	IdempotentReplayHeaderName = "Idempotent-Replayed"
+	maxIdempotentBodySize      = 1 << 20
+)
+
+type idempotencyKeyContextKey struct{}
+
+// Idempotency must be placed after authentication. Request with Idempotency-Key header is
+// executed once per user and key, its response is replayed for retries. Retry with the same key
+// but different request is rejected with 422, retry while the first request is still in progress
+// with 409. Server errors, 403 responses, e.g. missing two-factor code, and 429 responses are
+// not stored, so such request can be retried with the same key. Key is also released, when handler
+// panics. Lease of the key is renewed while handler runs, so retry takes it over only when instance
+// crashed meanwhile. Attempt is stored in request context, see IdempotencyKeyFromContext.
+func Idempotency(idempotencyService service.Idempotency) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeaderName)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			_, claims, _ := jwtauth.FromContext(r.Context())
+			userID, ok := claims["user_id"].(float64)
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+			if err != nil {
+				log.Error(err)
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			attempt, err := idempotencyService.Begin(int(userID), key, requestFingerprint(r, body))
+			if err != nil {
+				switch err.(type) {
+				case *errors.ValidationError:
+					log.Error(err)
+					w.WriteHeader(http.StatusBadRequest)
+				case *errors.IdempotencyKeyMismatchError:
+					log.Error(err)
+					w.WriteHeader(http.StatusUnprocessableEntity)
+				case *errors.IdempotencyKeyInProgressError:
+					log.Error(err)
+					w.WriteHeader(http.StatusConflict)
+				default:
+					log.Error("cannot check idempotency key: ", err)
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+			if attempt.CompletedAt != nil {
+				log.Infof("replaying response for idempotency key %s of user %d", key, int(userID))
+				if attempt.ContentType != "" {
+					w.Header().Set("Content-Type", attempt.ContentType)
+				}
+				w.Header().Set(IdempotentReplayHeaderName, "true")
+				w.WriteHeader(attempt.StatusCode)
+				w.Write(attempt.Body)
+				return
+			}
+
+			stopLease := idempotencyService.KeepLease(attempt)
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					stopLease()
+					if err := idempotencyService.Release(attempt); err != nil {
+						log.Error("cannot release idempotency key: ", err)
+					}
+					panic(recovered)
+				}
+			}()
+			recorder := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), idempotencyKeyContextKey{}, attempt)))
+			stopLease()
+			status := recorder.statusCode()
+			if status >= http.StatusInternalServerError ||
+				status == http.StatusForbidden ||
+				status == http.StatusTooManyRequests {
+				err = idempotencyService.Release(attempt)
+			} else {
+				err = idempotencyService.Complete(attempt, status, w.Header().Get("Content-Type"), recorder.body.Bytes())
+			}
+			if err != nil {
+				log.Error("cannot store response for idempotency key: ", err)
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// IdempotencyKeyFromContext returns attempt of request reserved by Idempotency middleware,
+// nil when request has no Idempotency-Key header.
+func IdempotencyKeyFromContext(ctx context.Context) *model.IdempotencyKey {
+	attempt, _ := ctx.Value(idempotencyKeyContextKey{}).(*model.IdempotencyKey)
+	return attempt
+}
+
+// requestFingerprint identifies request by method, path and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder passes response to client, keeping its copy.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"github.com/go-chi/jwtauth/v5"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/service"
+	"net/http"
+)
+
+// RejectRevoked must be placed after jwtauth.Authenticator. It responds with 401
+// for tokens revoked on logout or issued for a session which was terminated.
+func RejectRevoked(tokenService service.Token) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			_, claims, _ := jwtauth.FromContext(r.Context())
+			jti, _ := claims["jti"].(string)
+			sessionID, ok := claims["sid"].(float64)
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			revoked, err := tokenService.IsRevoked(jti, int(sessionID))
+			if err != nil {
+				log.Error("cannot check token revocation: ", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"github.com/go-chi/jwtauth/v5"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"net/http"
+)
+
+// RequireRole must be placed after jwtauth.Authenticator. It responds with 403
+// unless role claim of the token is one of roles. Tokens without role claim
+// belong to ordinary users.
+func RequireRole(roles ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			_, claims, _ := jwtauth.FromContext(r.Context())
+			role, _ := claims["role"].(string)
+			if role == "" {
+				role = model.RoleUser
+			}
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			log.Warnf("access to %s denied for role %s", r.URL.Path, role)
+			w.WriteHeader(http.StatusForbidden)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/yurchenkosv/gofermart/internal/keys"
+	"net/http"
+)
+
+// Verifier works like jwtauth.Verifier, but checks token signature against any
+// of the active keys of key set. Token is taken from Authorization header or from
+// cookieName cookie. Result is stored in request context in jwtauth format, so
+// jwtauth.Authenticator and jwtauth.FromContext can be used after it.
+func Verifier(keySet *keys.KeySet, cookieName string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			token, err := verifyRequest(keySet, cookieName, r)
+			ctx := jwtauth.NewContext(r.Context(), token, err)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func verifyRequest(keySet *keys.KeySet, cookieName string, r *http.Request) (jwt.Token, error) {
+	tokenString := jwtauth.TokenFromHeader(r)
+	if tokenString == "" {
+		if cookie, err := r.Cookie(cookieName); err == nil {
+			tokenString = cookie.Value
+		}
+	}
+	if tokenString == "" {
+		return nil, jwtauth.ErrNoTokenFound
+	}
+
+	token, err := keySet.Decode(tokenString)
+	if err != nil {
+		return token, jwtauth.ErrorReason(err)
+	}
+	if err := jwt.Validate(token); err != nil {
+		return token, jwtauth.ErrorReason(err)
+	}
+	return token, nil
+}
@@ -7,6 +7,7 @@ package mock_dao
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	dao "github.com/yurchenkosv/gofermart/internal/dao"
@@ -36,6 +37,20 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AnonymizeUser mocks base method.
+func (m *MockRepository) AnonymizeUser(userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeUser", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AnonymizeUser indicates an expected call of AnonymizeUser.
+func (mr *MockRepositoryMockRecorder) AnonymizeUser(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeUser", reflect.TypeOf((*MockRepository)(nil).AnonymizeUser), userID)
+}
+
 // Atomic mocks base method.
 func (m *MockRepository) Atomic(ctx context.Context, fn func(dao.Repository) error) error {
 	m.ctrl.T.Helper()
@@ -50,108 +65,974 @@ func (mr *MockRepositoryMockRecorder) Atomic(ctx, fn interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Atomic", reflect.TypeOf((*MockRepository)(nil).Atomic), ctx, fn)
 }
 
-// GetBalanceByUserID mocks base method.
-func (m *MockRepository) GetBalanceByUserID(userID int) (*model.Balance, error) {
+// ClaimWebhookDeliveries mocks base method.
+func (m *MockRepository) ClaimWebhookDeliveries(limit int, leaseUntil time.Time) ([]model.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWebhookDeliveries", limit, leaseUntil)
+	ret0, _ := ret[0].([]model.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWebhookDeliveries indicates an expected call of ClaimWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) ClaimWebhookDeliveries(limit, leaseUntil interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).ClaimWebhookDeliveries), limit, leaseUntil)
+}
+
+// CompleteIdempotencyKey mocks base method.
+func (m *MockRepository) CompleteIdempotencyKey(key *model.IdempotencyKey) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteIdempotencyKey", key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteIdempotencyKey indicates an expected call of CompleteIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) CompleteIdempotencyKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).CompleteIdempotencyKey), key)
+}
+
+// CountLoginAttempt mocks base method.
+func (m *MockRepository) CountLoginAttempt(key string, policy model.LoginAttemptPolicy) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountLoginAttempt", key, policy)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountLoginAttempt indicates an expected call of CountLoginAttempt.
+func (mr *MockRepositoryMockRecorder) CountLoginAttempt(key, policy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountLoginAttempt", reflect.TypeOf((*MockRepository)(nil).CountLoginAttempt), key, policy)
+}
+
+// CountRejectedStatusUpdates mocks base method.
+func (m *MockRepository) CountRejectedStatusUpdates() (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountRejectedStatusUpdates")
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountRejectedStatusUpdates indicates an expected call of CountRejectedStatusUpdates.
+func (mr *MockRepositoryMockRecorder) CountRejectedStatusUpdates() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountRejectedStatusUpdates", reflect.TypeOf((*MockRepository)(nil).CountRejectedStatusUpdates))
+}
+
+// CreateIdempotencyKey mocks base method.
+func (m *MockRepository) CreateIdempotencyKey(key *model.IdempotencyKey) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIdempotencyKey", key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateIdempotencyKey indicates an expected call of CreateIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) CreateIdempotencyKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).CreateIdempotencyKey), key)
+}
+
+// DeleteExpiredIdempotencyKeys mocks base method.
+func (m *MockRepository) DeleteExpiredIdempotencyKeys() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyKeys")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredIdempotencyKeys indicates an expected call of DeleteExpiredIdempotencyKeys.
+func (mr *MockRepositoryMockRecorder) DeleteExpiredIdempotencyKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyKeys", reflect.TypeOf((*MockRepository)(nil).DeleteExpiredIdempotencyKeys))
+}
+
+// DeleteExpiredTokens mocks base method.
+func (m *MockRepository) DeleteExpiredTokens() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredTokens")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredTokens indicates an expected call of DeleteExpiredTokens.
+func (mr *MockRepositoryMockRecorder) DeleteExpiredTokens() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredTokens", reflect.TypeOf((*MockRepository)(nil).DeleteExpiredTokens))
+}
+
+// DeleteIdempotencyKey mocks base method.
+func (m *MockRepository) DeleteIdempotencyKey(key *model.IdempotencyKey) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyKey", key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteIdempotencyKey indicates an expected call of DeleteIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) DeleteIdempotencyKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).DeleteIdempotencyKey), key)
+}
+
+// DeleteLoginAttempt mocks base method.
+func (m *MockRepository) DeleteLoginAttempt(key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLoginAttempt", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLoginAttempt indicates an expected call of DeleteLoginAttempt.
+func (mr *MockRepositoryMockRecorder) DeleteLoginAttempt(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLoginAttempt", reflect.TypeOf((*MockRepository)(nil).DeleteLoginAttempt), key)
+}
+
+// DeleteOIDCIdentities mocks base method.
+func (m *MockRepository) DeleteOIDCIdentities(userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOIDCIdentities", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOIDCIdentities indicates an expected call of DeleteOIDCIdentities.
+func (mr *MockRepositoryMockRecorder) DeleteOIDCIdentities(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOIDCIdentities", reflect.TypeOf((*MockRepository)(nil).DeleteOIDCIdentities), userID)
+}
+
+// DeleteTwoFactor mocks base method.
+func (m *MockRepository) DeleteTwoFactor(userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTwoFactor", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTwoFactor indicates an expected call of DeleteTwoFactor.
+func (mr *MockRepositoryMockRecorder) DeleteTwoFactor(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTwoFactor", reflect.TypeOf((*MockRepository)(nil).DeleteTwoFactor), userID)
+}
+
+// DeleteUnpolledOrder mocks base method.
+func (m *MockRepository) DeleteUnpolledOrder(orderID, userID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUnpolledOrder", orderID, userID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUnpolledOrder indicates an expected call of DeleteUnpolledOrder.
+func (mr *MockRepositoryMockRecorder) DeleteUnpolledOrder(orderID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUnpolledOrder", reflect.TypeOf((*MockRepository)(nil).DeleteUnpolledOrder), orderID, userID)
+}
+
+// DeleteUserData mocks base method.
+func (m *MockRepository) DeleteUserData(userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserData", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserData indicates an expected call of DeleteUserData.
+func (mr *MockRepositoryMockRecorder) DeleteUserData(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserData", reflect.TypeOf((*MockRepository)(nil).DeleteUserData), userID)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockRepository) DeleteWebhook(userID, webhookID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", userID, webhookID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockRepositoryMockRecorder) DeleteWebhook(userID, webhookID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockRepository)(nil).DeleteWebhook), userID, webhookID)
+}
+
+// DeleteWebhooksByUserID mocks base method.
+func (m *MockRepository) DeleteWebhooksByUserID(userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhooksByUserID", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhooksByUserID indicates an expected call of DeleteWebhooksByUserID.
+func (mr *MockRepositoryMockRecorder) DeleteWebhooksByUserID(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhooksByUserID", reflect.TypeOf((*MockRepository)(nil).DeleteWebhooksByUserID), userID)
+}
+
+// ForgiveLoginAttempt mocks base method.
+func (m *MockRepository) ForgiveLoginAttempt(key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgiveLoginAttempt", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForgiveLoginAttempt indicates an expected call of ForgiveLoginAttempt.
+func (mr *MockRepositoryMockRecorder) ForgiveLoginAttempt(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgiveLoginAttempt", reflect.TypeOf((*MockRepository)(nil).ForgiveLoginAttempt), key)
+}
+
+// GetAPIKeyByHash mocks base method.
+func (m *MockRepository) GetAPIKeyByHash(keyHash string) (*model.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByHash", keyHash)
+	ret0, _ := ret[0].(*model.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByHash indicates an expected call of GetAPIKeyByHash.
+func (mr *MockRepositoryMockRecorder) GetAPIKeyByHash(keyHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByHash", reflect.TypeOf((*MockRepository)(nil).GetAPIKeyByHash), keyHash)
+}
+
+// GetAPIKeysByMerchantID mocks base method.
+func (m *MockRepository) GetAPIKeysByMerchantID(merchantID int) ([]model.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeysByMerchantID", merchantID)
+	ret0, _ := ret[0].([]model.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeysByMerchantID indicates an expected call of GetAPIKeysByMerchantID.
+func (mr *MockRepositoryMockRecorder) GetAPIKeysByMerchantID(merchantID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeysByMerchantID", reflect.TypeOf((*MockRepository)(nil).GetAPIKeysByMerchantID), merchantID)
+}
+
+// GetActiveSessionsByUserID mocks base method.
+func (m *MockRepository) GetActiveSessionsByUserID(userID int) ([]model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActiveSessionsByUserID", userID)
+	ret0, _ := ret[0].([]model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActiveSessionsByUserID indicates an expected call of GetActiveSessionsByUserID.
+func (mr *MockRepositoryMockRecorder) GetActiveSessionsByUserID(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveSessionsByUserID", reflect.TypeOf((*MockRepository)(nil).GetActiveSessionsByUserID), userID)
+}
+
+// GetAuditEntries mocks base method.
+func (m *MockRepository) GetAuditEntries(targetUserID *int, limit int) ([]model.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditEntries", targetUserID, limit)
+	ret0, _ := ret[0].([]model.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditEntries indicates an expected call of GetAuditEntries.
+func (mr *MockRepositoryMockRecorder) GetAuditEntries(targetUserID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditEntries", reflect.TypeOf((*MockRepository)(nil).GetAuditEntries), targetUserID, limit)
+}
+
+// GetBalanceByUserID mocks base method.
+func (m *MockRepository) GetBalanceByUserID(userID int) (*model.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceByUserID", userID)
+	ret0, _ := ret[0].(*model.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceByUserID indicates an expected call of GetBalanceByUserID.
+func (mr *MockRepositoryMockRecorder) GetBalanceByUserID(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceByUserID", reflect.TypeOf((*MockRepository)(nil).GetBalanceByUserID), userID)
+}
+
+// GetIdempotencyKey mocks base method.
+func (m *MockRepository) GetIdempotencyKey(userID int, key string) (*model.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyKey", userID, key)
+	ret0, _ := ret[0].(*model.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyKey indicates an expected call of GetIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) GetIdempotencyKey(userID, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).GetIdempotencyKey), userID, key)
+}
+
+// GetLastOrderEventID mocks base method.
+func (m *MockRepository) GetLastOrderEventID(userID int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastOrderEventID", userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLastOrderEventID indicates an expected call of GetLastOrderEventID.
+func (mr *MockRepositoryMockRecorder) GetLastOrderEventID(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastOrderEventID", reflect.TypeOf((*MockRepository)(nil).GetLastOrderEventID), userID)
+}
+
+// GetLoginAttempt mocks base method.
+func (m *MockRepository) GetLoginAttempt(key string) (*model.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginAttempt", key)
+	ret0, _ := ret[0].(*model.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginAttempt indicates an expected call of GetLoginAttempt.
+func (mr *MockRepositoryMockRecorder) GetLoginAttempt(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginAttempt", reflect.TypeOf((*MockRepository)(nil).GetLoginAttempt), key)
+}
+
+// GetLoginChallengeByHash mocks base method.
+func (m *MockRepository) GetLoginChallengeByHash(tokenHash string) (*model.LoginChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginChallengeByHash", tokenHash)
+	ret0, _ := ret[0].(*model.LoginChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginChallengeByHash indicates an expected call of GetLoginChallengeByHash.
+func (mr *MockRepositoryMockRecorder) GetLoginChallengeByHash(tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginChallengeByHash", reflect.TypeOf((*MockRepository)(nil).GetLoginChallengeByHash), tokenHash)
+}
+
+// GetMerchantByID mocks base method.
+func (m *MockRepository) GetMerchantByID(merchantID int) (*model.Merchant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMerchantByID", merchantID)
+	ret0, _ := ret[0].(*model.Merchant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMerchantByID indicates an expected call of GetMerchantByID.
+func (mr *MockRepositoryMockRecorder) GetMerchantByID(merchantID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMerchantByID", reflect.TypeOf((*MockRepository)(nil).GetMerchantByID), merchantID)
+}
+
+// GetMerchantsWithNumberFormat mocks base method.
+func (m *MockRepository) GetMerchantsWithNumberFormat() ([]model.Merchant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMerchantsWithNumberFormat")
+	ret0, _ := ret[0].([]model.Merchant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMerchantsWithNumberFormat indicates an expected call of GetMerchantsWithNumberFormat.
+func (mr *MockRepositoryMockRecorder) GetMerchantsWithNumberFormat() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMerchantsWithNumberFormat", reflect.TypeOf((*MockRepository)(nil).GetMerchantsWithNumberFormat))
+}
+
+// GetOIDCIdentity mocks base method.
+func (m *MockRepository) GetOIDCIdentity(issuer, subject string) (*model.OIDCIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOIDCIdentity", issuer, subject)
+	ret0, _ := ret[0].(*model.OIDCIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOIDCIdentity indicates an expected call of GetOIDCIdentity.
+func (mr *MockRepositoryMockRecorder) GetOIDCIdentity(issuer, subject interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOIDCIdentity", reflect.TypeOf((*MockRepository)(nil).GetOIDCIdentity), issuer, subject)
+}
+
+// GetOrderByNumber mocks base method.
+func (m *MockRepository) GetOrderByNumber(orderNumber string) (*model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderByNumber", orderNumber)
+	ret0, _ := ret[0].(*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderByNumber indicates an expected call of GetOrderByNumber.
+func (mr *MockRepositoryMockRecorder) GetOrderByNumber(orderNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByNumber", reflect.TypeOf((*MockRepository)(nil).GetOrderByNumber), orderNumber)
+}
+
+// GetOrderEvents mocks base method.
+func (m *MockRepository) GetOrderEvents(userID, afterID, limit int) ([]model.OrderEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderEvents", userID, afterID, limit)
+	ret0, _ := ret[0].([]model.OrderEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderEvents indicates an expected call of GetOrderEvents.
+func (mr *MockRepositoryMockRecorder) GetOrderEvents(userID, afterID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderEvents", reflect.TypeOf((*MockRepository)(nil).GetOrderEvents), userID, afterID, limit)
+}
+
+// GetOrderStatusHistory mocks base method.
+func (m *MockRepository) GetOrderStatusHistory(orderID int) ([]model.OrderStatusTransition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderStatusHistory", orderID)
+	ret0, _ := ret[0].([]model.OrderStatusTransition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderStatusHistory indicates an expected call of GetOrderStatusHistory.
+func (mr *MockRepositoryMockRecorder) GetOrderStatusHistory(orderID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderStatusHistory", reflect.TypeOf((*MockRepository)(nil).GetOrderStatusHistory), orderID)
+}
+
+// GetOrdersByUserID mocks base method.
+func (m *MockRepository) GetOrdersByUserID(userID int) ([]model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrdersByUserID", userID)
+	ret0, _ := ret[0].([]model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersByUserID indicates an expected call of GetOrdersByUserID.
+func (mr *MockRepositoryMockRecorder) GetOrdersByUserID(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByUserID", reflect.TypeOf((*MockRepository)(nil).GetOrdersByUserID), userID)
+}
+
+// GetOrdersForStatusUpdate mocks base method.
+func (m *MockRepository) GetOrdersForStatusUpdate() ([]*model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrdersForStatusUpdate")
+	ret0, _ := ret[0].([]*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersForStatusUpdate indicates an expected call of GetOrdersForStatusUpdate.
+func (mr *MockRepositoryMockRecorder) GetOrdersForStatusUpdate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersForStatusUpdate", reflect.TypeOf((*MockRepository)(nil).GetOrdersForStatusUpdate))
+}
+
+// GetOrdersPage mocks base method.
+func (m *MockRepository) GetOrdersPage(userID int, filter model.OrderFilter) ([]model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrdersPage", userID, filter)
+	ret0, _ := ret[0].([]model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersPage indicates an expected call of GetOrdersPage.
+func (mr *MockRepositoryMockRecorder) GetOrdersPage(userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersPage", reflect.TypeOf((*MockRepository)(nil).GetOrdersPage), userID, filter)
+}
+
+// GetRefreshTokenByHash mocks base method.
+func (m *MockRepository) GetRefreshTokenByHash(tokenHash string) (*model.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefreshTokenByHash", tokenHash)
+	ret0, _ := ret[0].(*model.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefreshTokenByHash indicates an expected call of GetRefreshTokenByHash.
+func (mr *MockRepositoryMockRecorder) GetRefreshTokenByHash(tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshTokenByHash", reflect.TypeOf((*MockRepository)(nil).GetRefreshTokenByHash), tokenHash)
+}
+
+// GetSessionByID mocks base method.
+func (m *MockRepository) GetSessionByID(sessionID int) (*model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionByID", sessionID)
+	ret0, _ := ret[0].(*model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionByID indicates an expected call of GetSessionByID.
+func (mr *MockRepositoryMockRecorder) GetSessionByID(sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionByID", reflect.TypeOf((*MockRepository)(nil).GetSessionByID), sessionID)
+}
+
+// GetTwoFactor mocks base method.
+func (m *MockRepository) GetTwoFactor(userID int) (*model.TwoFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTwoFactor", userID)
+	ret0, _ := ret[0].(*model.TwoFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTwoFactor indicates an expected call of GetTwoFactor.
+func (mr *MockRepositoryMockRecorder) GetTwoFactor(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTwoFactor", reflect.TypeOf((*MockRepository)(nil).GetTwoFactor), userID)
+}
+
+// GetUserByID mocks base method.
+func (m *MockRepository) GetUserByID(userID int) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", userID)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockRepositoryMockRecorder) GetUserByID(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockRepository)(nil).GetUserByID), userID)
+}
+
+// GetUserByLogin mocks base method.
+func (m *MockRepository) GetUserByLogin(login string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByLogin", login)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByLogin indicates an expected call of GetUserByLogin.
+func (mr *MockRepositoryMockRecorder) GetUserByLogin(login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByLogin", reflect.TypeOf((*MockRepository)(nil).GetUserByLogin), login)
+}
+
+// GetUserByLoyaltyCard mocks base method.
+func (m *MockRepository) GetUserByLoyaltyCard(loyaltyCard string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByLoyaltyCard", loyaltyCard)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByLoyaltyCard indicates an expected call of GetUserByLoyaltyCard.
+func (mr *MockRepositoryMockRecorder) GetUserByLoyaltyCard(loyaltyCard interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByLoyaltyCard", reflect.TypeOf((*MockRepository)(nil).GetUserByLoyaltyCard), loyaltyCard)
+}
+
+// GetWebhookByID mocks base method.
+func (m *MockRepository) GetWebhookByID(webhookID int) (*model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookByID", webhookID)
+	ret0, _ := ret[0].(*model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookByID indicates an expected call of GetWebhookByID.
+func (mr *MockRepositoryMockRecorder) GetWebhookByID(webhookID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookByID", reflect.TypeOf((*MockRepository)(nil).GetWebhookByID), webhookID)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockRepository) GetWebhookDeliveries(webhookID, limit int) ([]model.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", webhookID, limit)
+	ret0, _ := ret[0].([]model.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) GetWebhookDeliveries(webhookID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).GetWebhookDeliveries), webhookID, limit)
+}
+
+// GetWebhooksByEvent mocks base method.
+func (m *MockRepository) GetWebhooksByEvent(userID int, eventType string) ([]model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooksByEvent", userID, eventType)
+	ret0, _ := ret[0].([]model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooksByEvent indicates an expected call of GetWebhooksByEvent.
+func (mr *MockRepositoryMockRecorder) GetWebhooksByEvent(userID, eventType interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooksByEvent", reflect.TypeOf((*MockRepository)(nil).GetWebhooksByEvent), userID, eventType)
+}
+
+// GetWebhooksByUserID mocks base method.
+func (m *MockRepository) GetWebhooksByUserID(userID int) ([]model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooksByUserID", userID)
+	ret0, _ := ret[0].([]model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooksByUserID indicates an expected call of GetWebhooksByUserID.
+func (mr *MockRepositoryMockRecorder) GetWebhooksByUserID(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooksByUserID", reflect.TypeOf((*MockRepository)(nil).GetWebhooksByUserID), userID)
+}
+
+// GetWithdrawalsByUserID mocks base method.
+func (m *MockRepository) GetWithdrawalsByUserID(userID int) ([]*model.Withdraw, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawalsByUserID", userID)
+	ret0, _ := ret[0].([]*model.Withdraw)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawalsByUserID indicates an expected call of GetWithdrawalsByUserID.
+func (mr *MockRepositoryMockRecorder) GetWithdrawalsByUserID(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawalsByUserID", reflect.TypeOf((*MockRepository)(nil).GetWithdrawalsByUserID), userID)
+}
+
+// IncrementLoginChallengeAttempts mocks base method.
+func (m *MockRepository) IncrementLoginChallengeAttempts(challengeID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementLoginChallengeAttempts", challengeID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementLoginChallengeAttempts indicates an expected call of IncrementLoginChallengeAttempts.
+func (mr *MockRepositoryMockRecorder) IncrementLoginChallengeAttempts(challengeID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementLoginChallengeAttempts", reflect.TypeOf((*MockRepository)(nil).IncrementLoginChallengeAttempts), challengeID)
+}
+
+// InvalidatePasswordResetTokens mocks base method.
+func (m *MockRepository) InvalidatePasswordResetTokens(userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InvalidatePasswordResetTokens", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidatePasswordResetTokens indicates an expected call of InvalidatePasswordResetTokens.
+func (mr *MockRepositoryMockRecorder) InvalidatePasswordResetTokens(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidatePasswordResetTokens", reflect.TypeOf((*MockRepository)(nil).InvalidatePasswordResetTokens), userID)
+}
+
+// IsTokenRevoked mocks base method.
+func (m *MockRepository) IsTokenRevoked(jti string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenRevoked", jti)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenRevoked indicates an expected call of IsTokenRevoked.
+func (mr *MockRepositoryMockRecorder) IsTokenRevoked(jti interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenRevoked", reflect.TypeOf((*MockRepository)(nil).IsTokenRevoked), jti)
+}
+
+// IsWithdrawnByIdempotencyKey mocks base method.
+func (m *MockRepository) IsWithdrawnByIdempotencyKey(keyID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsWithdrawnByIdempotencyKey", keyID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsWithdrawnByIdempotencyKey indicates an expected call of IsWithdrawnByIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) IsWithdrawnByIdempotencyKey(keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsWithdrawnByIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).IsWithdrawnByIdempotencyKey), keyID)
+}
+
+// LockBalanceByUserID mocks base method.
+func (m *MockRepository) LockBalanceByUserID(userID int) (*model.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockBalanceByUserID", userID)
+	ret0, _ := ret[0].(*model.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockBalanceByUserID indicates an expected call of LockBalanceByUserID.
+func (mr *MockRepositoryMockRecorder) LockBalanceByUserID(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockBalanceByUserID", reflect.TypeOf((*MockRepository)(nil).LockBalanceByUserID), userID)
+}
+
+// LockOrderByNumber mocks base method.
+func (m *MockRepository) LockOrderByNumber(orderNumber string) (*model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockOrderByNumber", orderNumber)
+	ret0, _ := ret[0].(*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockOrderByNumber indicates an expected call of LockOrderByNumber.
+func (mr *MockRepositoryMockRecorder) LockOrderByNumber(orderNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockOrderByNumber", reflect.TypeOf((*MockRepository)(nil).LockOrderByNumber), orderNumber)
+}
+
+// MarkOrderPolled mocks base method.
+func (m *MockRepository) MarkOrderPolled(orderNumber string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkOrderPolled", orderNumber)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkOrderPolled indicates an expected call of MarkOrderPolled.
+func (mr *MockRepositoryMockRecorder) MarkOrderPolled(orderNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkOrderPolled", reflect.TypeOf((*MockRepository)(nil).MarkOrderPolled), orderNumber)
+}
+
+// MarkRefreshTokenUsed mocks base method.
+func (m *MockRepository) MarkRefreshTokenUsed(tokenID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkRefreshTokenUsed", tokenID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkRefreshTokenUsed indicates an expected call of MarkRefreshTokenUsed.
+func (mr *MockRepositoryMockRecorder) MarkRefreshTokenUsed(tokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkRefreshTokenUsed", reflect.TypeOf((*MockRepository)(nil).MarkRefreshTokenUsed), tokenID)
+}
+
+// RenewIdempotencyKey mocks base method.
+func (m *MockRepository) RenewIdempotencyKey(key *model.IdempotencyKey) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenewIdempotencyKey", key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RenewIdempotencyKey indicates an expected call of RenewIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) RenewIdempotencyKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenewIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).RenewIdempotencyKey), key)
+}
+
+// ReplayWebhookDelivery mocks base method.
+func (m *MockRepository) ReplayWebhookDelivery(webhookID, deliveryID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayWebhookDelivery", webhookID, deliveryID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplayWebhookDelivery indicates an expected call of ReplayWebhookDelivery.
+func (mr *MockRepositoryMockRecorder) ReplayWebhookDelivery(webhookID, deliveryID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayWebhookDelivery", reflect.TypeOf((*MockRepository)(nil).ReplayWebhookDelivery), webhookID, deliveryID)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockRepository) RevokeAPIKey(merchantID, keyID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", merchantID, keyID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockRepositoryMockRecorder) RevokeAPIKey(merchantID, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockRepository)(nil).RevokeAPIKey), merchantID, keyID)
+}
+
+// RevokeSession mocks base method.
+func (m *MockRepository) RevokeSession(sessionID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", sessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockRepositoryMockRecorder) RevokeSession(sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockRepository)(nil).RevokeSession), sessionID)
+}
+
+// RevokeUserSession mocks base method.
+func (m *MockRepository) RevokeUserSession(userID, sessionID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserSession", userID, sessionID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeUserSession indicates an expected call of RevokeUserSession.
+func (mr *MockRepositoryMockRecorder) RevokeUserSession(userID, sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserSession", reflect.TypeOf((*MockRepository)(nil).RevokeUserSession), userID, sessionID)
+}
+
+// RevokeUserSessions mocks base method.
+func (m *MockRepository) RevokeUserSessions(userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserSessions", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserSessions indicates an expected call of RevokeUserSessions.
+func (mr *MockRepositoryMockRecorder) RevokeUserSessions(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserSessions", reflect.TypeOf((*MockRepository)(nil).RevokeUserSessions), userID)
+}
+
+// SaveAPIKey mocks base method.
+func (m *MockRepository) SaveAPIKey(key *model.APIKey) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBalanceByUserID", userID)
-	ret0, _ := ret[0].(*model.Balance)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "SaveAPIKey", key)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetBalanceByUserID indicates an expected call of GetBalanceByUserID.
-func (mr *MockRepositoryMockRecorder) GetBalanceByUserID(userID interface{}) *gomock.Call {
+// SaveAPIKey indicates an expected call of SaveAPIKey.
+func (mr *MockRepositoryMockRecorder) SaveAPIKey(key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceByUserID", reflect.TypeOf((*MockRepository)(nil).GetBalanceByUserID), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAPIKey", reflect.TypeOf((*MockRepository)(nil).SaveAPIKey), key)
 }
 
-// GetOrderByNumber mocks base method.
-func (m *MockRepository) GetOrderByNumber(orderNumber string) (*model.Order, error) {
+// SaveAuditEntry mocks base method.
+func (m *MockRepository) SaveAuditEntry(entry *model.AuditEntry) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrderByNumber", orderNumber)
-	ret0, _ := ret[0].(*model.Order)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "SaveAuditEntry", entry)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetOrderByNumber indicates an expected call of GetOrderByNumber.
-func (mr *MockRepositoryMockRecorder) GetOrderByNumber(orderNumber interface{}) *gomock.Call {
+// SaveAuditEntry indicates an expected call of SaveAuditEntry.
+func (mr *MockRepositoryMockRecorder) SaveAuditEntry(entry interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByNumber", reflect.TypeOf((*MockRepository)(nil).GetOrderByNumber), orderNumber)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAuditEntry", reflect.TypeOf((*MockRepository)(nil).SaveAuditEntry), entry)
 }
 
-// GetOrdersByUserID mocks base method.
-func (m *MockRepository) GetOrdersByUserID(userID int) ([]model.Order, error) {
+// SaveBalance mocks base method.
+func (m *MockRepository) SaveBalance(balance *model.Balance) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrdersByUserID", userID)
-	ret0, _ := ret[0].([]model.Order)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "SaveBalance", balance)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetOrdersByUserID indicates an expected call of GetOrdersByUserID.
-func (mr *MockRepositoryMockRecorder) GetOrdersByUserID(userID interface{}) *gomock.Call {
+// SaveBalance indicates an expected call of SaveBalance.
+func (mr *MockRepositoryMockRecorder) SaveBalance(balance interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByUserID", reflect.TypeOf((*MockRepository)(nil).GetOrdersByUserID), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBalance", reflect.TypeOf((*MockRepository)(nil).SaveBalance), balance)
 }
 
-// GetOrdersForStatusUpdate mocks base method.
-func (m *MockRepository) GetOrdersForStatusUpdate() ([]*model.Order, error) {
+// SaveLoginChallenge mocks base method.
+func (m *MockRepository) SaveLoginChallenge(challenge *model.LoginChallenge) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrdersForStatusUpdate")
-	ret0, _ := ret[0].([]*model.Order)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "SaveLoginChallenge", challenge)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetOrdersForStatusUpdate indicates an expected call of GetOrdersForStatusUpdate.
-func (mr *MockRepositoryMockRecorder) GetOrdersForStatusUpdate() *gomock.Call {
+// SaveLoginChallenge indicates an expected call of SaveLoginChallenge.
+func (mr *MockRepositoryMockRecorder) SaveLoginChallenge(challenge interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersForStatusUpdate", reflect.TypeOf((*MockRepository)(nil).GetOrdersForStatusUpdate))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveLoginChallenge", reflect.TypeOf((*MockRepository)(nil).SaveLoginChallenge), challenge)
 }
 
-// GetUser mocks base method.
-func (m *MockRepository) GetUser(user *model.User) (*model.User, error) {
+// SaveMerchant mocks base method.
+func (m *MockRepository) SaveMerchant(merchant *model.Merchant) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", user)
-	ret0, _ := ret[0].(*model.User)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "SaveMerchant", merchant)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetUser indicates an expected call of GetUser.
-func (mr *MockRepositoryMockRecorder) GetUser(user interface{}) *gomock.Call {
+// SaveMerchant indicates an expected call of SaveMerchant.
+func (mr *MockRepositoryMockRecorder) SaveMerchant(merchant interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepository)(nil).GetUser), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMerchant", reflect.TypeOf((*MockRepository)(nil).SaveMerchant), merchant)
 }
 
-// GetWithdrawalsByUserID mocks base method.
-func (m *MockRepository) GetWithdrawalsByUserID(userID int) ([]*model.Withdraw, error) {
+// SaveOIDCIdentity mocks base method.
+func (m *MockRepository) SaveOIDCIdentity(identity *model.OIDCIdentity) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWithdrawalsByUserID", userID)
-	ret0, _ := ret[0].([]*model.Withdraw)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "SaveOIDCIdentity", identity)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetWithdrawalsByUserID indicates an expected call of GetWithdrawalsByUserID.
-func (mr *MockRepositoryMockRecorder) GetWithdrawalsByUserID(userID interface{}) *gomock.Call {
+// SaveOIDCIdentity indicates an expected call of SaveOIDCIdentity.
+func (mr *MockRepositoryMockRecorder) SaveOIDCIdentity(identity interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawalsByUserID", reflect.TypeOf((*MockRepository)(nil).GetWithdrawalsByUserID), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOIDCIdentity", reflect.TypeOf((*MockRepository)(nil).SaveOIDCIdentity), identity)
 }
 
-// SaveBalance mocks base method.
-func (m *MockRepository) SaveBalance(balance *model.Balance) error {
+// SaveOIDCState mocks base method.
+func (m *MockRepository) SaveOIDCState(state *model.OIDCState) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveBalance", balance)
+	ret := m.ctrl.Call(m, "SaveOIDCState", state)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// SaveBalance indicates an expected call of SaveBalance.
-func (mr *MockRepositoryMockRecorder) SaveBalance(balance interface{}) *gomock.Call {
+// SaveOIDCState indicates an expected call of SaveOIDCState.
+func (mr *MockRepositoryMockRecorder) SaveOIDCState(state interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBalance", reflect.TypeOf((*MockRepository)(nil).SaveBalance), balance)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOIDCState", reflect.TypeOf((*MockRepository)(nil).SaveOIDCState), state)
 }
 
 // SaveOrder mocks base method.
@@ -168,6 +1049,118 @@ func (mr *MockRepositoryMockRecorder) SaveOrder(order interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrder", reflect.TypeOf((*MockRepository)(nil).SaveOrder), order)
 }
 
+// SaveOrderStatusTransition mocks base method.
+func (m *MockRepository) SaveOrderStatusTransition(order *model.Order, transition *model.OrderStatusTransition) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOrderStatusTransition", order, transition)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveOrderStatusTransition indicates an expected call of SaveOrderStatusTransition.
+func (mr *MockRepositoryMockRecorder) SaveOrderStatusTransition(order, transition interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrderStatusTransition", reflect.TypeOf((*MockRepository)(nil).SaveOrderStatusTransition), order, transition)
+}
+
+// SavePasswordResetToken mocks base method.
+func (m *MockRepository) SavePasswordResetToken(token *model.PasswordResetToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SavePasswordResetToken", token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SavePasswordResetToken indicates an expected call of SavePasswordResetToken.
+func (mr *MockRepositoryMockRecorder) SavePasswordResetToken(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePasswordResetToken", reflect.TypeOf((*MockRepository)(nil).SavePasswordResetToken), token)
+}
+
+// SaveRecoveryCodes mocks base method.
+func (m *MockRepository) SaveRecoveryCodes(userID int, codeHashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRecoveryCodes", userID, codeHashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveRecoveryCodes indicates an expected call of SaveRecoveryCodes.
+func (mr *MockRepositoryMockRecorder) SaveRecoveryCodes(userID, codeHashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRecoveryCodes", reflect.TypeOf((*MockRepository)(nil).SaveRecoveryCodes), userID, codeHashes)
+}
+
+// SaveRefreshToken mocks base method.
+func (m *MockRepository) SaveRefreshToken(token *model.RefreshToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRefreshToken", token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveRefreshToken indicates an expected call of SaveRefreshToken.
+func (mr *MockRepositoryMockRecorder) SaveRefreshToken(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRefreshToken", reflect.TypeOf((*MockRepository)(nil).SaveRefreshToken), token)
+}
+
+// SaveRejectedStatusUpdate mocks base method.
+func (m *MockRepository) SaveRejectedStatusUpdate(update *model.RejectedStatusUpdate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRejectedStatusUpdate", update)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveRejectedStatusUpdate indicates an expected call of SaveRejectedStatusUpdate.
+func (mr *MockRepositoryMockRecorder) SaveRejectedStatusUpdate(update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRejectedStatusUpdate", reflect.TypeOf((*MockRepository)(nil).SaveRejectedStatusUpdate), update)
+}
+
+// SaveRevokedToken mocks base method.
+func (m *MockRepository) SaveRevokedToken(jti string, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRevokedToken", jti, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveRevokedToken indicates an expected call of SaveRevokedToken.
+func (mr *MockRepositoryMockRecorder) SaveRevokedToken(jti, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRevokedToken", reflect.TypeOf((*MockRepository)(nil).SaveRevokedToken), jti, expiresAt)
+}
+
+// SaveSession mocks base method.
+func (m *MockRepository) SaveSession(session *model.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveSession", session)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveSession indicates an expected call of SaveSession.
+func (mr *MockRepositoryMockRecorder) SaveSession(session interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveSession", reflect.TypeOf((*MockRepository)(nil).SaveSession), session)
+}
+
+// SaveTwoFactor mocks base method.
+func (m *MockRepository) SaveTwoFactor(twoFactor *model.TwoFactor) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTwoFactor", twoFactor)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveTwoFactor indicates an expected call of SaveTwoFactor.
+func (mr *MockRepositoryMockRecorder) SaveTwoFactor(twoFactor interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTwoFactor", reflect.TypeOf((*MockRepository)(nil).SaveTwoFactor), twoFactor)
+}
+
 // SaveUser mocks base method.
 func (m *MockRepository) SaveUser(user *model.User) error {
 	m.ctrl.T.Helper()
@@ -182,6 +1175,34 @@ func (mr *MockRepositoryMockRecorder) SaveUser(user interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveUser", reflect.TypeOf((*MockRepository)(nil).SaveUser), user)
 }
 
+// SaveWebhook mocks base method.
+func (m *MockRepository) SaveWebhook(webhook *model.Webhook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveWebhook", webhook)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveWebhook indicates an expected call of SaveWebhook.
+func (mr *MockRepositoryMockRecorder) SaveWebhook(webhook interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveWebhook", reflect.TypeOf((*MockRepository)(nil).SaveWebhook), webhook)
+}
+
+// SaveWebhookDelivery mocks base method.
+func (m *MockRepository) SaveWebhookDelivery(delivery *model.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveWebhookDelivery", delivery)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveWebhookDelivery indicates an expected call of SaveWebhookDelivery.
+func (mr *MockRepositoryMockRecorder) SaveWebhookDelivery(delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveWebhookDelivery", reflect.TypeOf((*MockRepository)(nil).SaveWebhookDelivery), delivery)
+}
+
 // SaveWithdraw mocks base method.
 func (m *MockRepository) SaveWithdraw(withdraw *model.Withdraw) error {
 	m.ctrl.T.Helper()
@@ -196,6 +1217,35 @@ func (mr *MockRepositoryMockRecorder) SaveWithdraw(withdraw interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveWithdraw", reflect.TypeOf((*MockRepository)(nil).SaveWithdraw), withdraw)
 }
 
+// SearchUsersByLogin mocks base method.
+func (m *MockRepository) SearchUsersByLogin(login string, limit int) ([]model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsersByLogin", login, limit)
+	ret0, _ := ret[0].([]model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsersByLogin indicates an expected call of SearchUsersByLogin.
+func (mr *MockRepositoryMockRecorder) SearchUsersByLogin(login, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsersByLogin", reflect.TypeOf((*MockRepository)(nil).SearchUsersByLogin), login, limit)
+}
+
+// SetMerchantNumberFormat mocks base method.
+func (m *MockRepository) SetMerchantNumberFormat(merchantID int, format *model.NumberFormat) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMerchantNumberFormat", merchantID, format)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMerchantNumberFormat indicates an expected call of SetMerchantNumberFormat.
+func (mr *MockRepositoryMockRecorder) SetMerchantNumberFormat(merchantID, format interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMerchantNumberFormat", reflect.TypeOf((*MockRepository)(nil).SetMerchantNumberFormat), merchantID, format)
+}
+
 // Shutdown mocks base method.
 func (m *MockRepository) Shutdown() {
 	m.ctrl.T.Helper()
@@ -207,3 +1257,162 @@ func (mr *MockRepositoryMockRecorder) Shutdown() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shutdown", reflect.TypeOf((*MockRepository)(nil).Shutdown))
 }
+
+// TakeOIDCState mocks base method.
+func (m *MockRepository) TakeOIDCState(stateHash string) (*model.OIDCState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeOIDCState", stateHash)
+	ret0, _ := ret[0].(*model.OIDCState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeOIDCState indicates an expected call of TakeOIDCState.
+func (mr *MockRepositoryMockRecorder) TakeOIDCState(stateHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeOIDCState", reflect.TypeOf((*MockRepository)(nil).TakeOIDCState), stateHash)
+}
+
+// TouchAPIKey mocks base method.
+func (m *MockRepository) TouchAPIKey(keyID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchAPIKey", keyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchAPIKey indicates an expected call of TouchAPIKey.
+func (mr *MockRepositoryMockRecorder) TouchAPIKey(keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchAPIKey", reflect.TypeOf((*MockRepository)(nil).TouchAPIKey), keyID)
+}
+
+// TouchSession mocks base method.
+func (m *MockRepository) TouchSession(sessionID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchSession", sessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchSession indicates an expected call of TouchSession.
+func (mr *MockRepositoryMockRecorder) TouchSession(sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchSession", reflect.TypeOf((*MockRepository)(nil).TouchSession), sessionID)
+}
+
+// UpdateUserLoyaltyCard mocks base method.
+func (m *MockRepository) UpdateUserLoyaltyCard(userID int, loyaltyCard string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserLoyaltyCard", userID, loyaltyCard)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserLoyaltyCard indicates an expected call of UpdateUserLoyaltyCard.
+func (mr *MockRepositoryMockRecorder) UpdateUserLoyaltyCard(userID, loyaltyCard interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserLoyaltyCard", reflect.TypeOf((*MockRepository)(nil).UpdateUserLoyaltyCard), userID, loyaltyCard)
+}
+
+// UpdateUserPassword mocks base method.
+func (m *MockRepository) UpdateUserPassword(user *model.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockRepositoryMockRecorder) UpdateUserPassword(user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockRepository)(nil).UpdateUserPassword), user)
+}
+
+// UpdateUserRole mocks base method.
+func (m *MockRepository) UpdateUserRole(userID int, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserRole", userID, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserRole indicates an expected call of UpdateUserRole.
+func (mr *MockRepositoryMockRecorder) UpdateUserRole(userID, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserRole", reflect.TypeOf((*MockRepository)(nil).UpdateUserRole), userID, role)
+}
+
+// UpdateWebhookDelivery mocks base method.
+func (m *MockRepository) UpdateWebhookDelivery(delivery *model.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhookDelivery", delivery)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWebhookDelivery indicates an expected call of UpdateWebhookDelivery.
+func (mr *MockRepositoryMockRecorder) UpdateWebhookDelivery(delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhookDelivery", reflect.TypeOf((*MockRepository)(nil).UpdateWebhookDelivery), delivery)
+}
+
+// UseLoginChallenge mocks base method.
+func (m *MockRepository) UseLoginChallenge(challengeID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseLoginChallenge", challengeID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseLoginChallenge indicates an expected call of UseLoginChallenge.
+func (mr *MockRepositoryMockRecorder) UseLoginChallenge(challengeID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseLoginChallenge", reflect.TypeOf((*MockRepository)(nil).UseLoginChallenge), challengeID)
+}
+
+// UsePasswordResetToken mocks base method.
+func (m *MockRepository) UsePasswordResetToken(tokenHash string) (*model.PasswordResetToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsePasswordResetToken", tokenHash)
+	ret0, _ := ret[0].(*model.PasswordResetToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UsePasswordResetToken indicates an expected call of UsePasswordResetToken.
+func (mr *MockRepositoryMockRecorder) UsePasswordResetToken(tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsePasswordResetToken", reflect.TypeOf((*MockRepository)(nil).UsePasswordResetToken), tokenHash)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockRepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", userID, codeHash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockRepositoryMockRecorder) UseRecoveryCode(userID, codeHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockRepository)(nil).UseRecoveryCode), userID, codeHash)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockRepository) UseTOTPStep(userID int, step int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", userID, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockRepositoryMockRecorder) UseTOTPStep(userID, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockRepository)(nil).UseTOTPStep), userID, step)
+}
@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+const (
+	AuditActionSearchUsers       = "search_users"
+	AuditActionViewAccount       = "view_account"
+	AuditActionAdjustBalance     = "adjust_balance"
+	AuditActionChangeOrderStatus = "change_order_status"
+	AuditActionReassignOrder     = "reassign_order"
+	AuditActionSetRole           = "set_role"
+	AuditActionClearLockout      = "clear_lockout"
+	AuditActionSetLoyaltyCard    = "set_loyalty_card"
+	AuditActionCreateMerchant    = "create_merchant"
+	AuditActionIssueAPIKey       = "issue_api_key"
+	AuditActionRevokeAPIKey      = "revoke_api_key"
+	AuditActionSetNumberFormat   = "set_number_format"
+	AuditActionViewAuditTrail    = "view_audit_trail"
+	AuditActionViewStats         = "view_stats"
+	AuditActionDeleteOrder       = "delete_order"
+)
+
+type AuditEntry struct {
+	ID *int `json:"id"`
+	// ActorID is nil for actions authorized by admin token rather than by user with admin role.
+	ActorID      *int                   `json:"actor_id"`
+	Action       string                 `json:"action"`
+	TargetUserID *int                   `json:"target_user_id,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+type UserSummary struct {
+	ID    int    `json:"id"`
+	Login string `json:"login"`
+	Role  string `json:"role"`
+}
+
+type UserAccount struct {
+	User        UserSummary `json:"user"`
+	Balance     *Balance    `json:"balance"`
+	Orders      []Order     `json:"orders"`
+	Withdrawals []*Withdraw `json:"withdrawals"`
+}
+
+type BalanceAdjustment struct {
+	// Amount is credited to balance when positive and debited when negative.
+	Amount float32 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+type OrderStatusChange struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+type Stats struct {
+	// RejectedStatusUpdates is number of order status updates rejected as illegal transitions.
+	RejectedStatusUpdates uint64 `json:"rejected_status_updates"`
+}
+
+type OrderReassignment struct {
+	// Login is user the order is reassigned to.
+	Login  string `json:"login"`
+	Reason string `json:"reason"`
+}
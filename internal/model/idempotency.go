@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// IdempotencyKey is response to request of user, stored to be replayed for retries with the same key.
+// Response is empty while the first request is being processed.
+type IdempotencyKey struct {
+	ID     *int
+	UserID int
+	Key    string
+	// Fingerprint is hash of request, retry has to be the same request.
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	CompletedAt *time.Time
+	// LockedUntil is end of lease of request being processed, retry may take the key over after it.
+	LockedUntil *time.Time
+	// LeaseToken identifies attempt holding the lease, only it may complete or release the key.
+	LeaseToken string
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+type LoginAttempt struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// LoginAttemptPolicy tells how counted attempt locks key for next attempts. Attempt, which reaches
+// DelayAfter, locks it for DelayBase doubled with every next one up to DelayMax, attempt, which
+// reaches LockoutAfter, for LockoutDuration. Zero thresholds turn locks off. Attempts counted
+// before WindowStart are forgotten.
+type LoginAttemptPolicy struct {
+	WindowStart     time.Time
+	DelayAfter      int
+	DelayBase       time.Duration
+	DelayMax        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+}
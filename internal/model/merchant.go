@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// ScopeOrdersWrite allows merchant to upload orders on behalf of users.
+const ScopeOrdersWrite = "orders:write"
+
+type Merchant struct {
+	ID           *int          `json:"id"`
+	Name         string        `json:"name"`
+	NumberFormat *NumberFormat `json:"number_format,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+// NumberFormat is own format of order numbers of merchant. Pattern is regular expression
+// matching whole normalized number, Luhn additionally requires valid check digit.
+// Empty pattern resets merchant to Luhn validated numbers.
+type NumberFormat struct {
+	Pattern string `json:"pattern"`
+	Luhn    bool   `json:"luhn"`
+}
+
+type APIKey struct {
+	ID       *int      `json:"id"`
+	Merchant *Merchant `json:"-"`
+	// Key is returned only once, when key is issued. Only its hash is stored.
+	Key        string     `json:"key,omitempty"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type APIKeyRequest struct {
+	Scopes []string `json:"scopes"`
+}
+
+// PartnerOrder is order uploaded by merchant, the user is identified either by login or by loyalty card.
+type PartnerOrder struct {
+	Order       string `json:"order"`
+	Login       string `json:"login,omitempty"`
+	LoyaltyCard string `json:"loyalty_card,omitempty"`
+}
+
+type LoyaltyCardChange struct {
+	LoyaltyCard string `json:"loyalty_card"`
+}
@@ -0,0 +1,8 @@
+package model
+
+type Notification struct {
+	// Recipient is login of the user, notifiers delivering to mailboxes treat it as an address.
+	Recipient string
+	Subject   string
+	Body      string
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// OIDCIdentity links user to subject of OpenID Connect provider.
+type OIDCIdentity struct {
+	ID        *int
+	User      *User
+	Issuer    string
+	Subject   string
+	CreatedAt time.Time
+}
+
+// OIDCState keeps PKCE verifier and nonce of authorization request until user returns from
+// provider. User is set when identity is being linked to an already signed-in user.
+// BindingHash is hash of cookie set to browser, which started the flow.
+type OIDCState struct {
+	ID           *int
+	StateHash    string
+	BindingHash  string
+	CodeVerifier string
+	Nonce        string
+	User         *User
+	ExpiresAt    time.Time
+}
+
+type OIDCAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
@@ -12,6 +12,8 @@ type Order struct {
 	Accrual    *float32  `json:"accrual,omitempty"`
 	Status     string    `json:"status"`
 	UploadTime time.Time `json:"uploaded_at,omitempty"`
+	// MerchantID is set for orders uploaded by partner merchant.
+	MerchantID *int `json:"-"`
 }
 
 func (o *Order) MarshalJSON() ([]byte, error) {
@@ -24,3 +26,69 @@ func (o *Order) MarshalJSON() ([]byte, error) {
 		Alias:    (*Alias)(o),
 	})
 }
+
+const (
+	OrderResultAccepted           = "accepted"
+	OrderResultAlreadyYours       = "already_yours"
+	OrderResultOwnedByAnotherUser = "owned_by_another_user"
+	OrderResultInvalidNumber      = "invalid_number"
+)
+
+// OrderBatchResult is outcome of a single number of batch upload.
+type OrderBatchResult struct {
+	Number string `json:"number"`
+	Result string `json:"result"`
+}
+
+// OrderFilter selects page of user orders, which are ordered by upload time and id.
+type OrderFilter struct {
+	Statuses     []string
+	UploadedFrom *time.Time
+	UploadedTo   *time.Time
+	Descending   bool
+	// After is position of the last order of previous page.
+	After *OrderCursor
+	Limit int
+}
+
+type OrderCursor struct {
+	UploadTime time.Time
+	ID         int
+}
+
+type OrderPage struct {
+	Orders []Order
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+// RejectedStatusUpdate is status received from accrual system, which was not saved as illegal transition.
+type RejectedStatusUpdate struct {
+	OrderNumber string
+	FromStatus  string
+	ToStatus    string
+	RejectedAt  time.Time
+}
+
+// OrderStatusTransition is single change of order status, FromStatus is empty for upload of order.
+type OrderStatusTransition struct {
+	FromStatus string   `json:"from_status,omitempty"`
+	ToStatus   string   `json:"to_status"`
+	Accrual    *float32 `json:"accrual,omitempty"`
+	// BalanceCredited is set when accrual was credited to balance of order owner with this transition.
+	BalanceCredited bool      `json:"balance_credited,omitempty"`
+	ChangedAt       time.Time `json:"changed_at"`
+}
+
+// OrderEvent is status transition of user order, streamed to the user. ID grows with every event.
+type OrderEvent struct {
+	ID     int    `json:"-"`
+	Number string `json:"number"`
+	OrderStatusTransition
+}
+
+// OrderDetails is order with timeline of its status transitions, the oldest first.
+type OrderDetails struct {
+	Order   *Order                  `json:"order"`
+	History []OrderStatusTransition `json:"history"`
+}
@@ -6,3 +6,32 @@ const (
 	OrderStatusProcessed  = "PROCESSED"
 	OrderStatusInvalid    = "INVALID"
 )
+
+// orderStatusTransitions lists statuses order can move to from every status. PROCESSED and
+// INVALID are terminal, order cannot leave them.
+var orderStatusTransitions = map[string][]string{
+	OrderStatusNew:        {OrderStatusProcessing, OrderStatusProcessed, OrderStatusInvalid},
+	OrderStatusProcessing: {OrderStatusProcessed, OrderStatusInvalid},
+	OrderStatusProcessed:  {},
+	OrderStatusInvalid:    {},
+}
+
+func ValidOrderStatus(status string) bool {
+	_, ok := orderStatusTransitions[status]
+	return ok
+}
+
+func TerminalOrderStatus(status string) bool {
+	next, ok := orderStatusTransitions[status]
+	return ok && len(next) == 0
+}
+
+// CanTransitionOrderStatus reports whether order in status from may be moved to status to.
+func CanTransitionOrderStatus(from string, to string) bool {
+	for _, status := range orderStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
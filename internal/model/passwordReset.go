@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+type PasswordResetToken struct {
+	ID        *int
+	User      *User
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+type PasswordResetRequest struct {
+	Login string `json:"login"`
+}
+
+type PasswordReset struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
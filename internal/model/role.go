@@ -0,0 +1,20 @@
+package model
+
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+func ValidRole(role string) bool {
+	switch role {
+	case RoleUser, RoleSupport, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+type RoleChange struct {
+	Role string `json:"role"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+type Session struct {
+	ID         *int       `json:"id"`
+	User       *User      `json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-"`
+	// Current marks session of the token the list was requested with.
+	Current bool `json:"current"`
+}
+
+// ClientInfo describes client the session is started by.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+type RefreshToken struct {
+	ID        *int
+	Session   *Session
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+type Tokens struct {
+	AccessToken          string
+	AccessTokenExpiresAt time.Time
+	RefreshToken         string
+}
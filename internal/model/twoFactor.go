@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+type TwoFactor struct {
+	User    *User
+	Secret  string
+	Enabled bool
+	// LastUsedStep is TOTP time step of the last accepted code, codes of this
+	// and earlier steps are rejected to prevent replay.
+	LastUsedStep int64
+}
+
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorCode struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+type LoginChallenge struct {
+	ID        *int
+	User      *User
+	TokenHash string
+	ExpiresAt time.Time
+	Attempts  int
+	UsedAt    *time.Time
+}
+
+type TwoFactorLogin struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
@@ -4,4 +4,10 @@ type User struct {
 	ID       *int
 	Login    string `json:"login"`
 	Password string `json:"password"`
+	Role     string `json:"-"`
+}
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
 }
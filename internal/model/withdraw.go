@@ -10,6 +10,8 @@ type Withdraw struct {
 	Sum         float32   `json:"sum"`
 	ProcessedAt time.Time `json:"processed_at,omitempty"`
 	User        User      `json:"-"`
+	// IdempotencyKeyID is key of request, which made withdrawal, retry of it must not withdraw again.
+	IdempotencyKeyID *int `json:"-"`
 }
 
 func (w *Withdraw) MarshalJSON() ([]byte, error) {
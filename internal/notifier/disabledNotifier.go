@@ -0,0 +1,13 @@
+package notifier
+
+import (
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+)
+
+// DisabledNotifier drops notifications, when no way to deliver them is configured.
+type DisabledNotifier struct{}
+
+func (n DisabledNotifier) Notify(notification model.Notification) error {
+	return &errors.NotifierNotConfiguredError{}
+}
@@ -0,0 +1,50 @@
+package notifier
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogNotifier is meant for development: it writes notifications to the log,
+// or appends them to file when one is set.
+type LogNotifier struct {
+	file string
+	mu   *sync.Mutex
+}
+
+func NewLogNotifier(file string) *LogNotifier {
+	return &LogNotifier{
+		file: file,
+		mu:   &sync.Mutex{},
+	}
+}
+
+func (n LogNotifier) Notify(notification model.Notification) error {
+	if n.file == "" {
+		log.WithFields(log.Fields{
+			"recipient": notification.Recipient,
+			"subject":   notification.Subject,
+		}).Warn(notification.Body)
+		return nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, err := os.OpenFile(n.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Error("cannot open notification file: ", err)
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC1123Z),
+		notification.Recipient,
+		notification.Subject,
+		notification.Body,
+	)
+	return err
+}
@@ -0,0 +1,27 @@
+package notifier
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/model"
+)
+
+// Notifier delivers messages to users out of band, e.g. password reset tokens.
+type Notifier interface {
+	Notify(notification model.Notification) error
+}
+
+// NewNotifier returns SMTP notifier when SMTP server is configured and LogNotifier, when
+// notification file or log is enabled. Notifications carry secrets, e.g. reset tokens, so
+// they are not written to the log unless it was asked for, DisabledNotifier is returned then.
+func NewNotifier(cfg *config.ServerConfig) Notifier {
+	switch {
+	case cfg.SMTPAddress != "":
+		return NewSMTPNotifier(cfg.SMTPAddress, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	case cfg.NotificationFile != "" || cfg.NotificationLog:
+		return NewLogNotifier(cfg.NotificationFile)
+	default:
+		log.Warn("neither SMTP server nor notification file or log is configured, notifications will not be delivered")
+		return DisabledNotifier{}
+	}
+}
@@ -0,0 +1,116 @@
+package notifier
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type receivedMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer runs minimal SMTP server accepting a single message on local address.
+func startSMTPServer(t *testing.T) (string, <-chan receivedMail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	mails := make(chan receivedMail, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		var mail receivedMail
+
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				text.PrintfLine("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				mail.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+				text.PrintfLine("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				mail.to = append(mail.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+				text.PrintfLine("250 OK")
+			case command == "DATA":
+				text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				mail.data = string(data)
+				text.PrintfLine("250 OK")
+			case command == "QUIT":
+				text.PrintfLine("221 Bye")
+				mails <- mail
+				return
+			default:
+				text.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), mails
+}
+
+func TestSMTPNotifier_Notify(t *testing.T) {
+	address, mails := startSMTPServer(t)
+	n := NewSMTPNotifier(address, "", "", "gophermart@localhost")
+
+	err := n.Notify(model.Notification{
+		Recipient: "user@example.com",
+		Subject:   "Password reset",
+		Body:      "token: abc",
+	})
+	require.NoError(t, err)
+
+	mail := <-mails
+	assert.Equal(t, "gophermart@localhost", mail.from)
+	assert.Equal(t, []string{"user@example.com"}, mail.to)
+	assert.Contains(t, mail.data, "Subject: Password reset")
+	assert.Contains(t, mail.data, "To: user@example.com")
+	assert.Contains(t, mail.data, "token: abc")
+}
+
+func TestSMTPNotifier_NotifyNotEmail(t *testing.T) {
+	n := NewSMTPNotifier("127.0.0.1:1", "", "", "gophermart@localhost")
+	assert.Error(t, n.Notify(model.Notification{Recipient: "user"}))
+}
+
+func TestLogNotifier_NotifyFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "notifications.txt")
+	n := NewLogNotifier(file)
+
+	require.NoError(t, n.Notify(model.Notification{Recipient: "first", Subject: "Password reset", Body: "token: abc"}))
+	require.NoError(t, n.Notify(model.Notification{Recipient: "second", Subject: "Password reset", Body: "token: def"}))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	var recipients []string
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "To: ") {
+			recipients = append(recipients, strings.TrimPrefix(scanner.Text(), "To: "))
+		}
+	}
+	assert.Equal(t, []string{"first", "second"}, recipients)
+	assert.Contains(t, string(data), "token: def")
+}
@@ -0,0 +1,61 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier sends notifications as plain text emails, user login is used as recipient address.
+type SMTPNotifier struct {
+	address string
+	from    string
+	auth    smtp.Auth
+}
+
+// NewSMTPNotifier creates notifier for server at address (host:port). PLAIN authentication
+// is used when username is set, net/smtp allows it only over TLS or to localhost.
+func NewSMTPNotifier(address, username, password, from string) *SMTPNotifier {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := net.SplitHostPort(address)
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPNotifier{
+		address: address,
+		from:    from,
+		auth:    auth,
+	}
+}
+
+func (n SMTPNotifier) Notify(notification model.Notification) error {
+	if !strings.Contains(notification.Recipient, "@") {
+		return fmt.Errorf("cannot send email to %q: login is not an email address", notification.Recipient)
+	}
+	err := smtp.SendMail(n.address, n.auth, n.from, []string{notification.Recipient}, n.message(notification))
+	if err != nil {
+		log.Error("error sending email: ", err)
+		return err
+	}
+	return nil
+}
+
+func (n SMTPNotifier) message(notification model.Notification) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", notification.Recipient)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(notification.Body, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return msg.Bytes()
+}
@@ -4,52 +4,193 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/jwtauth/v5"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/clients"
+	"github.com/yurchenkosv/gofermart/internal/config"
 	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/events"
 	"github.com/yurchenkosv/gofermart/internal/handlers"
+	"github.com/yurchenkosv/gofermart/internal/keys"
 	"github.com/yurchenkosv/gofermart/internal/middlewares"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/notifier"
 	"github.com/yurchenkosv/gofermart/internal/service"
+	"github.com/yurchenkosv/gofermart/internal/validator"
 )
 
-func NewRouter(repo dao.Repository, tokenAuth *jwtauth.JWTAuth) chi.Router {
+func NewRouter(
+	repo dao.Repository,
+	keySet *keys.KeySet,
+	notifier notifier.Notifier,
+	broker *events.Broker,
+	cfg *config.ServerConfig,
+) chi.Router {
 	var (
-		authService     = service.NewAuthService(repo)
-		orderService    = service.NewOrderService(repo)
-		withdrawService = service.NewWithdrawService(repo)
+		validators      = validator.NewRegistry()
+		authService     = service.NewAuthService(repo, cfg)
+		tokenService    = service.NewTokenService(repo, keySet, cfg)
+		orderService    = service.NewOrderService(repo, validators)
+		withdrawService = service.NewWithdrawService(repo, validators.For(nil))
+		loginThrottle   = service.NewLoginThrottleService(repo, cfg)
 		balanceService  = service.NewBalance(repo)
+		passwordReset   = service.NewPasswordResetService(repo, loginThrottle, notifier, cfg)
+		twoFactor       = service.NewTwoFactorService(repo, loginThrottle, cfg)
+		adminService    = service.NewAdminService(repo, authService, loginThrottle)
+		merchantService = service.NewMerchantService(repo, orderService, validators)
+		idempotency     = service.NewIdempotencyService(repo, cfg)
+		webhookService  = service.NewWebhookService(repo, clients.NewWebhookClient(cfg.WebhookTimeout), cfg)
+		oidcService     = service.NewOIDCService(repo, clients.NewOIDCClient(
+			cfg.OIDCDiscoveryURL,
+			cfg.OIDCClientID,
+			cfg.OIDCClientSecret,
+			cfg.OIDCRedirectURL,
+			cfg.OIDCScopes,
+		), cfg)
 
-		authHandler    = handlers.NewAuthHanler(&authService, tokenAuth)
-		orderHandler   = handlers.NewOrderHandler(&orderService)
-		balanceHandler = handlers.NewBalanceHandler(&balanceService, &withdrawService)
+		authHandler      = handlers.NewAuthHanler(&authService, &tokenService, &loginThrottle, &twoFactor, cfg)
+		orderHandler     = handlers.NewOrderHandler(&orderService)
+		orderEvents      = handlers.NewOrderEventsHandler(&orderService, &tokenService, broker)
+		balanceHandler   = handlers.NewBalanceHandler(&balanceService, &withdrawService, &twoFactor)
+		jwksHandler      = handlers.NewJWKSHandler(keySet)
+		adminHandler     = handlers.NewAdminHandler(&adminService)
+		resetHandler     = handlers.NewPasswordResetHandler(&passwordReset)
+		twoFactorHandler = handlers.NewTwoFactorHandler(&twoFactor)
+		merchantHandler  = handlers.NewMerchantHandler(&merchantService)
+		partnerHandler   = handlers.NewPartnerHandler(&merchantService)
+		sessionHandler   = handlers.NewSessionHandler(&tokenService, cfg)
+		oidcHandler      = handlers.NewOIDCHandler(&oidcService, &tokenService, &twoFactor, cfg)
+		webhookHandler   = handlers.NewWebhookHandler(&webhookService)
 	)
 
+	if err := merchantService.LoadNumberFormats(); err != nil {
+		log.Fatal("cannot load number formats of merchants: ", err)
+	}
+
+	// authenticated requires valid access token of an active session
+	authenticated := func(r chi.Router) {
+		r.Use(middlewares.Verifier(keySet, cfg.CookieName))
+		r.Use(jwtauth.Authenticator)
+		r.Use(middlewares.RejectRevoked(tokenService))
+		r.Use(middlewares.CSRF(cfg.CookieName))
+	}
+
+	// idempotent replays response to retried request with the same Idempotency-Key header
+	idempotent := middlewares.Idempotency(idempotency)
+
 	router := chi.NewRouter()
+	if cfg.TrustProxyHeaders {
+		router.Use(middleware.RealIP)
+	}
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.Logger)
 	router.Use(middleware.RequestID)
 	router.Use(middleware.StripSlashes)
 
+	router.Get("/.well-known/jwks.json", jwksHandler.HandleGetJWKS)
+
 	router.Route("/api/user", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
 			r.Use(middlewares.AllowContentType("application/json"))
 			r.Post("/register", authHandler.HandleUserRegistration)
 			r.Post("/login", authHandler.HanldeUserLogin)
+			r.Post("/login/2fa", authHandler.HandleTwoFactorLogin)
+			r.Post("/password/reset/request", resetHandler.HandleRequestReset)
+			r.Post("/password/reset", resetHandler.HandleResetPassword)
+			r.With(middlewares.CSRF(handlers.RefreshTokenCookie)).
+				Post("/token/refresh", authHandler.HandleTokenRefresh)
+			if cfg.OIDCDiscoveryURL != "" {
+				r.Get("/oidc/login", oidcHandler.HandleLogin)
+				// callback is authenticated optionally, link flow requires the linking user
+				r.With(middlewares.Verifier(keySet, cfg.CookieName)).
+					Get("/oidc/callback", oidcHandler.HandleCallback)
+			}
 		})
 		r.Group(func(r chi.Router) {
-			r.Use(jwtauth.Verifier(tokenAuth))
-			r.Use(jwtauth.Authenticator)
+			authenticated(r)
 			r.Group(func(r chi.Router) {
 				r.Use(middlewares.AllowContentType("text/plain"))
+				r.Use(idempotent)
 				r.Post("/orders", orderHandler.HandleCreateOrder)
 			})
+			r.Group(func(r chi.Router) {
+				r.Use(middlewares.AllowContentType("application/json", "text/plain", "text/csv"))
+				r.Use(idempotent)
+				r.Post("/orders/batch", orderHandler.HandleCreateOrders)
+			})
 			r.Use(middlewares.AllowContentType("application/json"))
+			r.Post("/logout", authHandler.HandleUserLogout)
+			r.Put("/password", authHandler.HandleChangePassword)
+			r.Delete("/", authHandler.HandleDeleteUser)
+			r.Get("/sessions", sessionHandler.HandleGetSessions)
+			r.Delete("/sessions/{id}", sessionHandler.HandleDeleteSession)
+			if cfg.OIDCDiscoveryURL != "" {
+				r.Post("/oidc/link", oidcHandler.HandleLink)
+			}
+			r.Route("/2fa", func(r chi.Router) {
+				r.Post("/enroll", twoFactorHandler.HandleEnroll)
+				r.Post("/confirm", twoFactorHandler.HandleConfirm)
+				r.Post("/disable", twoFactorHandler.HandleDisable)
+			})
 			r.Get("/orders", orderHandler.HandleGetOrders)
+			r.Get("/orders/events", orderEvents.HandleOrderEvents)
+			r.Get("/orders/{number}", orderHandler.HandleGetOrder)
+			r.Delete("/orders/{number}", orderHandler.HandleDeleteOrder)
 			r.Get("/withdrawals", balanceHandler.HandleGetBalanceWithdraws)
 			r.Route("/balance", func(r chi.Router) {
 				r.Get("/", balanceHandler.HandleGetBalance)
-				r.Post("/withdraw", balanceHandler.HandleBalanceWithdraw)
+				r.With(idempotent).Post("/withdraw", balanceHandler.HandleBalanceWithdraw)
+			})
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Post("/", webhookHandler.HandleCreateWebhook)
+				r.Get("/", webhookHandler.HandleGetWebhooks)
+				r.Delete("/{id}", webhookHandler.HandleDeleteWebhook)
+				r.Get("/{id}/deliveries", webhookHandler.HandleGetDeliveries)
+				r.Post("/{id}/deliveries/{deliveryID}/replay", webhookHandler.HandleReplayDelivery)
 			})
 		})
 	})
 
+	router.Route("/api/support", func(r chi.Router) {
+		authenticated(r)
+		r.Use(middlewares.RequireRole(model.RoleSupport, model.RoleAdmin))
+		r.Delete("/lockouts/{login}", adminHandler.HandleClearLockout)
+		r.Put("/orders/{number}/owner", adminHandler.HandleReassignOrder)
+	})
+
+	router.Route("/api/admin", func(r chi.Router) {
+		r.Use(middlewares.AllowContentType("application/json"))
+		// admin token is meant for automation and granting role to the first admin
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.AdminToken(cfg.AdminToken))
+			r.Put("/token/users/{login}/role", adminHandler.HandleSetRole)
+		})
+		r.Group(func(r chi.Router) {
+			authenticated(r)
+			r.Use(middlewares.RequireRole(model.RoleAdmin))
+			r.Get("/users", adminHandler.HandleSearchUsers)
+			r.Get("/users/{id}", adminHandler.HandleGetUserAccount)
+			r.Post("/users/{id}/balance", adminHandler.HandleAdjustBalance)
+			r.Put("/users/{login}/role", adminHandler.HandleSetRole)
+			r.Put("/users/{id}/loyalty-card", adminHandler.HandleSetLoyaltyCard)
+			r.Put("/orders/{number}/status", adminHandler.HandleChangeOrderStatus)
+			r.Delete("/lockouts/{login}", adminHandler.HandleClearLockout)
+			r.Get("/audit", adminHandler.HandleGetAuditTrail)
+			r.Get("/stats", adminHandler.HandleGetStats)
+			r.Route("/merchants", func(r chi.Router) {
+				r.Post("/", merchantHandler.HandleCreateMerchant)
+				r.Put("/{id}/number-format", merchantHandler.HandleSetNumberFormat)
+				r.Get("/{id}/keys", merchantHandler.HandleGetAPIKeys)
+				r.Post("/{id}/keys", merchantHandler.HandleIssueAPIKey)
+				r.Delete("/{id}/keys/{keyID}", merchantHandler.HandleRevokeAPIKey)
+			})
+		})
+	})
+
+	router.Route("/api/partner", func(r chi.Router) {
+		r.Use(middlewares.AllowContentType("application/json"))
+		r.Use(middlewares.APIKey(merchantService, model.ScopeOrdersWrite))
+		r.Post("/orders", partnerHandler.HandleCreateOrder)
+	})
+
 	return router
 }
@@ -0,0 +1,385 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	adminSearchLimit     = 50
+	auditTrailLimit      = 200
+	loyaltyCardMinLength = 8
+	loyaltyCardMaxLength = 20
+)
+
+// Admin is operator tooling. Every call, including read-only ones, is written
+// to the audit trail, and fails if it cannot be recorded.
+type Admin interface {
+	SearchUsers(actorID int, login string) ([]model.UserSummary, error)
+	GetUserAccount(actorID int, userID int) (*model.UserAccount, error)
+	AdjustBalance(actorID int, userID int, adjustment model.BalanceAdjustment) (*model.Balance, error)
+	ChangeOrderStatus(actorID int, number string, change model.OrderStatusChange) (*model.Order, error)
+	ReassignOrder(actorID int, number string, reassignment model.OrderReassignment) (*model.Order, error)
+	SetRole(actorID *int, login string, role string) error
+	ClearLockout(actorID int, login string) error
+	SetLoyaltyCard(actorID int, userID int, loyaltyCard string) error
+	GetAuditTrail(actorID int, userID *int) ([]model.AuditEntry, error)
+	GetStats(actorID int) (*model.Stats, error)
+}
+
+type AdminService struct {
+	repo          dao.Repository
+	authService   Auth
+	loginThrottle LoginThrottle
+}
+
+func NewAdminService(repo dao.Repository, authService Auth, loginThrottle LoginThrottle) Admin {
+	return AdminService{
+		repo:          repo,
+		authService:   authService,
+		loginThrottle: loginThrottle,
+	}
+}
+
+func (s AdminService) SearchUsers(actorID int, login string) ([]model.UserSummary, error) {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("login", "must not be empty")
+		return nil, validationErr
+	}
+	err := audit(s.repo, &actorID, model.AuditActionSearchUsers, nil, map[string]interface{}{
+		"login": login,
+	})
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.repo.SearchUsersByLogin(login, adminSearchLimit)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]model.UserSummary, 0, len(users))
+	for _, user := range users {
+		summaries = append(summaries, userSummary(user))
+	}
+	return summaries, nil
+}
+
+func (s AdminService) GetUserAccount(actorID int, userID int) (*model.UserAccount, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	err = audit(s.repo, &actorID, model.AuditActionViewAccount, &userID, nil)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := s.repo.GetBalanceByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.repo.GetOrdersByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	withdrawals, err := s.repo.GetWithdrawalsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UserAccount{
+		User:        userSummary(*user),
+		Balance:     balance,
+		Orders:      orders,
+		Withdrawals: withdrawals,
+	}, nil
+}
+
+// AdjustBalance credits or debits user balance. Debit cannot make balance negative.
+func (s AdminService) AdjustBalance(actorID int, userID int, adjustment model.BalanceAdjustment) (*model.Balance, error) {
+	validationErr := &errors.ValidationError{}
+	if adjustment.Amount == 0 {
+		validationErr.Add("amount", "must not be zero")
+	}
+	if strings.TrimSpace(adjustment.Reason) == "" {
+		validationErr.Add("reason", "must not be empty")
+	}
+	if validationErr.HasErrors() {
+		return nil, validationErr
+	}
+	_, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var balance *model.Balance
+	ctx := context.Background()
+	err = s.repo.Atomic(ctx, func(r dao.Repository) error {
+		balance, err = r.LockBalanceByUserID(userID)
+		if err != nil {
+			return err
+		}
+		previous := balance.Balance
+		balance.Balance += adjustment.Amount
+		if balance.Balance < 0 {
+			return &errors.LowBalanceError{CurrentBalance: previous}
+		}
+		err = r.SaveBalance(balance)
+		if err != nil {
+			return err
+		}
+		return audit(r, &actorID, model.AuditActionAdjustBalance, &userID, map[string]interface{}{
+			"amount":           adjustment.Amount,
+			"reason":           adjustment.Reason,
+			"previous_balance": previous,
+			"balance":          balance.Balance,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
+// ChangeOrderStatus sets order status bypassing accrual system. Transition rules are not applied,
+// so support can also reopen orders in terminal status. Accrual and balance stay untouched.
+func (s AdminService) ChangeOrderStatus(actorID int, number string, change model.OrderStatusChange) (*model.Order, error) {
+	validationErr := &errors.ValidationError{}
+	if !model.ValidOrderStatus(change.Status) {
+		validationErr.Add("status", fmt.Sprintf("must be one of %s, %s, %s, %s",
+			model.OrderStatusNew,
+			model.OrderStatusProcessing,
+			model.OrderStatusProcessed,
+			model.OrderStatusInvalid,
+		))
+	}
+	if strings.TrimSpace(change.Reason) == "" {
+		validationErr.Add("reason", "must not be empty")
+	}
+	if validationErr.HasErrors() {
+		return nil, validationErr
+	}
+
+	var order *model.Order
+	ctx := context.Background()
+	err := s.repo.Atomic(ctx, func(r dao.Repository) error {
+		var err error
+		order, err = r.GetOrderByNumber(number)
+		if err != nil {
+			return err
+		}
+		if order.ID == nil {
+			return &errors.NoOrdersError{}
+		}
+		previous := order.Status
+		order.Status = change.Status
+		err = r.SaveOrder(order)
+		if err != nil {
+			return err
+		}
+		err = r.SaveOrderStatusTransition(order, &model.OrderStatusTransition{
+			FromStatus: previous,
+			ToStatus:   order.Status,
+			Accrual:    order.Accrual,
+			ChangedAt:  time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		return audit(r, &actorID, model.AuditActionChangeOrderStatus, order.User.ID, map[string]interface{}{
+			"order":           number,
+			"status":          change.Status,
+			"previous_status": previous,
+			"reason":          change.Reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// ReassignOrder moves order to another user, e.g. to its real owner, when number was uploaded
+// by mistake. Balances are not adjusted, already credited accrual has to be moved with AdjustBalance.
+func (s AdminService) ReassignOrder(actorID int, number string, reassignment model.OrderReassignment) (*model.Order, error) {
+	validationErr := &errors.ValidationError{}
+	if strings.TrimSpace(reassignment.Login) == "" {
+		validationErr.Add("login", "must not be empty")
+	}
+	if strings.TrimSpace(reassignment.Reason) == "" {
+		validationErr.Add("reason", "must not be empty")
+	}
+	if validationErr.HasErrors() {
+		return nil, validationErr
+	}
+
+	var order *model.Order
+	ctx := context.Background()
+	err := s.repo.Atomic(ctx, func(r dao.Repository) error {
+		var err error
+		order, err = r.GetOrderByNumber(number)
+		if err != nil {
+			return err
+		}
+		if order.ID == nil {
+			return &errors.NoOrdersError{}
+		}
+		user, err := r.GetUserByLogin(reassignment.Login)
+		if err != nil {
+			return err
+		}
+		if user.ID == nil {
+			return &errors.UserNotFoundError{User: reassignment.Login}
+		}
+		previousUserID := order.User.ID
+		if previousUserID != nil && *previousUserID == *user.ID {
+			validationErr.Add("login", "order already belongs to this user")
+			return validationErr
+		}
+		order.User = user
+		err = r.SaveOrder(order)
+		if err != nil {
+			return err
+		}
+		return audit(r, &actorID, model.AuditActionReassignOrder, user.ID, map[string]interface{}{
+			"order":            number,
+			"previous_user_id": previousUserID,
+			"reason":           reassignment.Reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// SetRole assigns role to user, actorID is nil when authorized by admin token.
+func (s AdminService) SetRole(actorID *int, login string, role string) error {
+	err := s.authService.SetRole(login, role)
+	if err != nil {
+		return err
+	}
+	user, err := s.repo.GetUserByLogin(login)
+	if err != nil {
+		return err
+	}
+	return audit(s.repo, actorID, model.AuditActionSetRole, user.ID, map[string]interface{}{
+		"role": role,
+	})
+}
+
+func (s AdminService) ClearLockout(actorID int, login string) error {
+	err := s.loginThrottle.ClearLockout(login)
+	if err != nil {
+		return err
+	}
+	user, err := s.repo.GetUserByLogin(login)
+	if err != nil {
+		return err
+	}
+	return audit(s.repo, &actorID, model.AuditActionClearLockout, user.ID, map[string]interface{}{
+		"login": login,
+	})
+}
+
+// SetLoyaltyCard binds loyalty card number to user, so merchants can upload orders by it.
+// Empty number unbinds current card.
+func (s AdminService) SetLoyaltyCard(actorID int, userID int, loyaltyCard string) error {
+	loyaltyCard = strings.TrimSpace(loyaltyCard)
+	if loyaltyCard != "" && !isLoyaltyCardNumber(loyaltyCard) {
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("loyalty_card", fmt.Sprintf("must be %d to %d digits", loyaltyCardMinLength, loyaltyCardMaxLength))
+		return validationErr
+	}
+	_, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	return s.repo.Atomic(ctx, func(r dao.Repository) error {
+		if loyaltyCard != "" {
+			owner, err := r.GetUserByLoyaltyCard(loyaltyCard)
+			if err != nil {
+				return err
+			}
+			if owner.ID != nil && *owner.ID != userID {
+				return &errors.LoyaltyCardTakenError{LoyaltyCard: loyaltyCard}
+			}
+		}
+		err := r.UpdateUserLoyaltyCard(userID, loyaltyCard)
+		if err != nil {
+			return err
+		}
+		return audit(r, &actorID, model.AuditActionSetLoyaltyCard, &userID, map[string]interface{}{
+			"loyalty_card": loyaltyCard,
+		})
+	})
+}
+
+func (s AdminService) GetAuditTrail(actorID int, userID *int) ([]model.AuditEntry, error) {
+	err := audit(s.repo, &actorID, model.AuditActionViewAuditTrail, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetAuditEntries(userID, auditTrailLimit)
+}
+
+func (s AdminService) GetStats(actorID int) (*model.Stats, error) {
+	err := audit(s.repo, &actorID, model.AuditActionViewStats, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	rejected, err := s.repo.CountRejectedStatusUpdates()
+	if err != nil {
+		return nil, err
+	}
+	return &model.Stats{
+		RejectedStatusUpdates: rejected,
+	}, nil
+}
+
+func (s AdminService) getUser(userID int) (*model.User, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == nil {
+		return nil, &errors.UserNotFoundError{User: strconv.Itoa(userID)}
+	}
+	return user, nil
+}
+
+func audit(r dao.Repository, actorID *int, action string, targetUserID *int, details map[string]interface{}) error {
+	return r.SaveAuditEntry(&model.AuditEntry{
+		ActorID:      actorID,
+		Action:       action,
+		TargetUserID: targetUserID,
+		Details:      details,
+		CreatedAt:    time.Now(),
+	})
+}
+
+func isLoyaltyCardNumber(number string) bool {
+	if len(number) < loyaltyCardMinLength || len(number) > loyaltyCardMaxLength {
+		return false
+	}
+	for _, c := range number {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func userSummary(user model.User) model.UserSummary {
+	return model.UserSummary{
+		ID:    *user.ID,
+		Login: user.Login,
+		Role:  user.Role,
+	}
+}
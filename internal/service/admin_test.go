@@ -0,0 +1,370 @@
+package service
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"testing"
+)
+
+func TestAdminService_SearchUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().SaveAuditEntry(gomock.Any()).DoAndReturn(func(entry *model.AuditEntry) error {
+			assert.Equal(t, 2, *entry.ActorID)
+			assert.Equal(t, model.AuditActionSearchUsers, entry.Action)
+			assert.Equal(t, "test", entry.Details["login"])
+			return nil
+		}),
+		repo.EXPECT().SearchUsersByLogin("test", adminSearchLimit).Return([]model.User{
+			{ID: GetIntPointer(1), Login: "test", Role: model.RoleUser},
+		}, nil),
+	)
+	s := NewAdminService(repo, nil, nil)
+	users, err := s.SearchUsers(2, " test ")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.UserSummary{{ID: 1, Login: "test", Role: model.RoleUser}}, users)
+}
+
+func TestAdminService_AdjustBalance(t *testing.T) {
+	tests := []struct {
+		name        string
+		adjustment  model.BalanceAdjustment
+		prepare     func(repo *mock_dao.MockRepository)
+		wantBalance float32
+		wantErrType error
+	}{
+		{
+			name:       "should credit balance and write audit",
+			adjustment: model.BalanceAdjustment{Amount: 50, Reason: "compensation"},
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1)}, nil),
+					expectAtomic(repo),
+					repo.EXPECT().LockBalanceByUserID(1).Return(&model.Balance{
+						User:    model.User{ID: GetIntPointer(1)},
+						Balance: 100,
+					}, nil),
+					repo.EXPECT().SaveBalance(gomock.Any()).Return(nil),
+					repo.EXPECT().SaveAuditEntry(gomock.Any()).DoAndReturn(func(entry *model.AuditEntry) error {
+						assert.Equal(t, model.AuditActionAdjustBalance, entry.Action)
+						assert.Equal(t, 1, *entry.TargetUserID)
+						assert.Equal(t, "compensation", entry.Details["reason"])
+						return nil
+					}),
+				)
+			},
+			wantBalance: 150,
+			wantErrType: nil,
+		},
+		{
+			name:       "should not debit below zero",
+			adjustment: model.BalanceAdjustment{Amount: -150, Reason: "fraud"},
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1)}, nil),
+					expectAtomic(repo),
+					repo.EXPECT().LockBalanceByUserID(1).Return(&model.Balance{
+						User:    model.User{ID: GetIntPointer(1)},
+						Balance: 100,
+					}, nil),
+				)
+			},
+			wantErrType: &errors.LowBalanceError{},
+		},
+		{
+			name:        "should require reason",
+			adjustment:  model.BalanceAdjustment{Amount: 50},
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			wantErrType: &errors.ValidationError{},
+		},
+		{
+			name:       "should return UserNotFoundError for unknown user",
+			adjustment: model.BalanceAdjustment{Amount: 50, Reason: "compensation"},
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetUserByID(1).Return(&model.User{}, nil)
+			},
+			wantErrType: &errors.UserNotFoundError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewAdminService(repo, nil, nil)
+			balance, err := s.AdjustBalance(2, 1, tt.adjustment)
+			assert.IsType(t, tt.wantErrType, err)
+			if err == nil {
+				assert.Equal(t, tt.wantBalance, balance.Balance)
+			}
+		})
+	}
+}
+
+func TestAdminService_ChangeOrderStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		change      model.OrderStatusChange
+		prepare     func(repo *mock_dao.MockRepository)
+		wantErrType error
+	}{
+		{
+			name:   "should change status and write audit",
+			change: model.OrderStatusChange{Status: model.OrderStatusInvalid, Reason: "stuck in accrual"},
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().GetOrderByNumber("2377225624").Return(&model.Order{
+						ID:     GetIntPointer(3),
+						User:   &model.User{ID: GetIntPointer(1)},
+						Number: "2377225624",
+						Status: model.OrderStatusProcessing,
+					}, nil),
+					repo.EXPECT().SaveOrder(gomock.Any()).DoAndReturn(func(order *model.Order) error {
+						assert.Equal(t, model.OrderStatusInvalid, order.Status)
+						return nil
+					}),
+					repo.EXPECT().SaveOrderStatusTransition(gomock.Any(), gomock.Any()).Return(nil),
+					repo.EXPECT().SaveAuditEntry(gomock.Any()).DoAndReturn(func(entry *model.AuditEntry) error {
+						assert.Equal(t, model.AuditActionChangeOrderStatus, entry.Action)
+						assert.Equal(t, model.OrderStatusProcessing, entry.Details["previous_status"])
+						return nil
+					}),
+				)
+			},
+			wantErrType: nil,
+		},
+		{
+			name:   "should return NoOrdersError for unknown order",
+			change: model.OrderStatusChange{Status: model.OrderStatusInvalid, Reason: "stuck in accrual"},
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().GetOrderByNumber("2377225624").Return(&model.Order{Number: "2377225624"}, nil),
+				)
+			},
+			wantErrType: &errors.NoOrdersError{},
+		},
+		{
+			name:        "should reject unknown status",
+			change:      model.OrderStatusChange{Status: "DONE", Reason: "stuck in accrual"},
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			wantErrType: &errors.ValidationError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewAdminService(repo, nil, nil)
+			_, err := s.ChangeOrderStatus(2, "2377225624", tt.change)
+			assert.IsType(t, tt.wantErrType, err)
+		})
+	}
+}
+
+func TestAdminService_ReassignOrder(t *testing.T) {
+	reassignment := model.OrderReassignment{Login: "owner", Reason: "uploaded by mistake"}
+	tests := []struct {
+		name         string
+		reassignment model.OrderReassignment
+		prepare      func(repo *mock_dao.MockRepository)
+		wantErrType  error
+	}{
+		{
+			name:         "should move order to another user",
+			reassignment: reassignment,
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().GetOrderByNumber("2377225624").Return(&model.Order{
+						ID:     GetIntPointer(3),
+						User:   &model.User{ID: GetIntPointer(1)},
+						Number: "2377225624",
+						Status: model.OrderStatusNew,
+					}, nil),
+					repo.EXPECT().GetUserByLogin("owner").Return(&model.User{ID: GetIntPointer(5), Login: "owner"}, nil),
+					repo.EXPECT().SaveOrder(gomock.Any()).DoAndReturn(func(order *model.Order) error {
+						assert.Equal(t, 5, *order.User.ID)
+						return nil
+					}),
+					repo.EXPECT().SaveAuditEntry(gomock.Any()).DoAndReturn(func(entry *model.AuditEntry) error {
+						assert.Equal(t, model.AuditActionReassignOrder, entry.Action)
+						assert.Equal(t, 5, *entry.TargetUserID)
+						return nil
+					}),
+				)
+			},
+			wantErrType: nil,
+		},
+		{
+			name:         "should return UserNotFoundError for unknown login",
+			reassignment: reassignment,
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().GetOrderByNumber("2377225624").Return(&model.Order{
+						ID:     GetIntPointer(3),
+						User:   &model.User{ID: GetIntPointer(1)},
+						Number: "2377225624",
+					}, nil),
+					repo.EXPECT().GetUserByLogin("owner").Return(&model.User{Login: "owner"}, nil),
+				)
+			},
+			wantErrType: &errors.UserNotFoundError{},
+		},
+		{
+			name:         "should reject reassignment to the same user",
+			reassignment: reassignment,
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().GetOrderByNumber("2377225624").Return(&model.Order{
+						ID:     GetIntPointer(3),
+						User:   &model.User{ID: GetIntPointer(5)},
+						Number: "2377225624",
+					}, nil),
+					repo.EXPECT().GetUserByLogin("owner").Return(&model.User{ID: GetIntPointer(5), Login: "owner"}, nil),
+				)
+			},
+			wantErrType: &errors.ValidationError{},
+		},
+		{
+			name:         "should require reason",
+			reassignment: model.OrderReassignment{Login: "owner"},
+			prepare:      func(repo *mock_dao.MockRepository) {},
+			wantErrType:  &errors.ValidationError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewAdminService(repo, nil, nil)
+			_, err := s.ReassignOrder(2, "2377225624", tt.reassignment)
+			assert.IsType(t, tt.wantErrType, err)
+		})
+	}
+}
+
+func TestAdminService_SetRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().GetUserByLogin("test").Return(&model.User{ID: GetIntPointer(1), Login: "test"}, nil),
+		expectAtomic(repo),
+		repo.EXPECT().UpdateUserRole(1, model.RoleAdmin).Return(nil),
+		repo.EXPECT().RevokeUserSessions(1).Return(nil),
+		repo.EXPECT().GetUserByLogin("test").Return(&model.User{ID: GetIntPointer(1), Login: "test"}, nil),
+		repo.EXPECT().SaveAuditEntry(gomock.Any()).DoAndReturn(func(entry *model.AuditEntry) error {
+			assert.Nil(t, entry.ActorID)
+			assert.Equal(t, model.AuditActionSetRole, entry.Action)
+			return nil
+		}),
+	)
+	s := NewAdminService(repo, NewAuthService(repo, authConfig), nil)
+	assert.NoError(t, s.SetRole(nil, "test", model.RoleAdmin))
+}
+
+func TestAdminService_SetLoyaltyCard(t *testing.T) {
+	tests := []struct {
+		name        string
+		loyaltyCard string
+		prepare     func(repo *mock_dao.MockRepository)
+		wantErrType error
+	}{
+		{
+			name:        "should bind loyalty card",
+			loyaltyCard: "12345678",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1)}, nil),
+					expectAtomic(repo),
+					repo.EXPECT().GetUserByLoyaltyCard("12345678").Return(&model.User{}, nil),
+					repo.EXPECT().UpdateUserLoyaltyCard(1, "12345678").Return(nil),
+					repo.EXPECT().SaveAuditEntry(gomock.Any()).Return(nil),
+				)
+			},
+			wantErrType: nil,
+		},
+		{
+			name:        "should reject card of another user",
+			loyaltyCard: "12345678",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1)}, nil),
+					expectAtomic(repo),
+					repo.EXPECT().GetUserByLoyaltyCard("12345678").Return(&model.User{ID: GetIntPointer(2)}, nil),
+				)
+			},
+			wantErrType: &errors.LoyaltyCardTakenError{},
+		},
+		{
+			name:        "should reject malformed card",
+			loyaltyCard: "12ab",
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			wantErrType: &errors.ValidationError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewAdminService(repo, nil, nil)
+			err := s.SetLoyaltyCard(2, 1, tt.loyaltyCard)
+			assert.IsType(t, tt.wantErrType, err)
+		})
+	}
+}
+
+func TestAdminService_GetAuditTrail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().SaveAuditEntry(gomock.Any()).DoAndReturn(func(entry *model.AuditEntry) error {
+			assert.Equal(t, 2, *entry.ActorID)
+			assert.Equal(t, model.AuditActionViewAuditTrail, entry.Action)
+			assert.Equal(t, 1, *entry.TargetUserID)
+			return nil
+		}),
+		repo.EXPECT().GetAuditEntries(GetIntPointer(1), auditTrailLimit).Return([]model.AuditEntry{
+			{Action: model.AuditActionAdjustBalance},
+		}, nil),
+	)
+	s := NewAdminService(repo, nil, nil)
+	entries, err := s.GetAuditTrail(2, GetIntPointer(1))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestAdminService_GetStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().SaveAuditEntry(gomock.Any()).DoAndReturn(func(entry *model.AuditEntry) error {
+			assert.Equal(t, 2, *entry.ActorID)
+			assert.Equal(t, model.AuditActionViewStats, entry.Action)
+			return nil
+		}),
+		repo.EXPECT().CountRejectedStatusUpdates().Return(uint64(3), nil),
+	)
+	s := NewAdminService(repo, nil, nil)
+	stats, err := s.GetStats(2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.RejectedStatusUpdates)
+}
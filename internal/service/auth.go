@@ -1,9 +1,10 @@
 package service
 
 import (
-	"crypto/sha256"
-	"encoding/base64"
+	"context"
+	"fmt"
 	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
 	"github.com/yurchenkosv/gofermart/internal/dao"
 	"github.com/yurchenkosv/gofermart/internal/errors"
 	"github.com/yurchenkosv/gofermart/internal/model"
@@ -12,18 +13,29 @@ import (
 type Auth interface {
 	RegisterUser(user *model.User) (*model.User, error)
 	AuthenticateUser(user *model.User) (*model.User, error)
+	ChangePassword(userID int, change model.PasswordChange) error
+	DeleteUser(userID int) error
+	SetRole(login string, role string) error
 }
 
 type AuthService struct {
 	repo dao.Repository
+	cfg  *config.ServerConfig
 }
 
-func NewAuthService(repo dao.Repository) Auth {
-	return AuthService{repo: repo}
+func NewAuthService(repo dao.Repository, cfg *config.ServerConfig) Auth {
+	return AuthService{
+		repo: repo,
+		cfg:  cfg,
+	}
 }
 
 func (auth AuthService) RegisterUser(user *model.User) (*model.User, error) {
-	savedUser, err := auth.repo.GetUser(user)
+	err := validateUser(user, auth.cfg)
+	if err != nil {
+		return nil, err
+	}
+	savedUser, err := auth.repo.GetUserByLogin(user.Login)
 	if err != nil {
 		log.Error(err)
 		return nil, err
@@ -32,27 +44,147 @@ func (auth AuthService) RegisterUser(user *model.User) (*model.User, error) {
 		err := errors.UserAlreadyExistsError{User: user.Login}
 		return nil, &err
 	}
-	user.Password = hashPW(user.Password)
+	user.Password, err = hashPassword(user.Password)
+	if err != nil {
+		return nil, err
+	}
 	err = auth.repo.SaveUser(user)
 	if err != nil {
 		return nil, err
 	}
 
-	savedUser, _ = auth.repo.GetUser(user)
+	savedUser, _ = auth.repo.GetUserByLogin(user.Login)
 	return savedUser, nil
 }
 
 func (auth AuthService) AuthenticateUser(user *model.User) (*model.User, error) {
-	user.Password = hashPW(user.Password)
-	user, _ = auth.repo.GetUser(user)
-	if user.ID == nil {
+	err := validateCredentials(user)
+	if err != nil {
+		return nil, err
+	}
+	savedUser, err := auth.repo.GetUserByLogin(user.Login)
+	if err != nil {
+		return nil, err
+	}
+	if savedUser.ID == nil {
 		err := errors.InvalidUserError{}
 		return nil, &err
 	}
-	return user, nil
+
+	ok, needRehash, err := verifyPassword(user.Password, savedUser.Password)
+	if err != nil {
+		log.Errorf("cannot verify password of user %s: %s", savedUser.Login, err)
+	}
+	if !ok {
+		err := errors.InvalidUserError{}
+		return nil, &err
+	}
+
+	if needRehash {
+		pwHash, err := hashPassword(user.Password)
+		if err != nil {
+			log.Error("cannot rehash password: ", err)
+			return savedUser, nil
+		}
+		savedUser.Password = pwHash
+		err = auth.repo.UpdateUserPassword(savedUser)
+		if err != nil {
+			log.Error("cannot save rehashed password: ", err)
+		}
+	}
+	return savedUser, nil
+}
+
+// ChangePassword sets new password after checking the current one and terminates
+// all sessions of user.
+func (auth AuthService) ChangePassword(userID int, change model.PasswordChange) error {
+	validationErr := &errors.ValidationError{}
+	validatePassword("new_password", change.NewPassword, auth.cfg, validationErr)
+	if validationErr.HasErrors() {
+		return validationErr
+	}
+
+	user, err := auth.repo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.ID == nil {
+		return &errors.InvalidUserError{}
+	}
+	ok, _, err := verifyPassword(change.CurrentPassword, user.Password)
+	if err != nil {
+		log.Errorf("cannot verify password of user %s: %s", user.Login, err)
+	}
+	if !ok {
+		return &errors.InvalidUserError{}
+	}
+
+	user.Password, err = hashPassword(change.NewPassword)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	return auth.repo.Atomic(ctx, func(r dao.Repository) error {
+		err := r.UpdateUserPassword(user)
+		if err != nil {
+			return err
+		}
+		return r.RevokeUserSessions(userID)
+	})
+}
+
+// DeleteUser anonymizes user and, depending on retention policy, deletes or keeps
+// orders, balance and withdrawals of user.
+func (auth AuthService) DeleteUser(userID int) error {
+	ctx := context.Background()
+	return auth.repo.Atomic(ctx, func(r dao.Repository) error {
+		err := r.RevokeUserSessions(userID)
+		if err != nil {
+			return err
+		}
+		err = r.DeleteTwoFactor(userID)
+		if err != nil {
+			return err
+		}
+		err = r.DeleteOIDCIdentities(userID)
+		if err != nil {
+			return err
+		}
+		err = r.DeleteWebhooksByUserID(userID)
+		if err != nil {
+			return err
+		}
+		if auth.cfg.AccountRetentionPolicy == config.RetentionPolicyCascade {
+			err = r.DeleteUserData(userID)
+			if err != nil {
+				return err
+			}
+		}
+		return r.AnonymizeUser(userID)
+	})
 }
 
-func hashPW(pw string) string {
-	pwHash := sha256.Sum256([]byte(pw))
-	return base64.StdEncoding.EncodeToString(pwHash[:])
+// SetRole assigns role to user. Sessions of the user are terminated, so tokens
+// carrying the previous role cannot be used or refreshed.
+func (auth AuthService) SetRole(login string, role string) error {
+	if !model.ValidRole(role) {
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("role", fmt.Sprintf("must be one of %s, %s, %s", model.RoleUser, model.RoleSupport, model.RoleAdmin))
+		return validationErr
+	}
+	user, err := auth.repo.GetUserByLogin(login)
+	if err != nil {
+		return err
+	}
+	if user.ID == nil {
+		return &errors.UserNotFoundError{User: login}
+	}
+	ctx := context.Background()
+	return auth.repo.Atomic(ctx, func(r dao.Repository) error {
+		err := r.UpdateUserRole(*user.ID, role)
+		if err != nil {
+			return err
+		}
+		return r.RevokeUserSessions(*user.ID)
+	})
 }
@@ -3,23 +3,32 @@ package service
 import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/errors"
 	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
 	"github.com/yurchenkosv/gofermart/internal/model"
+	"strings"
 	"testing"
 )
 
+var authConfig = &config.ServerConfig{
+	PasswordMinLength:    8,
+	PasswordRequireMixed: true,
+}
+
 func TestAuthenticateUser(t *testing.T) {
 	type mockBehavior func(s *mock_dao.MockRepository, user *model.User, id int)
 	type args struct {
 		user *model.User
 	}
 	tests := []struct {
-		name     string
-		id       int
-		args     args
-		behavior mockBehavior
-		want     *model.User
-		wantErr  bool
+		name        string
+		id          int
+		args        args
+		behavior    mockBehavior
+		want        *model.User
+		wantErr     bool
+		wantErrType error
 	}{
 		{
 			args: args{user: &model.User{
@@ -28,16 +37,69 @@ func TestAuthenticateUser(t *testing.T) {
 			}},
 			id: 1,
 			behavior: func(s *mock_dao.MockRepository, user *model.User, id int) {
-				user.ID = &id
-				s.EXPECT().GetUser(user).Return(user, nil)
+				pwHash, _ := hashPassword(user.Password)
+				s.EXPECT().GetUserByLogin(user.Login).Return(&model.User{
+					ID:       &id,
+					Login:    user.Login,
+					Password: pwHash,
+				}, nil)
 			},
 			name: "should successfully return user",
 			want: &model.User{
+				Login: "test",
+			},
+			wantErr: false,
+		},
+		{
+			args: args{user: &model.User{
 				Login:    "test",
-				Password: "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=",
+				Password: "test",
+			}},
+			id: 1,
+			behavior: func(s *mock_dao.MockRepository, user *model.User, id int) {
+				s.EXPECT().GetUserByLogin(user.Login).Return(&model.User{
+					ID:       &id,
+					Login:    user.Login,
+					Password: "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=",
+				}, nil)
+				s.EXPECT().UpdateUserPassword(gomock.Any()).Return(nil)
+			},
+			name: "should rehash legacy password",
+			want: &model.User{
+				Login: "test",
 			},
 			wantErr: false,
 		},
+		{
+			args: args{user: &model.User{
+				Login:    "test",
+				Password: "wrong",
+			}},
+			id: 1,
+			behavior: func(s *mock_dao.MockRepository, user *model.User, id int) {
+				pwHash, _ := hashPassword("test")
+				s.EXPECT().GetUserByLogin(user.Login).Return(&model.User{
+					ID:       &id,
+					Login:    user.Login,
+					Password: pwHash,
+				}, nil)
+			},
+			name:        "should return InvalidUserError on wrong password",
+			wantErr:     true,
+			wantErrType: &errors.InvalidUserError{},
+		},
+		{
+			args: args{user: &model.User{
+				Login:    "unknown",
+				Password: "test",
+			}},
+			behavior: func(s *mock_dao.MockRepository, user *model.User, id int) {
+				s.EXPECT().GetUserByLogin(user.Login).Return(&model.User{Login: user.Login}, nil)
+			},
+			name:        "should return InvalidUserError on unknown user",
+			wantErr:     true,
+			wantErrType: &errors.InvalidUserError{},
+		},
 	}
 
 	for _, tt := range tests {
@@ -46,15 +108,19 @@ func TestAuthenticateUser(t *testing.T) {
 			defer ctrl.Finish()
 			authRepo := mock_dao.NewMockRepository(ctrl)
 			tt.behavior(authRepo, tt.args.user, tt.id)
-			authService := NewAuthService(authRepo)
+			authService := NewAuthService(authRepo, authConfig)
 			got, err := authService.AuthenticateUser(tt.args.user)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("AuthenticateUser() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				assert.IsType(t, tt.wantErrType, err)
+				return
+			}
 			assert.NotNil(t, got.ID, "user id is nil")
 			assert.Equal(t, tt.want.Login, got.Login)
-			assert.Equal(t, tt.want.Password, got.Password)
+			assert.True(t, strings.HasPrefix(got.Password, argonPrefix), "password is not argon2id hash")
 		})
 	}
 }
@@ -65,23 +131,24 @@ func TestRegisterUser(t *testing.T) {
 		user *model.User
 	}
 	tests := []struct {
-		name     string
-		id       int
-		args     args
-		behavior mockBehavior
-		want     *model.User
-		wantErr  bool
+		name        string
+		id          int
+		args        args
+		behavior    mockBehavior
+		want        *model.User
+		wantErr     bool
+		wantErrType error
 	}{
 		{
 			args: args{user: &model.User{
 				Login:    "test",
-				Password: "test",
+				Password: "test1234",
 			}},
 			id: 1,
 			behavior: func(s *mock_dao.MockRepository, user *model.User, id int) {
-				s.EXPECT().GetUser(user).Return(user, nil)
+				s.EXPECT().GetUserByLogin(user.Login).Return(&model.User{Login: user.Login}, nil)
 				s.EXPECT().SaveUser(user).Return(nil)
-				s.EXPECT().GetUser(user).Return(&model.User{
+				s.EXPECT().GetUserByLogin(user.Login).Return(&model.User{
 					ID:       &id,
 					Login:    user.Login,
 					Password: user.Password,
@@ -90,6 +157,16 @@ func TestRegisterUser(t *testing.T) {
 			name:    "should successfully save user",
 			wantErr: false,
 		},
+		{
+			args: args{user: &model.User{
+				Login:    "",
+				Password: "test",
+			}},
+			behavior:    func(s *mock_dao.MockRepository, user *model.User, id int) {},
+			name:        "should return ValidationError",
+			wantErr:     true,
+			wantErrType: &errors.ValidationError{},
+		},
 	}
 
 	for _, tt := range tests {
@@ -97,15 +174,172 @@ func TestRegisterUser(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			authRepo := mock_dao.NewMockRepository(ctrl)
-			authService := NewAuthService(authRepo)
+			authService := NewAuthService(authRepo, authConfig)
 			tt.behavior(authRepo, tt.args.user, tt.id)
 			got, err := authService.RegisterUser(tt.args.user)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("AuthenticateUser() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				assert.IsType(t, tt.wantErrType, err)
+				return
+			}
 			assert.Nil(t, err)
 			assert.NotNil(t, got.ID, "user id is nil")
+			assert.True(t, strings.HasPrefix(tt.args.user.Password, argonPrefix), "password is not argon2id hash")
+		})
+	}
+}
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	type args struct {
+		change model.PasswordChange
+	}
+	tests := []struct {
+		name        string
+		args        args
+		prepare     func(s *mock_dao.MockRepository)
+		wantErr     assert.ErrorAssertionFunc
+		wantErrType error
+	}{
+		{
+			name: "should change password and revoke sessions",
+			args: args{change: model.PasswordChange{CurrentPassword: "test1234", NewPassword: "new12345"}},
+			prepare: func(s *mock_dao.MockRepository) {
+				pwHash, _ := hashPassword("test1234")
+				gomock.InOrder(
+					s.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1), Login: "test", Password: pwHash}, nil),
+					expectAtomic(s),
+					s.EXPECT().UpdateUserPassword(gomock.Any()).Return(nil),
+					s.EXPECT().RevokeUserSessions(1).Return(nil),
+				)
+			},
+			wantErr:     assert.NoError,
+			wantErrType: nil,
+		},
+		{
+			name: "should return InvalidUserError on wrong current password",
+			args: args{change: model.PasswordChange{CurrentPassword: "wrong", NewPassword: "new12345"}},
+			prepare: func(s *mock_dao.MockRepository) {
+				pwHash, _ := hashPassword("test1234")
+				s.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1), Login: "test", Password: pwHash}, nil)
+			},
+			wantErr:     assert.Error,
+			wantErrType: &errors.InvalidUserError{},
+		},
+		{
+			name:        "should return ValidationError on weak new password",
+			args:        args{change: model.PasswordChange{CurrentPassword: "test1234", NewPassword: "weak"}},
+			prepare:     func(s *mock_dao.MockRepository) {},
+			wantErr:     assert.Error,
+			wantErrType: &errors.ValidationError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			err := NewAuthService(repo, authConfig).ChangePassword(1, tt.args.change)
+			tt.wantErr(t, err)
+			assert.IsType(t, tt.wantErrType, err)
+		})
+	}
+}
+
+func TestAuthService_DeleteUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		prepare func(s *mock_dao.MockRepository)
+	}{
+		{
+			name:   "should keep user data with retain policy",
+			policy: config.RetentionPolicyRetain,
+			prepare: func(s *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(s),
+					s.EXPECT().RevokeUserSessions(1).Return(nil),
+					s.EXPECT().DeleteTwoFactor(1).Return(nil),
+					s.EXPECT().DeleteOIDCIdentities(1).Return(nil),
+					s.EXPECT().DeleteWebhooksByUserID(1).Return(nil),
+					s.EXPECT().AnonymizeUser(1).Return(nil),
+				)
+			},
+		},
+		{
+			name:   "should delete user data with cascade policy",
+			policy: config.RetentionPolicyCascade,
+			prepare: func(s *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(s),
+					s.EXPECT().RevokeUserSessions(1).Return(nil),
+					s.EXPECT().DeleteTwoFactor(1).Return(nil),
+					s.EXPECT().DeleteOIDCIdentities(1).Return(nil),
+					s.EXPECT().DeleteWebhooksByUserID(1).Return(nil),
+					s.EXPECT().DeleteUserData(1).Return(nil),
+					s.EXPECT().AnonymizeUser(1).Return(nil),
+				)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			cfg := &config.ServerConfig{AccountRetentionPolicy: tt.policy}
+			assert.NoError(t, NewAuthService(repo, cfg).DeleteUser(1))
+		})
+	}
+}
+
+func TestAuthService_SetRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        string
+		prepare     func(s *mock_dao.MockRepository)
+		wantErrType error
+	}{
+		{
+			name: "should set role and revoke sessions",
+			role: model.RoleSupport,
+			prepare: func(s *mock_dao.MockRepository) {
+				gomock.InOrder(
+					s.EXPECT().GetUserByLogin("test").Return(&model.User{ID: GetIntPointer(1), Login: "test"}, nil),
+					expectAtomic(s),
+					s.EXPECT().UpdateUserRole(1, model.RoleSupport).Return(nil),
+					s.EXPECT().RevokeUserSessions(1).Return(nil),
+				)
+			},
+			wantErrType: nil,
+		},
+		{
+			name:        "should reject unknown role",
+			role:        "root",
+			prepare:     func(s *mock_dao.MockRepository) {},
+			wantErrType: &errors.ValidationError{},
+		},
+		{
+			name: "should return UserNotFoundError for unknown login",
+			role: model.RoleAdmin,
+			prepare: func(s *mock_dao.MockRepository) {
+				s.EXPECT().GetUserByLogin("test").Return(&model.User{Login: "test"}, nil)
+			},
+			wantErrType: &errors.UserNotFoundError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			err := NewAuthService(repo, authConfig).SetRole("test", tt.role)
+			assert.IsType(t, tt.wantErrType, err)
 		})
 	}
 }
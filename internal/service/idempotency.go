@@ -0,0 +1,144 @@
+package service
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"sync"
+	"time"
+)
+
+const idempotencyKeyMaxLength = 255
+
+// Idempotency makes retries of mutating requests safe. Request with Idempotency-Key header
+// is executed once, retries with the same key get stored response.
+type Idempotency interface {
+	Begin(userID int, key string, fingerprint string) (*model.IdempotencyKey, error)
+	KeepLease(attempt *model.IdempotencyKey) (stop func())
+	Complete(attempt *model.IdempotencyKey, statusCode int, contentType string, body []byte) error
+	Release(attempt *model.IdempotencyKey) error
+	PurgeExpired() error
+}
+
+type IdempotencyService struct {
+	repo dao.Repository
+	cfg  *config.ServerConfig
+}
+
+func NewIdempotencyService(repo dao.Repository, cfg *config.ServerConfig) Idempotency {
+	return IdempotencyService{
+		repo: repo,
+		cfg:  cfg,
+	}
+}
+
+// Begin reserves key for request. It returns stored response, with CompletedAt set, when request
+// is retry of already completed one, otherwise attempt holding lease of the key, which has to be
+// executed. Key of request, whose lease expired, is taken over by retry of the same request.
+func (s IdempotencyService) Begin(userID int, key string, fingerprint string) (*model.IdempotencyKey, error) {
+	if key == "" || len(key) > idempotencyKeyMaxLength {
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("Idempotency-Key", fmt.Sprintf("must be from 1 to %d characters", idempotencyKeyMaxLength))
+		return nil, validationErr
+	}
+	leaseToken, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	currentTime := time.Now()
+	lockedUntil := currentTime.Add(s.cfg.IdempotencyLease)
+	attempt := &model.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   currentTime,
+		ExpiresAt:   currentTime.Add(s.cfg.IdempotencyKeyTTL),
+		LockedUntil: &lockedUntil,
+		LeaseToken:  leaseToken,
+	}
+	created, err := s.repo.CreateIdempotencyKey(attempt)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		return attempt, nil
+	}
+	stored, err := s.repo.GetIdempotencyKey(userID, key)
+	if err != nil {
+		return nil, err
+	}
+	if stored.ID != nil && stored.Fingerprint != fingerprint {
+		return nil, &errors.IdempotencyKeyMismatchError{Key: key}
+	}
+	if stored.ID == nil || stored.CompletedAt == nil {
+		return nil, &errors.IdempotencyKeyInProgressError{Key: key}
+	}
+	return stored, nil
+}
+
+// KeepLease renews lease of attempt until stop is called, so request running longer than
+// cfg.IdempotencyLease is not taken over by retry.
+func (s IdempotencyService) KeepLease(attempt *model.IdempotencyKey) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.cfg.IdempotencyLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				lockedUntil := time.Now().Add(s.cfg.IdempotencyLease)
+				renewed, err := s.repo.RenewIdempotencyKey(&model.IdempotencyKey{
+					ID:          attempt.ID,
+					LeaseToken:  attempt.LeaseToken,
+					LockedUntil: &lockedUntil,
+				})
+				if err != nil {
+					log.Error("cannot renew lease of idempotency key: ", err)
+					continue
+				}
+				if !renewed {
+					log.Warnf("lease of idempotency key %s of user %d is lost", attempt.Key, attempt.UserID)
+					return
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// Complete stores response to request, started with Begin. Response is not stored, when retry
+// has taken the key over meanwhile.
+func (s IdempotencyService) Complete(attempt *model.IdempotencyKey, statusCode int, contentType string, body []byte) error {
+	completedAt := time.Now()
+	completed, err := s.repo.CompleteIdempotencyKey(&model.IdempotencyKey{
+		ID:          attempt.ID,
+		LeaseToken:  attempt.LeaseToken,
+		StatusCode:  statusCode,
+		ContentType: contentType,
+		Body:        body,
+		CompletedAt: &completedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if !completed {
+		log.Warnf("response for idempotency key %s of user %d is not stored, lease is lost", attempt.Key, attempt.UserID)
+	}
+	return nil
+}
+
+// Release forgets key of request, which failed and may be retried with the same key.
+func (s IdempotencyService) Release(attempt *model.IdempotencyKey) error {
+	_, err := s.repo.DeleteIdempotencyKey(attempt)
+	return err
+}
+
+func (s IdempotencyService) PurgeExpired() error {
+	return s.repo.DeleteExpiredIdempotencyKeys()
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyService_Begin(t *testing.T) {
+	completedAt := time.Now()
+	tests := []struct {
+		name        string
+		key         string
+		prepare     func(repo *mock_dao.MockRepository)
+		wantReplay  bool
+		wantErrType error
+	}{
+		{
+			name: "should reserve new key",
+			key:  "key",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().CreateIdempotencyKey(gomock.Any()).DoAndReturn(func(key *model.IdempotencyKey) (bool, error) {
+					assert.Equal(t, 1, key.UserID)
+					assert.Equal(t, "fingerprint", key.Fingerprint)
+					assert.Equal(t, 24*time.Hour, key.ExpiresAt.Sub(key.CreatedAt))
+					assert.Equal(t, time.Minute, key.LockedUntil.Sub(key.CreatedAt))
+					assert.NotEmpty(t, key.LeaseToken)
+					key.ID = GetIntPointer(1)
+					return true, nil
+				})
+			},
+		},
+		{
+			name: "should return stored response for retry",
+			key:  "key",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().CreateIdempotencyKey(gomock.Any()).Return(false, nil),
+					repo.EXPECT().GetIdempotencyKey(1, "key").Return(&model.IdempotencyKey{
+						ID:          GetIntPointer(1),
+						Fingerprint: "fingerprint",
+						StatusCode:  202,
+						CompletedAt: &completedAt,
+					}, nil),
+				)
+			},
+			wantReplay: true,
+		},
+		{
+			name: "should reject key used for different request",
+			key:  "key",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().CreateIdempotencyKey(gomock.Any()).Return(false, nil),
+					repo.EXPECT().GetIdempotencyKey(1, "key").Return(&model.IdempotencyKey{
+						ID:          GetIntPointer(1),
+						Fingerprint: "other",
+						CompletedAt: &completedAt,
+					}, nil),
+				)
+			},
+			wantErrType: &errors.IdempotencyKeyMismatchError{},
+		},
+		{
+			name: "should reject retry of request in progress",
+			key:  "key",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().CreateIdempotencyKey(gomock.Any()).Return(false, nil),
+					repo.EXPECT().GetIdempotencyKey(1, "key").Return(&model.IdempotencyKey{
+						ID:          GetIntPointer(1),
+						Fingerprint: "fingerprint",
+					}, nil),
+				)
+			},
+			wantErrType: &errors.IdempotencyKeyInProgressError{},
+		},
+		{
+			name:        "should reject too long key",
+			key:         strings.Repeat("k", idempotencyKeyMaxLength+1),
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			wantErrType: &errors.ValidationError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewIdempotencyService(repo, &config.ServerConfig{IdempotencyKeyTTL: 24 * time.Hour, IdempotencyLease: time.Minute})
+			attempt, err := s.Begin(1, tt.key, "fingerprint")
+			if tt.wantErrType != nil {
+				assert.IsType(t, tt.wantErrType, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, attempt.ID)
+			assert.Equal(t, tt.wantReplay, attempt.CompletedAt != nil)
+		})
+	}
+}
+
+func TestIdempotencyService_Complete(t *testing.T) {
+	tests := []struct {
+		name      string
+		completed bool
+	}{
+		{
+			name:      "should store response of attempt holding lease",
+			completed: true,
+		},
+		{
+			name:      "should not fail when lease is taken over",
+			completed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			repo.EXPECT().CompleteIdempotencyKey(gomock.Any()).DoAndReturn(func(key *model.IdempotencyKey) (bool, error) {
+				assert.Equal(t, 1, *key.ID)
+				assert.Equal(t, "lease", key.LeaseToken)
+				assert.Equal(t, 202, key.StatusCode)
+				assert.Equal(t, []byte("accepted"), key.Body)
+				assert.NotNil(t, key.CompletedAt)
+				return tt.completed, nil
+			})
+			s := NewIdempotencyService(repo, &config.ServerConfig{})
+			attempt := &model.IdempotencyKey{ID: GetIntPointer(1), UserID: 1, Key: "key", LeaseToken: "lease"}
+			assert.NoError(t, s.Complete(attempt, 202, "text/plain", []byte("accepted")))
+		})
+	}
+}
+
+func TestIdempotencyService_KeepLease(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	renewed := make(chan struct{})
+	repo.EXPECT().RenewIdempotencyKey(gomock.Any()).DoAndReturn(func(key *model.IdempotencyKey) (bool, error) {
+		assert.Equal(t, 1, *key.ID)
+		assert.Equal(t, "lease", key.LeaseToken)
+		assert.True(t, key.LockedUntil.After(time.Now()))
+		close(renewed)
+		return false, nil
+	})
+	s := NewIdempotencyService(repo, &config.ServerConfig{IdempotencyLease: 30 * time.Millisecond})
+	stop := s.KeepLease(&model.IdempotencyKey{ID: GetIntPointer(1), UserID: 1, Key: "key", LeaseToken: "lease"})
+	defer stop()
+	select {
+	case <-renewed:
+	case <-time.After(time.Second):
+		t.Fatal("lease is not renewed")
+	}
+}
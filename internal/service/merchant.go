@@ -0,0 +1,281 @@
+package service
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/validator"
+	"strings"
+	"time"
+)
+
+const (
+	apiKeyPrefix       = "gmk_"
+	apiKeyPrefixLength = len(apiKeyPrefix) + 8
+	// numberPatternMaxLength is size of merchants.number_pattern column.
+	numberPatternMaxLength = 256
+)
+
+var knownScopes = []string{model.ScopeOrdersWrite}
+
+type Merchant interface {
+	CreateMerchant(actorID int, name string) (*model.Merchant, error)
+	IssueAPIKey(actorID int, merchantID int, scopes []string) (*model.APIKey, error)
+	ListAPIKeys(merchantID int) ([]model.APIKey, error)
+	RevokeAPIKey(actorID int, merchantID int, keyID int) error
+	Authenticate(key string) (*model.APIKey, error)
+	SubmitOrder(merchant model.Merchant, partnerOrder model.PartnerOrder) (*model.Order, error)
+	SetNumberFormat(actorID int, merchantID int, format model.NumberFormat) (*model.Merchant, error)
+	LoadNumberFormats() error
+}
+
+type MerchantService struct {
+	repo         dao.Repository
+	orderService Order
+	validators   *validator.Registry
+}
+
+func NewMerchantService(repo dao.Repository, orderService Order, validators *validator.Registry) Merchant {
+	return MerchantService{
+		repo:         repo,
+		orderService: orderService,
+		validators:   validators,
+	}
+}
+
+func (s MerchantService) CreateMerchant(actorID int, name string) (*model.Merchant, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("name", "must not be empty")
+		return nil, validationErr
+	}
+	merchant := model.Merchant{
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	err := s.repo.SaveMerchant(&merchant)
+	if err != nil {
+		return nil, err
+	}
+	err = audit(s.repo, &actorID, model.AuditActionCreateMerchant, nil, map[string]interface{}{
+		"merchant_id": *merchant.ID,
+		"name":        name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+// IssueAPIKey creates new key for merchant. Plain key is present only in the returned value,
+// only its hash is stored. Without scopes key is allowed to upload orders.
+func (s MerchantService) IssueAPIKey(actorID int, merchantID int, scopes []string) (*model.APIKey, error) {
+	if len(scopes) == 0 {
+		scopes = []string{model.ScopeOrdersWrite}
+	}
+	for _, scope := range scopes {
+		if !isKnownScope(scope) {
+			validationErr := &errors.ValidationError{}
+			validationErr.Add("scopes", fmt.Sprintf("must contain only %s", strings.Join(knownScopes, ", ")))
+			return nil, validationErr
+		}
+	}
+	merchant, err := s.getMerchant(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	plainKey := apiKeyPrefix + secret
+	key := model.APIKey{
+		Merchant:  merchant,
+		Key:       plainKey,
+		Prefix:    plainKey[:apiKeyPrefixLength],
+		KeyHash:   hashToken(plainKey),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	err = s.repo.SaveAPIKey(&key)
+	if err != nil {
+		return nil, err
+	}
+	err = audit(s.repo, &actorID, model.AuditActionIssueAPIKey, nil, map[string]interface{}{
+		"merchant_id": merchantID,
+		"key_id":      *key.ID,
+		"prefix":      key.Prefix,
+		"scopes":      scopes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s MerchantService) ListAPIKeys(merchantID int) ([]model.APIKey, error) {
+	_, err := s.getMerchant(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetAPIKeysByMerchantID(merchantID)
+}
+
+func (s MerchantService) RevokeAPIKey(actorID int, merchantID int, keyID int) error {
+	revoked, err := s.repo.RevokeAPIKey(merchantID, keyID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return &errors.APIKeyNotFoundError{KeyID: keyID}
+	}
+	return audit(s.repo, &actorID, model.AuditActionRevokeAPIKey, nil, map[string]interface{}{
+		"merchant_id": merchantID,
+		"key_id":      keyID,
+	})
+}
+
+func (s MerchantService) Authenticate(key string) (*model.APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, &errors.InvalidAPIKeyError{}
+	}
+	apiKey, err := s.repo.GetAPIKeyByHash(hashToken(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.ID == nil || apiKey.RevokedAt != nil {
+		return nil, &errors.InvalidAPIKeyError{}
+	}
+	if err := s.repo.TouchAPIKey(*apiKey.ID); err != nil {
+		log.Error("cannot update api key usage time: ", err)
+	}
+	return apiKey, nil
+}
+
+// SubmitOrder uploads order on behalf of user, going through the same checks
+// as orders uploaded by users themselves.
+func (s MerchantService) SubmitOrder(merchant model.Merchant, partnerOrder model.PartnerOrder) (*model.Order, error) {
+	validationErr := &errors.ValidationError{}
+	if strings.TrimSpace(partnerOrder.Order) == "" {
+		validationErr.Add("order", "must not be empty")
+	}
+	if (partnerOrder.Login == "") == (partnerOrder.LoyaltyCard == "") {
+		validationErr.Add("login", "exactly one of login and loyalty_card must be set")
+	}
+	if validationErr.HasErrors() {
+		return nil, validationErr
+	}
+
+	var (
+		user *model.User
+		err  error
+	)
+	if partnerOrder.Login != "" {
+		user, err = s.repo.GetUserByLogin(partnerOrder.Login)
+	} else {
+		user, err = s.repo.GetUserByLoyaltyCard(partnerOrder.LoyaltyCard)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == nil {
+		return nil, &errors.UserNotFoundError{User: partnerOrder.Login + partnerOrder.LoyaltyCard}
+	}
+
+	order := model.Order{
+		User:       user,
+		Number:     strings.TrimSpace(partnerOrder.Order),
+		Status:     model.OrderStatusNew,
+		UploadTime: time.Now(),
+		MerchantID: merchant.ID,
+	}
+	err = s.orderService.CreateOrder(&order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// SetNumberFormat stores own order number format of merchant and starts validating its orders
+// with it. Format is registered in this instance right away, other instances load it on start.
+func (s MerchantService) SetNumberFormat(actorID int, merchantID int, format model.NumberFormat) (*model.Merchant, error) {
+	var numberValidator validator.Validator
+	if format.Pattern != "" {
+		validationErr := &errors.ValidationError{}
+		var err error
+		if len(format.Pattern) > numberPatternMaxLength {
+			validationErr.Add("pattern", fmt.Sprintf("must be at most %d characters", numberPatternMaxLength))
+		} else if numberValidator, err = validator.NewPatternValidator(format.Pattern, format.Luhn); err != nil {
+			validationErr.Add("pattern", "must be valid regular expression")
+		}
+		if validationErr.HasErrors() {
+			return nil, validationErr
+		}
+	}
+	merchant, err := s.getMerchant(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	merchant.NumberFormat = nil
+	if numberValidator != nil {
+		merchant.NumberFormat = &format
+	}
+	err = s.repo.SetMerchantNumberFormat(merchantID, merchant.NumberFormat)
+	if err != nil {
+		return nil, err
+	}
+	if numberValidator != nil {
+		s.validators.Register(merchantID, numberValidator)
+	} else {
+		s.validators.Unregister(merchantID)
+	}
+	err = audit(s.repo, &actorID, model.AuditActionSetNumberFormat, nil, map[string]interface{}{
+		"merchant_id": merchantID,
+		"pattern":     format.Pattern,
+		"luhn":        format.Luhn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merchant, nil
+}
+
+// LoadNumberFormats registers stored number formats of merchants, it is called on start.
+func (s MerchantService) LoadNumberFormats() error {
+	merchants, err := s.repo.GetMerchantsWithNumberFormat()
+	if err != nil {
+		return err
+	}
+	for _, merchant := range merchants {
+		numberValidator, err := validator.NewPatternValidator(merchant.NumberFormat.Pattern, merchant.NumberFormat.Luhn)
+		if err != nil {
+			log.Errorf("cannot load number format of merchant %d: %s", *merchant.ID, err)
+			continue
+		}
+		s.validators.Register(*merchant.ID, numberValidator)
+	}
+	return nil
+}
+
+func (s MerchantService) getMerchant(merchantID int) (*model.Merchant, error) {
+	merchant, err := s.repo.GetMerchantByID(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant.ID == nil {
+		return nil, &errors.MerchantNotFoundError{MerchantID: merchantID}
+	}
+	return merchant, nil
+}
+
+func isKnownScope(scope string) bool {
+	for _, known := range knownScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
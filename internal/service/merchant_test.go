@@ -0,0 +1,218 @@
+package service
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/validator"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMerchantService_SubmitOrder(t *testing.T) {
+	merchant := model.Merchant{ID: GetIntPointer(3), Name: "shop"}
+	tests := []struct {
+		name         string
+		partnerOrder model.PartnerOrder
+		prepare      func(repo *mock_dao.MockRepository)
+		wantErrType  error
+	}{
+		{
+			name:         "should create order for user found by login",
+			partnerOrder: model.PartnerOrder{Order: "12345678903", Login: "test"},
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().GetUserByLogin("test").Return(&model.User{ID: GetIntPointer(1)}, nil),
+					repo.EXPECT().GetOrderByNumber("12345678903").Return(&model.Order{}, nil),
+					repo.EXPECT().SaveOrder(gomock.Any()).DoAndReturn(func(order *model.Order) error {
+						assert.Equal(t, 1, *order.User.ID)
+						assert.Equal(t, 3, *order.MerchantID)
+						assert.Equal(t, model.OrderStatusNew, order.Status)
+						return nil
+					}),
+				)
+			},
+			wantErrType: nil,
+		},
+		{
+			name:         "should create order for user found by loyalty card",
+			partnerOrder: model.PartnerOrder{Order: "12345678903", LoyaltyCard: "12345678"},
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().GetUserByLoyaltyCard("12345678").Return(&model.User{ID: GetIntPointer(1)}, nil),
+					repo.EXPECT().GetOrderByNumber("12345678903").Return(&model.Order{}, nil),
+					repo.EXPECT().SaveOrder(gomock.Any()).Return(nil),
+				)
+			},
+			wantErrType: nil,
+		},
+		{
+			name:         "should require exactly one user identifier",
+			partnerOrder: model.PartnerOrder{Order: "12345678903", Login: "test", LoyaltyCard: "12345678"},
+			prepare:      func(repo *mock_dao.MockRepository) {},
+			wantErrType:  &errors.ValidationError{},
+		},
+		{
+			name:         "should return UserNotFoundError for unknown user",
+			partnerOrder: model.PartnerOrder{Order: "12345678903", LoyaltyCard: "12345678"},
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetUserByLoyaltyCard("12345678").Return(&model.User{}, nil)
+			},
+			wantErrType: &errors.UserNotFoundError{},
+		},
+		{
+			name:         "should reuse order checks",
+			partnerOrder: model.PartnerOrder{Order: "12345678903", Login: "test"},
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().GetUserByLogin("test").Return(&model.User{ID: GetIntPointer(1)}, nil),
+					repo.EXPECT().GetOrderByNumber("12345678903").Return(&model.Order{
+						ID:   GetIntPointer(5),
+						User: &model.User{ID: GetIntPointer(2)},
+					}, nil),
+				)
+			},
+			wantErrType: &errors.OrderAlreadyAcceptedDifferentUserError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewMerchantService(repo, NewOrderService(repo, validator.NewRegistry()), validator.NewRegistry())
+			_, err := s.SubmitOrder(merchant, tt.partnerOrder)
+			assert.IsType(t, tt.wantErrType, err)
+		})
+	}
+}
+
+func TestMerchantService_IssueAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	var keyHash string
+	gomock.InOrder(
+		repo.EXPECT().GetMerchantByID(3).Return(&model.Merchant{ID: GetIntPointer(3)}, nil),
+		repo.EXPECT().SaveAPIKey(gomock.Any()).DoAndReturn(func(key *model.APIKey) error {
+			keyHash = key.KeyHash
+			key.ID = GetIntPointer(7)
+			return nil
+		}),
+		repo.EXPECT().SaveAuditEntry(gomock.Any()).DoAndReturn(func(entry *model.AuditEntry) error {
+			assert.Equal(t, model.AuditActionIssueAPIKey, entry.Action)
+			assert.Equal(t, 7, entry.Details["key_id"])
+			return nil
+		}),
+	)
+	s := NewMerchantService(repo, nil, validator.NewRegistry())
+	key, err := s.IssueAPIKey(2, 3, nil)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key.Key, key.Prefix))
+	assert.Equal(t, hashToken(key.Key), keyHash)
+	assert.NotContains(t, keyHash, key.Key)
+	assert.Equal(t, []string{model.ScopeOrdersWrite}, key.Scopes)
+
+	_, err = s.IssueAPIKey(2, 3, []string{"orders:delete"})
+	assert.IsType(t, &errors.ValidationError{}, err)
+}
+
+func TestMerchantService_Authenticate(t *testing.T) {
+	revokedAt := time.Now()
+	tests := []struct {
+		name    string
+		key     string
+		prepare func(repo *mock_dao.MockRepository)
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "should accept active key",
+			key:  "gmk_active",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetAPIKeyByHash(hashToken("gmk_active")).Return(&model.APIKey{
+					ID:       GetIntPointer(7),
+					Merchant: &model.Merchant{ID: GetIntPointer(3)},
+				}, nil)
+				repo.EXPECT().TouchAPIKey(7).Return(nil)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "should reject revoked key",
+			key:  "gmk_revoked",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetAPIKeyByHash(hashToken("gmk_revoked")).Return(&model.APIKey{
+					ID:        GetIntPointer(7),
+					Merchant:  &model.Merchant{ID: GetIntPointer(3)},
+					RevokedAt: &revokedAt,
+				}, nil)
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should reject key without prefix",
+			key:     "",
+			prepare: func(repo *mock_dao.MockRepository) {},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewMerchantService(repo, nil, validator.NewRegistry())
+			_, err := s.Authenticate(tt.key)
+			tt.wantErr(t, err)
+			if err != nil {
+				assert.IsType(t, &errors.InvalidAPIKeyError{}, err)
+			}
+		})
+	}
+}
+
+func TestMerchantService_SetNumberFormat(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	validators := validator.NewRegistry()
+	gomock.InOrder(
+		repo.EXPECT().GetMerchantByID(3).Return(&model.Merchant{ID: GetIntPointer(3)}, nil),
+		repo.EXPECT().SetMerchantNumberFormat(3, &model.NumberFormat{Pattern: "SHOP[0-9]{6}"}).Return(nil),
+		repo.EXPECT().SaveAuditEntry(gomock.Any()).Return(nil),
+		repo.EXPECT().GetMerchantByID(3).Return(&model.Merchant{ID: GetIntPointer(3)}, nil),
+		repo.EXPECT().SetMerchantNumberFormat(3, nil).Return(nil),
+		repo.EXPECT().SaveAuditEntry(gomock.Any()).Return(nil),
+	)
+	s := NewMerchantService(repo, nil, validators)
+
+	_, err := s.SetNumberFormat(1, 3, model.NumberFormat{Pattern: "SHOP[0-9"})
+	assert.IsType(t, &errors.ValidationError{}, err)
+
+	merchant, err := s.SetNumberFormat(1, 3, model.NumberFormat{Pattern: "SHOP[0-9]{6}"})
+	assert.NoError(t, err)
+	assert.Equal(t, "SHOP[0-9]{6}", merchant.NumberFormat.Pattern)
+	assert.True(t, validators.For(GetIntPointer(3)).Valid("SHOP123456"))
+
+	_, err = s.SetNumberFormat(1, 3, model.NumberFormat{})
+	assert.NoError(t, err)
+	assert.False(t, validators.For(GetIntPointer(3)).Valid("SHOP123456"))
+}
+
+func TestMerchantService_LoadNumberFormats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	validators := validator.NewRegistry()
+	repo.EXPECT().GetMerchantsWithNumberFormat().Return([]model.Merchant{
+		{ID: GetIntPointer(3), NumberFormat: &model.NumberFormat{Pattern: "SHOP[0-9]{6}"}},
+	}, nil)
+	s := NewMerchantService(repo, nil, validators)
+	assert.NoError(t, s.LoadNumberFormats())
+	assert.True(t, validators.For(GetIntPointer(3)).Valid("SHOP123456"))
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/clients"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/dto"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"time"
+	"unicode/utf8"
+)
+
+// OIDC signs users in with OpenID Connect provider using authorization code flow with PKCE.
+type OIDC interface {
+	// StartLogin returns provider URL to send user to and binding, which must be kept by
+	// the browser starting the flow. When linkUserID is set, identity is linked to that
+	// user instead of signing in.
+	StartLogin(linkUserID *int) (string, string, error)
+	// CompleteLogin exchanges authorization code returned with state and returns user
+	// linked to the provider subject, creating new user on first sign in. Binding must be
+	// the one returned with state, and link flow must be completed by the same callerID.
+	CompleteLogin(state string, code string, binding string, callerID *int) (*model.User, error)
+}
+
+type OIDCService struct {
+	repo     dao.Repository
+	provider clients.OIDCProvider
+	cfg      *config.ServerConfig
+}
+
+func NewOIDCService(repo dao.Repository, provider clients.OIDCProvider, cfg *config.ServerConfig) OIDC {
+	return OIDCService{
+		repo:     repo,
+		provider: provider,
+		cfg:      cfg,
+	}
+}
+
+func (s OIDCService) StartLogin(linkUserID *int) (string, string, error) {
+	state, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	binding, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	oidcState := model.OIDCState{
+		StateHash:    hashToken(state),
+		BindingHash:  hashToken(binding),
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(s.cfg.OIDCStateTTL),
+	}
+	if linkUserID != nil {
+		oidcState.User = &model.User{ID: linkUserID}
+	}
+	err = s.repo.SaveOIDCState(&oidcState)
+	if err != nil {
+		return "", "", err
+	}
+	authorizationURL, err := s.provider.AuthCodeURL(state, nonce, codeChallenge(codeVerifier))
+	if err != nil {
+		return "", "", err
+	}
+	return authorizationURL, binding, nil
+}
+
+func (s OIDCService) CompleteLogin(state string, code string, binding string, callerID *int) (*model.User, error) {
+	oidcState, err := s.repo.TakeOIDCState(hashToken(state))
+	if err != nil {
+		return nil, err
+	}
+	if oidcState.ID == nil || oidcState.ExpiresAt.Before(time.Now()) {
+		return nil, &errors.InvalidTokenError{}
+	}
+	// state started in another browser is rejected, otherwise victim could be signed in
+	// as attacker or link their identity to attacker account
+	if subtle.ConstantTimeCompare([]byte(hashToken(binding)), []byte(oidcState.BindingHash)) != 1 {
+		log.Warn("openid connect state is completed by another browser")
+		return nil, &errors.InvalidTokenError{}
+	}
+	if oidcState.User != nil && (callerID == nil || *callerID != *oidcState.User.ID) {
+		log.Warnf("openid connect link of user %d is completed by another user", *oidcState.User.ID)
+		return nil, &errors.InvalidTokenError{}
+	}
+	claims, err := s.provider.Exchange(code, oidcState.CodeVerifier, oidcState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *model.User
+	ctx := context.Background()
+	err = s.repo.Atomic(ctx, func(r dao.Repository) error {
+		identity, err := r.GetOIDCIdentity(claims.Issuer, claims.Subject)
+		if err != nil {
+			return err
+		}
+		if identity.ID != nil {
+			if oidcState.User != nil && *oidcState.User.ID != *identity.User.ID {
+				return &errors.OIDCIdentityLinkedError{Subject: claims.Subject}
+			}
+			user, err = r.GetUserByID(*identity.User.ID)
+			if err != nil {
+				return err
+			}
+			if user.ID == nil {
+				return &errors.UserNotFoundError{User: claims.Subject}
+			}
+			return nil
+		}
+
+		if oidcState.User != nil {
+			user, err = r.GetUserByID(*oidcState.User.ID)
+		} else {
+			user, err = createOIDCUser(r, claims)
+		}
+		if err != nil {
+			return err
+		}
+		if user.ID == nil {
+			return &errors.UserNotFoundError{User: claims.Subject}
+		}
+		log.Infof("linking openid connect subject %s to user %d", claims.Subject, *user.ID)
+		return r.SaveOIDCIdentity(&model.OIDCIdentity{
+			User:      user,
+			Issuer:    claims.Issuer,
+			Subject:   claims.Subject,
+			CreatedAt: time.Now(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// createOIDCUser creates user without password, who can sign in only through provider
+// until password is set by reset. Login is taken from provider claims when it is valid
+// and free, otherwise it is derived from subject.
+func createOIDCUser(r dao.Repository, claims *dto.OIDCClaims) (*model.User, error) {
+	candidates := []string{claims.PreferredUsername}
+	if claims.EmailVerified {
+		candidates = append(candidates, claims.Email)
+	}
+	sum := sha256.Sum256([]byte(claims.Issuer + " " + claims.Subject))
+	candidates = append(candidates, "oidc-"+hex.EncodeToString(sum[:6]))
+
+	for _, login := range candidates {
+		loginLength := utf8.RuneCountInString(login)
+		if loginLength < loginMinLength || loginLength > loginMaxLength || !loginPattern.MatchString(login) {
+			continue
+		}
+		existing, err := r.GetUserByLogin(login)
+		if err != nil {
+			return nil, err
+		}
+		if existing.ID != nil {
+			continue
+		}
+		err = r.SaveUser(&model.User{Login: login})
+		if err != nil {
+			return nil, err
+		}
+		return r.GetUserByLogin(login)
+	}
+	return nil, &errors.UserAlreadyExistsError{User: candidates[len(candidates)-1]}
+}
+
+// codeChallenge is PKCE S256 challenge of verifier.
+func codeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
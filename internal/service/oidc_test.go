@@ -0,0 +1,216 @@
+package service
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dto"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var oidcConfig = &config.ServerConfig{
+	OIDCStateTTL: 10 * time.Minute,
+}
+
+// fakeOIDCProvider authorizes any code, returning claims for expected verifier only.
+type fakeOIDCProvider struct {
+	claims        dto.OIDCClaims
+	codeChallenge string
+	nonce         string
+}
+
+func (p *fakeOIDCProvider) AuthCodeURL(state string, nonce string, codeChallenge string) (string, error) {
+	p.codeChallenge = codeChallenge
+	p.nonce = nonce
+	return "https://idp.example.com/authorize?state=" + url.QueryEscape(state), nil
+}
+
+func (p *fakeOIDCProvider) Exchange(code string, codeVerifier string, nonce string) (*dto.OIDCClaims, error) {
+	if codeChallenge(codeVerifier) != p.codeChallenge || nonce != p.nonce {
+		return nil, &errors.OIDCAuthenticationError{Reason: "invalid_grant"}
+	}
+	return &p.claims, nil
+}
+
+func TestOIDCService_StartLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	provider := &fakeOIDCProvider{}
+	var saved *model.OIDCState
+	repo.EXPECT().SaveOIDCState(gomock.Any()).DoAndReturn(func(state *model.OIDCState) error {
+		saved = state
+		return nil
+	})
+
+	s := NewOIDCService(repo, provider, oidcConfig)
+	authorizationURL, binding, err := s.StartLogin(GetIntPointer(1))
+	assert.NoError(t, err)
+	assert.Equal(t, hashToken(binding), saved.BindingHash)
+
+	parsed, _ := url.Parse(authorizationURL)
+	state := parsed.Query().Get("state")
+	assert.Equal(t, hashToken(state), saved.StateHash)
+	assert.Equal(t, codeChallenge(saved.CodeVerifier), provider.codeChallenge)
+	assert.Equal(t, saved.Nonce, provider.nonce)
+	assert.Equal(t, 1, *saved.User.ID)
+}
+
+func TestOIDCService_CompleteLogin(t *testing.T) {
+	claims := dto.OIDCClaims{
+		Issuer:            "https://idp.example.com",
+		Subject:           "subject-1",
+		PreferredUsername: "jdoe",
+	}
+	oidcState := func(userID *int) *model.OIDCState {
+		state := &model.OIDCState{
+			ID:           GetIntPointer(1),
+			BindingHash:  hashToken("binding"),
+			CodeVerifier: "verifier",
+			Nonce:        "nonce",
+			ExpiresAt:    time.Now().Add(time.Minute),
+		}
+		if userID != nil {
+			state.User = &model.User{ID: userID}
+		}
+		return state
+	}
+	tests := []struct {
+		name        string
+		binding     string
+		callerID    *int
+		prepare     func(repo *mock_dao.MockRepository)
+		wantUserID  int
+		wantErrType error
+	}{
+		{
+			name: "should sign in user linked to subject",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().TakeOIDCState(hashToken("state")).Return(oidcState(nil), nil),
+					expectAtomic(repo),
+					repo.EXPECT().GetOIDCIdentity(claims.Issuer, claims.Subject).Return(&model.OIDCIdentity{
+						ID:   GetIntPointer(3),
+						User: &model.User{ID: GetIntPointer(5)},
+					}, nil),
+					repo.EXPECT().GetUserByID(5).Return(&model.User{ID: GetIntPointer(5)}, nil),
+				)
+			},
+			wantUserID:  5,
+			wantErrType: nil,
+		},
+		{
+			name: "should create user on first sign in",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().TakeOIDCState(hashToken("state")).Return(oidcState(nil), nil),
+					expectAtomic(repo),
+					repo.EXPECT().GetOIDCIdentity(claims.Issuer, claims.Subject).Return(&model.OIDCIdentity{}, nil),
+					repo.EXPECT().GetUserByLogin("jdoe").Return(&model.User{ID: GetIntPointer(2)}, nil),
+					repo.EXPECT().GetUserByLogin(gomock.Any()).Return(&model.User{}, nil),
+					repo.EXPECT().SaveUser(gomock.Any()).DoAndReturn(func(user *model.User) error {
+						assert.Regexp(t, "^oidc-[0-9a-f]{12}$", user.Login)
+						assert.Empty(t, user.Password)
+						return nil
+					}),
+					repo.EXPECT().GetUserByLogin(gomock.Any()).Return(&model.User{ID: GetIntPointer(6)}, nil),
+					repo.EXPECT().SaveOIDCIdentity(gomock.Any()).DoAndReturn(func(identity *model.OIDCIdentity) error {
+						assert.Equal(t, 6, *identity.User.ID)
+						assert.Equal(t, claims.Subject, identity.Subject)
+						return nil
+					}),
+				)
+			},
+			wantUserID:  6,
+			wantErrType: nil,
+		},
+		{
+			name:     "should link subject to signed in user",
+			callerID: GetIntPointer(1),
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().TakeOIDCState(hashToken("state")).Return(oidcState(GetIntPointer(1)), nil),
+					expectAtomic(repo),
+					repo.EXPECT().GetOIDCIdentity(claims.Issuer, claims.Subject).Return(&model.OIDCIdentity{}, nil),
+					repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1)}, nil),
+					repo.EXPECT().SaveOIDCIdentity(gomock.Any()).Return(nil),
+				)
+			},
+			wantUserID:  1,
+			wantErrType: nil,
+		},
+		{
+			name:     "should not link subject of another user",
+			callerID: GetIntPointer(1),
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().TakeOIDCState(hashToken("state")).Return(oidcState(GetIntPointer(1)), nil),
+					expectAtomic(repo),
+					repo.EXPECT().GetOIDCIdentity(claims.Issuer, claims.Subject).Return(&model.OIDCIdentity{
+						ID:   GetIntPointer(3),
+						User: &model.User{ID: GetIntPointer(5)},
+					}, nil),
+				)
+			},
+			wantErrType: &errors.OIDCIdentityLinkedError{},
+		},
+		{
+			name: "should reject unknown state",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().TakeOIDCState(hashToken("state")).Return(&model.OIDCState{}, nil)
+			},
+			wantErrType: &errors.InvalidTokenError{},
+		},
+		{
+			name:    "should reject state started in another browser",
+			binding: "other",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().TakeOIDCState(hashToken("state")).Return(oidcState(nil), nil)
+			},
+			wantErrType: &errors.InvalidTokenError{},
+		},
+		{
+			name:     "should reject link completed by another user",
+			callerID: GetIntPointer(2),
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().TakeOIDCState(hashToken("state")).Return(oidcState(GetIntPointer(1)), nil)
+			},
+			wantErrType: &errors.InvalidTokenError{},
+		},
+		{
+			name: "should reject link completed without signed in user",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().TakeOIDCState(hashToken("state")).Return(oidcState(GetIntPointer(1)), nil)
+			},
+			wantErrType: &errors.InvalidTokenError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			provider := &fakeOIDCProvider{
+				claims:        claims,
+				codeChallenge: codeChallenge("verifier"),
+				nonce:         "nonce",
+			}
+			s := NewOIDCService(repo, provider, oidcConfig)
+			binding := tt.binding
+			if binding == "" {
+				binding = "binding"
+			}
+			user, err := s.CompleteLogin("state", "code", binding, tt.callerID)
+			assert.IsType(t, tt.wantErrType, err)
+			if err == nil {
+				assert.Equal(t, tt.wantUserID, *user.ID)
+			}
+		})
+	}
+}
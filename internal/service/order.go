@@ -2,67 +2,92 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/yurchenkosv/gofermart/internal/dao"
 	"github.com/yurchenkosv/gofermart/internal/errors"
 	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/validator"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 var (
 	mux sync.Mutex
 )
 
+const (
+	orderBatchMaxSize   = 1000
+	orderBatchChunkSize = 100
+	orderPageSize       = 50
+	orderPageMaxSize    = 200
+	orderEventsLimit    = 100
+)
+
 type Order interface {
 	CreateOrder(order *model.Order) error
+	CreateOrders(userID int, numbers []string) ([]model.OrderBatchResult, error)
 	GetUploadedOrdersForUser(UserID int) ([]model.Order, error)
+	GetOrdersPage(userID int, filter model.OrderFilter, cursor string) (*model.OrderPage, error)
+	GetOrder(userID int, number string) (*model.OrderDetails, error)
+	DeleteOrder(userID int, number string) error
+	GetOrderEvents(userID int, afterID int) ([]model.OrderEvent, error)
+	GetLastOrderEventID(userID int) (int, error)
 	UpdateOrderStatus(order model.Order) error
 }
 
 type OrderService struct {
-	repo dao.Repository
+	repo       dao.Repository
+	validators *validator.Registry
 }
 
-func NewOrderService(repo dao.Repository) Order {
-	return OrderService{repo: repo}
+func NewOrderService(repo dao.Repository, validators *validator.Registry) Order {
+	return OrderService{
+		repo:       repo,
+		validators: validators,
+	}
 }
 
 func (s OrderService) CreateOrder(order *model.Order) error {
-	checkOrder, err := s.repo.GetOrderByNumber(order.Number)
-	if err != nil {
-		return err
-	}
-	if checkOrder.ID != nil {
-		checkUserID := *checkOrder.User.ID
-		orderUserID := *order.User.ID
-		if checkUserID == orderUserID {
-			return &errors.OrderAlreadyAcceptedCurrentUserError{
-				UserID:      checkUserID,
-				OrderNumber: order.Number,
-			}
-		} else {
-			return &errors.OrderAlreadyAcceptedDifferentUserError{
-				OrderNumber: order.Number,
-				UserID:      checkUserID,
-			}
-		}
+	return createOrder(s.repo, s.validators.For(order.MerchantID), order)
+}
 
+// CreateOrders uploads batch of orders of user. Result is reported for every number,
+// orders are saved in transactions of orderBatchChunkSize numbers.
+func (s OrderService) CreateOrders(userID int, numbers []string) ([]model.OrderBatchResult, error) {
+	if len(numbers) == 0 || len(numbers) > orderBatchMaxSize {
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("orders", fmt.Sprintf("must contain from 1 to %d numbers", orderBatchMaxSize))
+		return nil, validationErr
 	}
-	orderNum, err := strconv.Atoi(order.Number)
-	if err != nil {
-		return err
-	}
-	if !checkOrderFormat(orderNum) {
-		return &errors.OrderFormatError{
-			OrderNumber: order.Number,
+	results := make([]model.OrderBatchResult, 0, len(numbers))
+	ctx := context.Background()
+	for start := 0; start < len(numbers); start += orderBatchChunkSize {
+		end := start + orderBatchChunkSize
+		if end > len(numbers) {
+			end = len(numbers)
 		}
+		var chunkResults []model.OrderBatchResult
+		err := s.repo.Atomic(ctx, func(r dao.Repository) error {
+			chunkResults = make([]model.OrderBatchResult, 0, end-start)
+			for _, number := range numbers[start:end] {
+				result, err := createOrderInBatch(r, s.validators.For(nil), userID, number)
+				if err != nil {
+					return err
+				}
+				chunkResults = append(chunkResults, model.OrderBatchResult{Number: number, Result: result})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, chunkResults...)
 	}
-	err = s.repo.SaveOrder(order)
-	if err != nil {
-		return err
-	}
-	return nil
+	return results, nil
 }
 
 func (s OrderService) GetUploadedOrdersForUser(UserID int) ([]model.Order, error) {
@@ -77,10 +102,63 @@ func (s OrderService) GetUploadedOrdersForUser(UserID int) ([]model.Order, error
 	return orders, nil
 }
 
+// GetOrdersPage returns orders matching filter, starting after position encoded in cursor.
+// Cursor of the next page is set only when there are more orders.
+func (s OrderService) GetOrdersPage(userID int, filter model.OrderFilter, cursor string) (*model.OrderPage, error) {
+	validationErr := &errors.ValidationError{}
+	for _, status := range filter.Statuses {
+		if !model.ValidOrderStatus(status) {
+			validationErr.Add("status", fmt.Sprintf("unknown status %s", status))
+		}
+	}
+	if filter.UploadedFrom != nil && filter.UploadedTo != nil && !filter.UploadedFrom.Before(*filter.UploadedTo) {
+		validationErr.Add("uploaded_from", "must be before uploaded_to")
+	}
+	if filter.Limit == 0 {
+		filter.Limit = orderPageSize
+	}
+	if filter.Limit < 0 || filter.Limit > orderPageMaxSize {
+		validationErr.Add("limit", fmt.Sprintf("must be from 1 to %d", orderPageMaxSize))
+	}
+	if cursor != "" {
+		after, err := decodeOrderCursor(cursor)
+		if err != nil {
+			validationErr.Add("cursor", "is malformed")
+		}
+		filter.After = after
+	}
+	if validationErr.HasErrors() {
+		return nil, validationErr
+	}
+
+	limit := filter.Limit
+	// one more order tells whether there is next page
+	filter.Limit++
+	orders, err := s.repo.GetOrdersPage(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, &errors.NoOrdersError{}
+	}
+	page := model.OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		last := page.Orders[limit-1]
+		page.NextCursor = encodeOrderCursor(model.OrderCursor{UploadTime: last.UploadTime, ID: *last.ID})
+	}
+	return &page, nil
+}
+
+// UpdateOrderStatus saves status and accrual received from accrual system, records transition
+// in order history and credits accrual to balance of the order owner. Illegal transitions and
+// unknown statuses are rejected with OrderStatusTransitionError.
 func (s OrderService) UpdateOrderStatus(order model.Order) error {
 	ctx := context.Background()
 	err := s.repo.Atomic(ctx, func(r dao.Repository) error {
-		orderInDB, err := s.repo.GetOrderByNumber(order.Number)
+		// order is locked, so concurrent update sees status set by this one and accrual
+		// can't be credited twice
+		orderInDB, err := r.LockOrderByNumber(order.Number)
 		if err != nil {
 			return err
 		}
@@ -90,51 +168,209 @@ func (s OrderService) UpdateOrderStatus(order model.Order) error {
 		if orderInDB.Status == order.Status {
 			return &errors.OrderNoChangeError{}
 		}
+		if !model.CanTransitionOrderStatus(orderInDB.Status, order.Status) {
+			log.Warnf("rejected status update of order %s from %s to %s",
+				orderInDB.Number, orderInDB.Status, order.Status)
+			return &errors.OrderStatusTransitionError{
+				OrderNumber: orderInDB.Number,
+				From:        orderInDB.Status,
+				To:          order.Status,
+			}
+		}
+		previous := orderInDB.Status
 		orderInDB.Accrual = order.Accrual
 		orderInDB.Status = order.Status
 
-		err = s.repo.SaveOrder(orderInDB)
+		err = r.SaveOrder(orderInDB)
+		if err != nil {
+			return err
+		}
+		err = r.SaveOrderStatusTransition(orderInDB, &model.OrderStatusTransition{
+			FromStatus:      previous,
+			ToStatus:        orderInDB.Status,
+			Accrual:         orderInDB.Accrual,
+			BalanceCredited: orderInDB.Accrual != nil,
+			ChangedAt:       time.Now(),
+		})
 		if err != nil {
 			return err
 		}
 
 		if order.Accrual != nil {
-			balance, err := s.repo.GetBalanceByUserID(*orderInDB.User.ID)
+			balance, err := r.LockBalanceByUserID(*orderInDB.User.ID)
 			if err != nil {
 				log.Error(err)
 				return err
 			}
 			balance.Balance += *orderInDB.Accrual
-			err = s.repo.SaveBalance(balance)
+			err = r.SaveBalance(balance)
 			if err != nil {
 				return err
 			}
 		}
+		if orderInDB.Status == model.OrderStatusProcessed {
+			return enqueueWebhookEvent(r, *orderInDB.User.ID, model.WebhookEventOrderProcessed, orderInDB)
+		}
 		return nil
 	})
 
+	if e, ok := err.(*errors.OrderStatusTransitionError); ok {
+		// rejected update is counted outside of the transaction, which is rolled back
+		saveErr := s.repo.SaveRejectedStatusUpdate(&model.RejectedStatusUpdate{
+			OrderNumber: e.OrderNumber,
+			FromStatus:  e.From,
+			ToStatus:    e.To,
+			RejectedAt:  time.Now(),
+		})
+		if saveErr != nil {
+			log.Error("cannot count rejected status update: ", saveErr)
+		}
+	}
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func checkOrderFormat(number int) bool {
-	return (number%10+luhnChecksum(number/10))%10 == 0
+// GetOrder returns order of user with its status history. Upload of order starts the history.
+func (s OrderService) GetOrder(userID int, number string) (*model.OrderDetails, error) {
+	order, err := s.repo.GetOrderByNumber(s.validators.For(nil).Normalize(number))
+	if err != nil {
+		return nil, err
+	}
+	if order.ID == nil || order.User == nil || order.User.ID == nil || *order.User.ID != userID {
+		return nil, &errors.NoOrdersError{}
+	}
+	history, err := s.repo.GetOrderStatusHistory(*order.ID)
+	if err != nil {
+		return nil, err
+	}
+	details := model.OrderDetails{
+		Order: order,
+		History: []model.OrderStatusTransition{{
+			ToStatus:  model.OrderStatusNew,
+			ChangedAt: order.UploadTime,
+		}},
+	}
+	details.History = append(details.History, history...)
+	return &details, nil
 }
 
-func luhnChecksum(number int) int {
-	var luhn int
-	for i := 0; number > 0; i++ {
-		cur := number % 10
-		if i%2 == 0 { // even
-			cur = cur * 2
-			if cur > 9 {
-				cur = cur%10 + cur/10
+// DeleteOrder releases number of order uploaded by mistake, so the real owner can upload it.
+// Only NEW orders, which accrual system has not reported yet, can be deleted. Order is gone
+// with its history, so deletion is recorded in the audit trail for support.
+func (s OrderService) DeleteOrder(userID int, number string) error {
+	number = s.validators.For(nil).Normalize(number)
+	ctx := context.Background()
+	return s.repo.Atomic(ctx, func(r dao.Repository) error {
+		order, err := r.GetOrderByNumber(number)
+		if err != nil {
+			return err
+		}
+		if order.ID == nil || order.User == nil || order.User.ID == nil || *order.User.ID != userID {
+			return &errors.NoOrdersError{}
+		}
+		deleted, err := r.DeleteUnpolledOrder(*order.ID, userID)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return &errors.OrderNotDeletableError{OrderNumber: number}
+		}
+		return audit(r, &userID, model.AuditActionDeleteOrder, &userID, map[string]interface{}{
+			"order":  number,
+			"status": order.Status,
+		})
+	})
+}
+
+// GetOrderEvents returns next events of user orders after event afterID, at most orderEventsLimit.
+func (s OrderService) GetOrderEvents(userID int, afterID int) ([]model.OrderEvent, error) {
+	return s.repo.GetOrderEvents(userID, afterID, orderEventsLimit)
+}
+
+// GetLastOrderEventID returns id of the latest event of user orders, zero if there are none.
+func (s OrderService) GetLastOrderEventID(userID int) (int, error) {
+	return s.repo.GetLastOrderEventID(userID)
+}
+
+// createOrder normalizes order number and saves order after checking it was not uploaded yet
+// and its number has valid format.
+func createOrder(r dao.Repository, numberValidator validator.Validator, order *model.Order) error {
+	order.Number = numberValidator.Normalize(order.Number)
+	checkOrder, err := r.GetOrderByNumber(order.Number)
+	if err != nil {
+		return err
+	}
+	if checkOrder.ID != nil {
+		checkUserID := *checkOrder.User.ID
+		orderUserID := *order.User.ID
+		if checkUserID == orderUserID {
+			return &errors.OrderAlreadyAcceptedCurrentUserError{
+				UserID:      checkUserID,
+				OrderNumber: order.Number,
+			}
+		} else {
+			return &errors.OrderAlreadyAcceptedDifferentUserError{
+				OrderNumber: order.Number,
+				UserID:      checkUserID,
 			}
 		}
-		luhn += cur
-		number = number / 10
+
+	}
+	if !numberValidator.Valid(order.Number) {
+		return &errors.OrderFormatError{
+			OrderNumber: order.Number,
+		}
+	}
+	return r.SaveOrder(order)
+}
+
+// createOrderInBatch maps result of createOrder to batch result, errors not related
+// to the order itself abort the batch.
+func createOrderInBatch(r dao.Repository, numberValidator validator.Validator, userID int, number string) (string, error) {
+	err := createOrder(r, numberValidator, &model.Order{
+		User:       &model.User{ID: &userID},
+		Number:     number,
+		Status:     model.OrderStatusNew,
+		UploadTime: time.Now(),
+	})
+	switch err.(type) {
+	case nil:
+		return model.OrderResultAccepted, nil
+	case *errors.OrderAlreadyAcceptedCurrentUserError:
+		return model.OrderResultAlreadyYours, nil
+	case *errors.OrderAlreadyAcceptedDifferentUserError:
+		return model.OrderResultOwnedByAnotherUser, nil
+	case *errors.OrderFormatError:
+		return model.OrderResultInvalidNumber, nil
+	default:
+		return "", err
+	}
+}
+
+// encodeOrderCursor makes opaque cursor of order position.
+func encodeOrderCursor(cursor model.OrderCursor) string {
+	raw := fmt.Sprintf("%d:%d", cursor.UploadTime.UnixNano(), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeOrderCursor(cursor string) (*model.OrderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed cursor %s", cursor)
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, err
 	}
-	return luhn % 10
+	return &model.OrderCursor{UploadTime: time.Unix(0, nanos), ID: id}, nil
 }
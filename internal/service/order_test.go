@@ -7,6 +7,7 @@ import (
 	"github.com/yurchenkosv/gofermart/internal/errors"
 	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
 	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/validator"
 	"testing"
 	"time"
 )
@@ -88,7 +89,8 @@ func TestOrderService_CreateOrder(t *testing.T) {
 			f := fields{repo: mock_dao.NewMockRepository(ctrl)}
 			tt.prepare(&f, tt.args.order)
 			s := OrderService{
-				repo: f.repo,
+				repo:       f.repo,
+				validators: validator.NewRegistry(),
 			}
 			orderService := s.CreateOrder(tt.args.order)
 			tt.wantErr(t, orderService, fmt.Sprintf("CreateOrder(%v)", tt.args.order))
@@ -96,6 +98,41 @@ func TestOrderService_CreateOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestOrderService_CreateOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	gomock.InOrder(
+		expectAtomic(repo),
+		repo.EXPECT().GetOrderByNumber("2377225624").Return(&model.Order{}, nil),
+		repo.EXPECT().SaveOrder(gomock.Any()).Return(nil),
+		repo.EXPECT().GetOrderByNumber("12345678903").Return(&model.Order{
+			ID:   GetIntPointer(2),
+			User: &model.User{ID: GetIntPointer(1)},
+		}, nil),
+		repo.EXPECT().GetOrderByNumber("79927398713").Return(&model.Order{
+			ID:   GetIntPointer(3),
+			User: &model.User{ID: GetIntPointer(2)},
+		}, nil),
+		repo.EXPECT().GetOrderByNumber("12345").Return(&model.Order{}, nil),
+		repo.EXPECT().GetOrderByNumber("abc").Return(&model.Order{}, nil),
+	)
+	s := NewOrderService(repo, validator.NewRegistry())
+	results, err := s.CreateOrders(1, []string{"2377225624", "12345678903", "79927398713", "12345", "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.OrderBatchResult{
+		{Number: "2377225624", Result: model.OrderResultAccepted},
+		{Number: "12345678903", Result: model.OrderResultAlreadyYours},
+		{Number: "79927398713", Result: model.OrderResultOwnedByAnotherUser},
+		{Number: "12345", Result: model.OrderResultInvalidNumber},
+		{Number: "abc", Result: model.OrderResultInvalidNumber},
+	}, results)
+
+	_, err = s.CreateOrders(1, nil)
+	assert.IsType(t, &errors.ValidationError{}, err)
+}
+
 func GetIntPointer(value int) *int {
 	return &value
 }
@@ -175,6 +212,253 @@ func TestOrderService_GetUploadedOrdersForUser(t *testing.T) {
 	}
 }
 
+func TestOrderService_GetOrdersPage(t *testing.T) {
+	uploadTime := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	orders := []model.Order{
+		{ID: GetIntPointer(1), Number: "2377225624", UploadTime: uploadTime},
+		{ID: GetIntPointer(2), Number: "12345678903", UploadTime: uploadTime.Add(time.Minute)},
+		{ID: GetIntPointer(3), Number: "4561261212345467", UploadTime: uploadTime.Add(2 * time.Minute)},
+	}
+	tests := []struct {
+		name           string
+		prepare        func(repo *mock_dao.MockRepository)
+		filter         model.OrderFilter
+		cursor         string
+		wantOrders     int
+		wantNextCursor bool
+		wantErrType    error
+	}{
+		{
+			name: "should return first page with next cursor",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetOrdersPage(1, model.OrderFilter{Limit: 3}).Return(orders, nil)
+			},
+			filter:         model.OrderFilter{Limit: 2},
+			wantOrders:     2,
+			wantNextCursor: true,
+		},
+		{
+			name: "should return last page without next cursor",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetOrdersPage(1, gomock.Any()).
+					DoAndReturn(func(userID int, filter model.OrderFilter) ([]model.Order, error) {
+						assert.Equal(t, 3, filter.Limit)
+						assert.True(t, uploadTime.Equal(filter.After.UploadTime))
+						assert.Equal(t, 1, filter.After.ID)
+						return orders[1:], nil
+					})
+			},
+			filter:     model.OrderFilter{Limit: 2},
+			cursor:     encodeOrderCursor(model.OrderCursor{UploadTime: uploadTime, ID: 1}),
+			wantOrders: 2,
+		},
+		{
+			name: "should use default page size",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetOrdersPage(1, model.OrderFilter{Limit: orderPageSize + 1}).Return(orders, nil)
+			},
+			wantOrders: 3,
+		},
+		{
+			name: "should return NoOrdersError on empty page",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetOrdersPage(1, gomock.Any()).Return(nil, nil)
+			},
+			wantErrType: &errors.NoOrdersError{},
+		},
+		{
+			name:        "should reject unknown status",
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			filter:      model.OrderFilter{Statuses: []string{"DONE"}},
+			wantErrType: &errors.ValidationError{},
+		},
+		{
+			name:        "should reject too large limit",
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			filter:      model.OrderFilter{Limit: orderPageMaxSize + 1},
+			wantErrType: &errors.ValidationError{},
+		},
+		{
+			name:    "should reject empty date range",
+			prepare: func(repo *mock_dao.MockRepository) {},
+			filter: model.OrderFilter{
+				UploadedFrom: &uploadTime,
+				UploadedTo:   &uploadTime,
+			},
+			wantErrType: &errors.ValidationError{},
+		},
+		{
+			name:        "should reject malformed cursor",
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			cursor:      "not a cursor",
+			wantErrType: &errors.ValidationError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewOrderService(repo, validator.NewRegistry())
+			page, err := s.GetOrdersPage(1, tt.filter, tt.cursor)
+			if tt.wantErrType != nil {
+				assert.IsType(t, tt.wantErrType, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, page.Orders, tt.wantOrders)
+			assert.Equal(t, tt.wantNextCursor, page.NextCursor != "")
+		})
+	}
+}
+
+func Test_decodeOrderCursor(t *testing.T) {
+	cursor := model.OrderCursor{UploadTime: time.Date(2022, 5, 1, 10, 0, 0, 123456000, time.UTC), ID: 42}
+	decoded, err := decodeOrderCursor(encodeOrderCursor(cursor))
+	assert.NoError(t, err)
+	assert.True(t, cursor.UploadTime.Equal(decoded.UploadTime))
+	assert.Equal(t, cursor.ID, decoded.ID)
+
+	_, err = decodeOrderCursor(encodeOrderCursor(cursor)[:4])
+	assert.Error(t, err)
+}
+
+func TestOrderService_GetOrder(t *testing.T) {
+	uploadTime := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	accrual := float32(500)
+	tests := []struct {
+		name        string
+		prepare     func(repo *mock_dao.MockRepository)
+		wantHistory []string
+		wantErrType error
+	}{
+		{
+			name: "should return order with history",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetOrderByNumber("2377225624").Return(&model.Order{
+					ID:         GetIntPointer(3),
+					User:       &model.User{ID: GetIntPointer(1)},
+					Number:     "2377225624",
+					Status:     model.OrderStatusProcessed,
+					Accrual:    &accrual,
+					UploadTime: uploadTime,
+				}, nil)
+				repo.EXPECT().GetOrderStatusHistory(3).Return([]model.OrderStatusTransition{
+					{FromStatus: model.OrderStatusNew, ToStatus: model.OrderStatusProcessing},
+					{FromStatus: model.OrderStatusProcessing, ToStatus: model.OrderStatusProcessed, Accrual: &accrual},
+				}, nil)
+			},
+			wantHistory: []string{model.OrderStatusNew, model.OrderStatusProcessing, model.OrderStatusProcessed},
+		},
+		{
+			name: "should return NoOrdersError for order of another user",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetOrderByNumber("2377225624").Return(&model.Order{
+					ID:     GetIntPointer(3),
+					User:   &model.User{ID: GetIntPointer(2)},
+					Number: "2377225624",
+				}, nil)
+			},
+			wantErrType: &errors.NoOrdersError{},
+		},
+		{
+			name: "should return NoOrdersError for unknown order",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetOrderByNumber("2377225624").Return(&model.Order{Number: "2377225624"}, nil)
+			},
+			wantErrType: &errors.NoOrdersError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewOrderService(repo, validator.NewRegistry())
+			details, err := s.GetOrder(1, "2377 2256 24")
+			if tt.wantErrType != nil {
+				assert.IsType(t, tt.wantErrType, err)
+				return
+			}
+			assert.NoError(t, err)
+			var statuses []string
+			for _, transition := range details.History {
+				statuses = append(statuses, transition.ToStatus)
+			}
+			assert.Equal(t, tt.wantHistory, statuses)
+			assert.Equal(t, uploadTime, details.History[0].ChangedAt)
+		})
+	}
+}
+
+func TestOrderService_DeleteOrder(t *testing.T) {
+	newOrder := func(userID int) *model.Order {
+		return &model.Order{
+			ID:     GetIntPointer(3),
+			User:   &model.User{ID: GetIntPointer(userID)},
+			Number: "2377225624",
+			Status: model.OrderStatusNew,
+		}
+	}
+	tests := []struct {
+		name        string
+		prepare     func(repo *mock_dao.MockRepository)
+		wantErrType error
+	}{
+		{
+			name: "should delete unpolled order and record it in audit trail",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().GetOrderByNumber("2377225624").Return(newOrder(1), nil),
+					repo.EXPECT().DeleteUnpolledOrder(3, 1).Return(true, nil),
+					repo.EXPECT().SaveAuditEntry(gomock.Any()).DoAndReturn(func(entry *model.AuditEntry) error {
+						assert.Equal(t, model.AuditActionDeleteOrder, entry.Action)
+						assert.Equal(t, 1, *entry.ActorID)
+						assert.Equal(t, "2377225624", entry.Details["order"])
+						return nil
+					}),
+				)
+			},
+			wantErrType: nil,
+		},
+		{
+			name: "should return OrderNotDeletableError for polled order",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().GetOrderByNumber("2377225624").Return(newOrder(1), nil),
+					repo.EXPECT().DeleteUnpolledOrder(3, 1).Return(false, nil),
+				)
+			},
+			wantErrType: &errors.OrderNotDeletableError{},
+		},
+		{
+			name: "should return NoOrdersError for order of another user",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().GetOrderByNumber("2377225624").Return(newOrder(2), nil),
+				)
+			},
+			wantErrType: &errors.NoOrdersError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewOrderService(repo, validator.NewRegistry())
+			err := s.DeleteOrder(1, "2377225624")
+			assert.IsType(t, tt.wantErrType, err)
+		})
+	}
+}
+
 func TestOrderService_UpdateOrderStatus(t *testing.T) {
 	type fields struct {
 		repo *mock_dao.MockRepository
@@ -182,6 +466,7 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 	type args struct {
 		order model.Order
 	}
+	accrual := float32(500)
 	tests := []struct {
 		name          string
 		args          args
@@ -202,8 +487,15 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 					User:       &model.User{ID: &id},
 				}
 				gomock.InOrder(
-					f.repo.EXPECT().GetOrderByNumber("2377225624").Return(orderInDB, nil),
+					expectAtomic(f.repo),
+					f.repo.EXPECT().LockOrderByNumber("2377225624").Return(orderInDB, nil),
 					f.repo.EXPECT().SaveOrder(orderInDB).Return(nil),
+					f.repo.EXPECT().SaveOrderStatusTransition(gomock.Any(), gomock.Any()).
+						DoAndReturn(func(order *model.Order, transition *model.OrderStatusTransition) error {
+							assert.Equal(t, model.OrderStatusNew, transition.FromStatus)
+							assert.Equal(t, model.OrderStatusInvalid, transition.ToStatus)
+							return nil
+						}),
 				)
 			},
 			args: args{order: model.Order{
@@ -215,6 +507,88 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 			wantErr:       assert.NoError,
 			wantErrorType: nil,
 		},
+		{
+			name: "should credit accrual to balance",
+			prepare: func(f *fields) {
+				orderInDB := &model.Order{
+					ID:     GetIntPointer(1),
+					Number: "2377225624",
+					Status: model.OrderStatusProcessing,
+					User:   &model.User{ID: GetIntPointer(2)},
+				}
+				balance := &model.Balance{Balance: 100}
+				gomock.InOrder(
+					expectAtomic(f.repo),
+					f.repo.EXPECT().LockOrderByNumber("2377225624").Return(orderInDB, nil),
+					f.repo.EXPECT().SaveOrder(orderInDB).Return(nil),
+					f.repo.EXPECT().SaveOrderStatusTransition(gomock.Any(), gomock.Any()).
+						DoAndReturn(func(order *model.Order, transition *model.OrderStatusTransition) error {
+							assert.Equal(t, float32(500), *transition.Accrual)
+							return nil
+						}),
+					f.repo.EXPECT().LockBalanceByUserID(2).Return(balance, nil),
+					f.repo.EXPECT().SaveBalance(balance).DoAndReturn(func(balance *model.Balance) error {
+						assert.Equal(t, float32(600), balance.Balance)
+						return nil
+					}),
+					f.repo.EXPECT().GetWebhooksByEvent(2, model.WebhookEventOrderProcessed).Return(nil, nil),
+				)
+			},
+			args: args{order: model.Order{
+				Number:  "2377225624",
+				Accrual: &accrual,
+				Status:  model.OrderStatusProcessed,
+			}},
+			wantErr:       assert.NoError,
+			wantErrorType: nil,
+		},
+		{
+			name: "should reject leaving terminal status",
+			prepare: func(f *fields) {
+				gomock.InOrder(
+					expectAtomic(f.repo),
+					f.repo.EXPECT().LockOrderByNumber("2377225624").Return(&model.Order{
+						ID:     GetIntPointer(1),
+						Number: "2377225624",
+						Status: model.OrderStatusProcessed,
+						User:   &model.User{ID: GetIntPointer(1)},
+					}, nil),
+					f.repo.EXPECT().SaveRejectedStatusUpdate(gomock.Any()).DoAndReturn(func(update *model.RejectedStatusUpdate) error {
+						assert.Equal(t, "2377225624", update.OrderNumber)
+						assert.Equal(t, model.OrderStatusProcessed, update.FromStatus)
+						assert.Equal(t, model.OrderStatusProcessing, update.ToStatus)
+						return nil
+					}),
+				)
+			},
+			args: args{order: model.Order{
+				Number: "2377225624",
+				Status: model.OrderStatusProcessing,
+			}},
+			wantErr:       assert.Error,
+			wantErrorType: &errors.OrderStatusTransitionError{},
+		},
+		{
+			name: "should reject unknown status",
+			prepare: func(f *fields) {
+				gomock.InOrder(
+					expectAtomic(f.repo),
+					f.repo.EXPECT().LockOrderByNumber("2377225624").Return(&model.Order{
+						ID:     GetIntPointer(1),
+						Number: "2377225624",
+						Status: model.OrderStatusNew,
+						User:   &model.User{ID: GetIntPointer(1)},
+					}, nil),
+					f.repo.EXPECT().SaveRejectedStatusUpdate(gomock.Any()).Return(nil),
+				)
+			},
+			args: args{order: model.Order{
+				Number: "2377225624",
+				Status: "REGISTERED",
+			}},
+			wantErr:       assert.Error,
+			wantErrorType: &errors.OrderStatusTransitionError{},
+		},
 		{
 			name: "should return NoOrdersErr",
 			prepare: func(f *fields) {
@@ -226,7 +600,8 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 					UploadTime: time.Time{},
 				}
 				gomock.InOrder(
-					f.repo.EXPECT().GetOrderByNumber("2377225624").Return(orderInDB, nil),
+					expectAtomic(f.repo),
+					f.repo.EXPECT().LockOrderByNumber("2377225624").Return(orderInDB, nil),
 				)
 			},
 			args: args{order: model.Order{
@@ -251,7 +626,8 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 					User:       &model.User{ID: &id},
 				}
 				gomock.InOrder(
-					f.repo.EXPECT().GetOrderByNumber("2377225624").Return(orderInDB, nil),
+					expectAtomic(f.repo),
+					f.repo.EXPECT().LockOrderByNumber("2377225624").Return(orderInDB, nil),
 				)
 			},
 			args: args{order: model.Order{
@@ -275,34 +651,7 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 			}
 			orderService := s.UpdateOrderStatus(tt.args.order)
 			tt.wantErr(t, orderService, fmt.Sprintf("UpdateOrderStatus(%v)", tt.args.order))
-			assert.ErrorIs(t, orderService, tt.wantErrorType, fmt.Sprintf("UpdateOrderStatus(%v)", tt.args.order))
-		})
-	}
-}
-
-func Test_checkOrderFormat(t *testing.T) {
-	type args struct {
-		number int
-	}
-	tests := []struct {
-		name string
-		args args
-		want bool
-	}{
-		{
-			args: args{number: 2377225624},
-			name: "should success with right Luhn number",
-			want: true,
-		},
-		{
-			args: args{number: 1234567890},
-			name: "should fail with wrong Luhn number",
-			want: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, tt.want, checkOrderFormat(tt.args.number))
+			assert.IsType(t, tt.wantErrorType, orderService, fmt.Sprintf("UpdateOrderStatus(%v)", tt.args.order))
 		})
 	}
 }
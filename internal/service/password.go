@@ -0,0 +1,90 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+const (
+	argonTime    uint32 = 1
+	argonMemory  uint32 = 64 * 1024
+	argonThreads uint8  = 4
+	argonKeyLen  uint32 = 32
+	argonSaltLen        = 16
+	argonPrefix         = "$argon2id$"
+)
+
+// hashPassword returns argon2id hash of pw in PHC string format:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>
+func hashPassword(pw string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(pw), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argonPrefix,
+		argon2.Version,
+		argonMemory,
+		argonTime,
+		argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verifyPassword checks pw against encoded hash. Besides argon2id hashes it accepts
+// legacy unsalted sha256 hashes, for them and for argon2id hashes with outdated
+// parameters needRehash is true.
+func verifyPassword(pw string, encoded string) (ok bool, needRehash bool, err error) {
+	if !strings.HasPrefix(encoded, argonPrefix) {
+		ok = subtle.ConstantTimeCompare([]byte(legacyHashPW(pw)), []byte(encoded)) == 1
+		return ok, ok, nil
+	}
+
+	var (
+		version int
+		memory  uint32
+		time    uint32
+		threads uint8
+	)
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, fmt.Errorf("invalid password hash format")
+	}
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, false, err
+	}
+	if version != argon2.Version {
+		return false, false, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, err
+	}
+
+	otherKey := argon2.IDKey([]byte(pw), salt, time, memory, threads, uint32(len(key)))
+	ok = subtle.ConstantTimeCompare(key, otherKey) == 1
+	needRehash = ok && (memory != argonMemory ||
+		time != argonTime ||
+		threads != argonThreads ||
+		uint32(len(key)) != argonKeyLen)
+	return ok, needRehash, nil
+}
+
+func legacyHashPW(pw string) string {
+	pwHash := sha256.Sum256([]byte(pw))
+	return base64.StdEncoding.EncodeToString(pwHash[:])
+}
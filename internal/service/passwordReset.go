@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/notifier"
+	"time"
+)
+
+type PasswordReset interface {
+	RequestReset(login string, ip string) error
+	ResetPassword(reset model.PasswordReset) error
+}
+
+type PasswordResetService struct {
+	repo          dao.Repository
+	loginThrottle LoginThrottle
+	notifier      notifier.Notifier
+	cfg           *config.ServerConfig
+}
+
+func NewPasswordResetService(
+	repo dao.Repository,
+	loginThrottle LoginThrottle,
+	notifier notifier.Notifier,
+	cfg *config.ServerConfig,
+) PasswordReset {
+	return PasswordResetService{
+		repo:          repo,
+		loginThrottle: loginThrottle,
+		notifier:      notifier,
+		cfg:           cfg,
+	}
+}
+
+// RequestReset sends one-time reset token to the user. Unknown logins and delivery
+// failures are not reported to caller, token is sent in background, so that neither
+// result nor response time of the endpoint tell which logins exist. Requests are
+// throttled per login and client address, LoginLockedError is returned over the limit.
+func (s PasswordResetService) RequestReset(login string, ip string) error {
+	if login == "" {
+		validationErr := &errors.ValidationError{}
+		validationErr.Add("login", "must not be empty")
+		return validationErr
+	}
+	err := s.loginThrottle.CheckPasswordReset(login, ip)
+	if err != nil {
+		return err
+	}
+	user, err := s.repo.GetUserByLogin(login)
+	if err != nil {
+		return err
+	}
+	if user.ID == nil {
+		log.Warnf("password reset requested for unknown login %s", login)
+		return nil
+	}
+	go s.sendResetToken(user)
+	return nil
+}
+
+// sendResetToken gives up waiting for delivery after cfg.NotificationTimeout.
+func (s PasswordResetService) sendResetToken(user *model.User) {
+	token, err := randomToken(32)
+	if err != nil {
+		log.Errorf("cannot create password reset token for user %d: %s", *user.ID, err)
+		return
+	}
+	currentTime := time.Now()
+	resetToken := model.PasswordResetToken{
+		User:      user,
+		TokenHash: hashToken(token),
+		CreatedAt: currentTime,
+		ExpiresAt: currentTime.Add(s.cfg.PasswordResetTTL),
+	}
+	err = s.repo.SavePasswordResetToken(&resetToken)
+	if err != nil {
+		log.Errorf("cannot save password reset token for user %d: %s", *user.ID, err)
+		return
+	}
+
+	delivered := make(chan error, 1)
+	go func() {
+		delivered <- s.notifier.Notify(model.Notification{
+			Recipient: user.Login,
+			Subject:   "Password reset",
+			Body: fmt.Sprintf(
+				"Use this token to set a new password: %s\n"+
+					"It is valid until %s. If you did not request password reset, ignore this message.",
+				token,
+				resetToken.ExpiresAt.Format(time.RFC1123),
+			),
+		})
+	}()
+	select {
+	case err = <-delivered:
+	case <-time.After(s.cfg.NotificationTimeout):
+		err = fmt.Errorf("no answer in %s", s.cfg.NotificationTimeout)
+	}
+	if err != nil {
+		log.Errorf("cannot deliver password reset token to user %d: %s", *user.ID, err)
+	}
+}
+
+// ResetPassword sets new password by reset token. On success all reset tokens
+// of the user are spent and all the sessions are terminated.
+func (s PasswordResetService) ResetPassword(reset model.PasswordReset) error {
+	validationErr := &errors.ValidationError{}
+	validatePassword("new_password", reset.NewPassword, s.cfg, validationErr)
+	if validationErr.HasErrors() {
+		return validationErr
+	}
+	if reset.Token == "" {
+		return &errors.InvalidTokenError{}
+	}
+	pwHash, err := hashPassword(reset.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	return s.repo.Atomic(ctx, func(r dao.Repository) error {
+		token, err := r.UsePasswordResetToken(hashToken(reset.Token))
+		if err != nil {
+			return err
+		}
+		if token.ID == nil {
+			return &errors.InvalidTokenError{}
+		}
+		user, err := r.GetUserByID(*token.User.ID)
+		if err != nil {
+			return err
+		}
+		if user.ID == nil {
+			return &errors.InvalidTokenError{}
+		}
+		user.Password = pwHash
+		err = r.UpdateUserPassword(user)
+		if err != nil {
+			return err
+		}
+		err = r.InvalidatePasswordResetTokens(*user.ID)
+		if err != nil {
+			return err
+		}
+		return r.RevokeUserSessions(*user.ID)
+	})
+}
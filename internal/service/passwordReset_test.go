@@ -0,0 +1,191 @@
+package service
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+var resetConfig = &config.ServerConfig{
+	PasswordMinLength:    8,
+	PasswordRequireMixed: true,
+	PasswordResetTTL:     30 * time.Minute,
+	NotificationTimeout:  time.Second,
+	LoginDelayAfter:      3,
+	LoginLockoutAfter:    10,
+	LoginIPLockoutAfter:  100,
+	LoginLockoutDuration: 15 * time.Minute,
+	LoginFailureWindow:   15 * time.Minute,
+}
+
+// recordingNotifier passes notifications sent in background to test.
+type recordingNotifier struct {
+	notifications chan model.Notification
+}
+
+func (n *recordingNotifier) Notify(notification model.Notification) error {
+	n.notifications <- notification
+	return nil
+}
+
+func TestPasswordResetService_RequestReset(t *testing.T) {
+	lockedUntil := time.Now().Add(time.Minute)
+	tests := []struct {
+		name        string
+		login       string
+		prepare     func(repo *mock_dao.MockRepository)
+		wantErr     assert.ErrorAssertionFunc
+		wantErrType error
+		wantNote    bool
+	}{
+		{
+			name:  "should send token to existing user",
+			login: "test@example.com",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().CountLoginAttempt("ip:127.0.0.1", gomock.Any()).Return(true, nil),
+					repo.EXPECT().CountLoginAttempt("reset:test@example.com", gomock.Any()).Return(true, nil),
+					repo.EXPECT().GetUserByLogin("test@example.com").Return(&model.User{
+						ID:    GetIntPointer(1),
+						Login: "test@example.com",
+					}, nil),
+					repo.EXPECT().SavePasswordResetToken(gomock.Any()).DoAndReturn(func(token *model.PasswordResetToken) error {
+						assert.Equal(t, 1, *token.User.ID)
+						assert.Len(t, token.TokenHash, 64)
+						assert.WithinDuration(t, time.Now().Add(30*time.Minute), token.ExpiresAt, time.Minute)
+						return nil
+					}),
+				)
+			},
+			wantErr:  assert.NoError,
+			wantNote: true,
+		},
+		{
+			name:  "should silently ignore unknown login",
+			login: "unknown",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().CountLoginAttempt("ip:127.0.0.1", gomock.Any()).Return(true, nil),
+					repo.EXPECT().CountLoginAttempt("reset:unknown", gomock.Any()).Return(true, nil),
+					repo.EXPECT().GetUserByLogin("unknown").Return(&model.User{}, nil),
+				)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "should reject too many requests for login",
+			login: "test@example.com",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().CountLoginAttempt("ip:127.0.0.1", gomock.Any()).Return(true, nil),
+					repo.EXPECT().CountLoginAttempt("reset:test@example.com", gomock.Any()).Return(false, nil),
+					repo.EXPECT().GetLoginAttempt("reset:test@example.com").Return(&model.LoginAttempt{
+						LockedUntil: &lockedUntil,
+					}, nil),
+				)
+			},
+			wantErr:     assert.Error,
+			wantErrType: &errors.LoginLockedError{},
+		},
+		{
+			name:        "should reject empty login",
+			login:       "",
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			wantErr:     assert.Error,
+			wantErrType: &errors.ValidationError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			notifier := &recordingNotifier{notifications: make(chan model.Notification, 1)}
+			s := NewPasswordResetService(repo, NewLoginThrottleService(repo, resetConfig), notifier, resetConfig)
+			err := s.RequestReset(tt.login, "127.0.0.1")
+			tt.wantErr(t, err)
+			assert.IsType(t, tt.wantErrType, err)
+			if !tt.wantNote {
+				return
+			}
+			select {
+			case notification := <-notifier.notifications:
+				assert.Equal(t, tt.login, notification.Recipient)
+			case <-time.After(time.Second):
+				t.Fatal("reset token is not sent")
+			}
+		})
+	}
+}
+
+func TestPasswordResetService_ResetPassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		reset       model.PasswordReset
+		prepare     func(repo *mock_dao.MockRepository)
+		wantErr     assert.ErrorAssertionFunc
+		wantErrType error
+	}{
+		{
+			name:  "should set new password and spend token",
+			reset: model.PasswordReset{Token: "reset", NewPassword: "new12345"},
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().UsePasswordResetToken(hashToken("reset")).Return(&model.PasswordResetToken{
+						ID:        GetIntPointer(5),
+						User:      &model.User{ID: GetIntPointer(1)},
+						ExpiresAt: time.Now().Add(time.Minute),
+					}, nil),
+					repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1), Login: "test"}, nil),
+					repo.EXPECT().UpdateUserPassword(gomock.Any()).DoAndReturn(func(user *model.User) error {
+						assert.True(t, strings.HasPrefix(user.Password, argonPrefix))
+						return nil
+					}),
+					repo.EXPECT().InvalidatePasswordResetTokens(1).Return(nil),
+					repo.EXPECT().RevokeUserSessions(1).Return(nil),
+				)
+			},
+			wantErr:     assert.NoError,
+			wantErrType: nil,
+		},
+		{
+			name:  "should reject used or expired token",
+			reset: model.PasswordReset{Token: "reset", NewPassword: "new12345"},
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().UsePasswordResetToken(hashToken("reset")).Return(&model.PasswordResetToken{}, nil),
+				)
+			},
+			wantErr:     assert.Error,
+			wantErrType: &errors.InvalidTokenError{},
+		},
+		{
+			name:        "should return ValidationError on weak password",
+			reset:       model.PasswordReset{Token: "reset", NewPassword: "weak"},
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			wantErr:     assert.Error,
+			wantErrType: &errors.ValidationError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewPasswordResetService(repo, NewLoginThrottleService(repo, resetConfig), &recordingNotifier{}, resetConfig)
+			err := s.ResetPassword(tt.reset)
+			tt.wantErr(t, err)
+			assert.IsType(t, tt.wantErrType, err)
+		})
+	}
+}
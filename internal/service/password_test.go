@@ -0,0 +1,74 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_verifyPassword(t *testing.T) {
+	currentHash, _ := hashPassword("test")
+	tests := []struct {
+		name           string
+		pw             string
+		encoded        string
+		wantOk         bool
+		wantNeedRehash bool
+		wantErr        assert.ErrorAssertionFunc
+	}{
+		{
+			name:           "should verify argon2id hash",
+			pw:             "test",
+			encoded:        currentHash,
+			wantOk:         true,
+			wantNeedRehash: false,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:           "should fail argon2id hash with wrong password",
+			pw:             "wrong",
+			encoded:        currentHash,
+			wantOk:         false,
+			wantNeedRehash: false,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:           "should verify legacy hash and require rehash",
+			pw:             "test",
+			encoded:        "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=",
+			wantOk:         true,
+			wantNeedRehash: true,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:           "should require rehash for outdated parameters",
+			pw:             "test",
+			encoded:        "$argon2id$v=19$m=16,t=2,p=1$c29tZXNhbHRzb21lc2FsdA$4B3N9Cab1XP2ZE1SAUsIunYahGEcrb1+z+S7++EXXl8",
+			wantOk:         true,
+			wantNeedRehash: true,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:    "should return error on malformed hash",
+			pw:      "test",
+			encoded: "$argon2id$broken",
+			wantOk:  false,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, needRehash, err := verifyPassword(tt.pw, tt.encoded)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantNeedRehash, needRehash)
+		})
+	}
+}
+
+func Test_hashPassword(t *testing.T) {
+	first, err := hashPassword("test")
+	assert.NoError(t, err)
+	second, err := hashPassword("test")
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second, "hashes of same password must use different salts")
+}
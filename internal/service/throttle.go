@@ -0,0 +1,131 @@
+package service
+
+import (
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"time"
+)
+
+const (
+	// loginDelayBase is delay after cfg.LoginDelayAfter failed attempts, it doubles with every next one.
+	loginDelayBase = time.Second
+	loginDelayMax  = time.Minute
+	// loginAttemptKeyMaxLength is length of key column of login_attempts.
+	loginAttemptKeyMaxLength = 256
+)
+
+type LoginThrottle interface {
+	Check(login string, ip string) error
+	CheckPasswordReset(login string, ip string) error
+	RegisterSuccess(login string, ip string) error
+	ClearLockout(login string) error
+}
+
+type LoginThrottleService struct {
+	repo dao.Repository
+	cfg  *config.ServerConfig
+}
+
+func NewLoginThrottleService(repo dao.Repository, cfg *config.ServerConfig) LoginThrottle {
+	return LoginThrottleService{
+		repo: repo,
+		cfg:  cfg,
+	}
+}
+
+// Check counts attempt for client address and login before it is verified, so that concurrent
+// attempts can't pass the check together. It returns LoginLockedError when login or client address
+// is locked, or when progressive delay after previous attempt for login has not passed yet. Attempt
+// stays counted as failure, unless RegisterSuccess is called. Client address is checked first, so
+// that attempts from locked one aren't counted for logins.
+func (s LoginThrottleService) Check(login string, ip string) error {
+	return s.check(loginKey(login), ip)
+}
+
+// CheckPasswordReset limits password reset requests as Check limits logins. Requests are counted
+// apart from logins, so that requesting reset for somebody's login can't lock it.
+func (s LoginThrottleService) CheckPasswordReset(login string, ip string) error {
+	return s.check(passwordResetKey(login), ip)
+}
+
+func (s LoginThrottleService) check(key string, ip string) error {
+	windowStart := time.Now().Add(-s.cfg.LoginFailureWindow)
+	err := countLoginAttempt(s.repo, ipKey(ip), model.LoginAttemptPolicy{
+		WindowStart:     windowStart,
+		LockoutAfter:    s.cfg.LoginIPLockoutAfter,
+		LockoutDuration: s.cfg.LoginLockoutDuration,
+	})
+	if err != nil {
+		return err
+	}
+	return countLoginAttempt(s.repo, key, model.LoginAttemptPolicy{
+		WindowStart:     windowStart,
+		DelayAfter:      s.cfg.LoginDelayAfter,
+		DelayBase:       loginDelayBase,
+		DelayMax:        loginDelayMax,
+		LockoutAfter:    s.cfg.LoginLockoutAfter,
+		LockoutDuration: s.cfg.LoginLockoutDuration,
+	})
+}
+
+// RegisterSuccess resets failures of login. Failures of client address are kept,
+// so that attacker can't reset them by logging into own account, only the successful
+// attempt is uncounted.
+func (s LoginThrottleService) RegisterSuccess(login string, ip string) error {
+	err := s.repo.DeleteLoginAttempt(loginKey(login))
+	if err != nil {
+		return err
+	}
+	return s.repo.ForgiveLoginAttempt(ipKey(ip))
+}
+
+func (s LoginThrottleService) ClearLockout(login string) error {
+	return s.repo.DeleteLoginAttempt(loginKey(login))
+}
+
+// countLoginAttempt returns LoginLockedError when key is locked and attempt is not counted.
+func countLoginAttempt(repo dao.Repository, key string, policy model.LoginAttemptPolicy) error {
+	counted, err := repo.CountLoginAttempt(key, policy)
+	if err != nil {
+		return err
+	}
+	if counted {
+		return nil
+	}
+	attempt, err := repo.GetLoginAttempt(key)
+	if err != nil {
+		return err
+	}
+	// lock may have just expired, client should retry then
+	retryAfter := time.Second
+	if attempt.LockedUntil != nil {
+		if until := time.Until(*attempt.LockedUntil); until > retryAfter {
+			retryAfter = until
+		}
+	}
+	return &errors.LoginLockedError{RetryAfter: retryAfter}
+}
+
+// loginKey hashes login, which doesn't fit into key column. Such key contains ':' after
+// prefix, which is not allowed in logins, so it can't collide with key of another login.
+func loginKey(login string) string {
+	return attemptKey("login:", login)
+}
+
+func passwordResetKey(login string) string {
+	return attemptKey("reset:", login)
+}
+
+func attemptKey(prefix string, login string) string {
+	key := prefix + login
+	if len(key) > loginAttemptKeyMaxLength {
+		return prefix + "sha256:" + hashToken(login)
+	}
+	return key
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+var throttleConfig = &config.ServerConfig{
+	LoginDelayAfter:      3,
+	LoginLockoutAfter:    10,
+	LoginIPLockoutAfter:  100,
+	LoginLockoutDuration: 15 * time.Minute,
+	LoginFailureWindow:   15 * time.Minute,
+}
+
+func TestLoginThrottleService_Check(t *testing.T) {
+	lockedUntil := time.Now().Add(10 * time.Minute)
+	tests := []struct {
+		name           string
+		prepare        func(repo *mock_dao.MockRepository)
+		wantErr        assert.ErrorAssertionFunc
+		wantRetryAfter time.Duration
+	}{
+		{
+			name: "should count attempt for client address and login",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().CountLoginAttempt("ip:127.0.0.1", gomock.Any()).DoAndReturn(
+						func(key string, policy model.LoginAttemptPolicy) (bool, error) {
+							assert.Equal(t, 0, policy.DelayAfter)
+							assert.Equal(t, 100, policy.LockoutAfter)
+							return true, nil
+						}),
+					repo.EXPECT().CountLoginAttempt("login:test", gomock.Any()).DoAndReturn(
+						func(key string, policy model.LoginAttemptPolicy) (bool, error) {
+							assert.Equal(t, 3, policy.DelayAfter)
+							assert.Equal(t, loginDelayBase, policy.DelayBase)
+							assert.Equal(t, 10, policy.LockoutAfter)
+							assert.Equal(t, 15*time.Minute, policy.LockoutDuration)
+							assert.WithinDuration(t, time.Now().Add(-15*time.Minute), policy.WindowStart, time.Second)
+							return true, nil
+						}),
+				)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "should reject locked login",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().CountLoginAttempt("ip:127.0.0.1", gomock.Any()).Return(true, nil),
+					repo.EXPECT().CountLoginAttempt("login:test", gomock.Any()).Return(false, nil),
+					repo.EXPECT().GetLoginAttempt("login:test").Return(&model.LoginAttempt{
+						Key:         "login:test",
+						Failures:    5,
+						LockedUntil: &lockedUntil,
+					}, nil),
+				)
+			},
+			wantErr:        assert.Error,
+			wantRetryAfter: 9 * time.Minute,
+		},
+		{
+			name: "should not count attempt for login from locked client address",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().CountLoginAttempt("ip:127.0.0.1", gomock.Any()).Return(false, nil),
+					repo.EXPECT().GetLoginAttempt("ip:127.0.0.1").Return(&model.LoginAttempt{
+						Key:         "ip:127.0.0.1",
+						Failures:    100,
+						LockedUntil: &lockedUntil,
+					}, nil),
+				)
+			},
+			wantErr:        assert.Error,
+			wantRetryAfter: 9 * time.Minute,
+		},
+		{
+			name: "should ask to retry shortly when lock has just expired",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().CountLoginAttempt("ip:127.0.0.1", gomock.Any()).Return(true, nil),
+					repo.EXPECT().CountLoginAttempt("login:test", gomock.Any()).Return(false, nil),
+					repo.EXPECT().GetLoginAttempt("login:test").Return(&model.LoginAttempt{Key: "login:test"}, nil),
+				)
+			},
+			wantErr:        assert.Error,
+			wantRetryAfter: time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewLoginThrottleService(repo, throttleConfig)
+			err := s.Check("test", "127.0.0.1")
+			tt.wantErr(t, err)
+			if err != nil {
+				assert.IsType(t, &errors.LoginLockedError{}, err)
+				assert.GreaterOrEqual(t, err.(*errors.LoginLockedError).RetryAfter, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleService_RegisterSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().DeleteLoginAttempt("login:test").Return(nil),
+		repo.EXPECT().ForgiveLoginAttempt("ip:127.0.0.1").Return(nil),
+	)
+	s := NewLoginThrottleService(repo, throttleConfig)
+	assert.NoError(t, s.RegisterSuccess("test", "127.0.0.1"))
+}
+
+func Test_loginKey(t *testing.T) {
+	assert.Equal(t, "login:test", loginKey("test"))
+	long := strings.Repeat("a", loginAttemptKeyMaxLength)
+	assert.Equal(t, "login:sha256:"+hashToken(long), loginKey(long))
+	assert.LessOrEqual(t, len(loginKey(long)), loginAttemptKeyMaxLength)
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/go-chi/jwtauth/v5"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/keys"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"time"
+)
+
+const (
+	// sessionTouchInterval limits how often last seen time of session is written.
+	sessionTouchInterval = time.Minute
+	userAgentMaxLength   = 512
+)
+
+type Token interface {
+	IssueTokens(user model.User, client model.ClientInfo) (*model.Tokens, error)
+	RefreshTokens(refreshToken string) (*model.Tokens, error)
+	Logout(jti string, sessionID int, expiresAt time.Time) error
+	IsRevoked(jti string, sessionID int) (bool, error)
+	ListSessions(userID int, currentSessionID int) ([]model.Session, error)
+	RevokeSession(userID int, sessionID int) error
+	PurgeExpired() error
+}
+
+type TokenService struct {
+	repo   dao.Repository
+	keySet *keys.KeySet
+	cfg    *config.ServerConfig
+}
+
+func NewTokenService(repo dao.Repository, keySet *keys.KeySet, cfg *config.ServerConfig) Token {
+	return TokenService{
+		repo:   repo,
+		keySet: keySet,
+		cfg:    cfg,
+	}
+}
+
+// IssueTokens starts new session for user and returns access and refresh tokens bound to it.
+func (s TokenService) IssueTokens(user model.User, client model.ClientInfo) (*model.Tokens, error) {
+	var tokens *model.Tokens
+	userAgent := client.UserAgent
+	if len(userAgent) > userAgentMaxLength {
+		userAgent = userAgent[:userAgentMaxLength]
+	}
+	ctx := context.Background()
+	err := s.repo.Atomic(ctx, func(r dao.Repository) error {
+		currentTime := time.Now()
+		session := model.Session{
+			User:       &user,
+			UserAgent:  userAgent,
+			IP:         client.IP,
+			CreatedAt:  currentTime,
+			LastSeenAt: currentTime,
+		}
+		err := r.SaveSession(&session)
+		if err != nil {
+			return err
+		}
+		tokens, err = s.issueForSession(r, session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RefreshTokens exchanges refresh token for a new token pair in the same session.
+// Every refresh token can be used only once, presenting an already used token
+// revokes whole session, as it most likely was stolen.
+func (s TokenService) RefreshTokens(refreshToken string) (*model.Tokens, error) {
+	var (
+		tokens          *model.Tokens
+		reusedSessionID *int
+	)
+	ctx := context.Background()
+	err := s.repo.Atomic(ctx, func(r dao.Repository) error {
+		token, err := r.GetRefreshTokenByHash(hashToken(refreshToken))
+		if err != nil {
+			return err
+		}
+		if token.ID == nil || token.ExpiresAt.Before(time.Now()) {
+			return &errors.InvalidTokenError{}
+		}
+		session, err := r.GetSessionByID(*token.Session.ID)
+		if err != nil {
+			return err
+		}
+		if session.ID == nil || session.RevokedAt != nil {
+			return &errors.InvalidTokenError{}
+		}
+		if token.UsedAt != nil {
+			reusedSessionID = session.ID
+			return &errors.InvalidTokenError{}
+		}
+		marked, err := r.MarkRefreshTokenUsed(*token.ID)
+		if err != nil {
+			return err
+		}
+		if !marked {
+			// token was used by concurrent refresh
+			reusedSessionID = session.ID
+			return &errors.InvalidTokenError{}
+		}
+		tokens, err = s.issueForSession(r, *session)
+		return err
+	})
+	if reusedSessionID != nil {
+		// revoke outside of transaction, as it was rolled back
+		log.Warnf("refresh token reuse detected, revoking session %d", *reusedSessionID)
+		if revokeErr := s.repo.RevokeSession(*reusedSessionID); revokeErr != nil {
+			log.Error("cannot revoke session: ", revokeErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Logout revokes access token by its jti and the session it belongs to.
+func (s TokenService) Logout(jti string, sessionID int, expiresAt time.Time) error {
+	ctx := context.Background()
+	return s.repo.Atomic(ctx, func(r dao.Repository) error {
+		if jti != "" {
+			err := r.SaveRevokedToken(jti, expiresAt)
+			if err != nil {
+				return err
+			}
+		}
+		return r.RevokeSession(sessionID)
+	})
+}
+
+// IsRevoked checks token on every authenticated request, so it also keeps last seen time of
+// the session up to date.
+func (s TokenService) IsRevoked(jti string, sessionID int) (bool, error) {
+	revoked, err := s.repo.IsTokenRevoked(jti)
+	if err != nil || revoked {
+		return revoked, err
+	}
+	session, err := s.repo.GetSessionByID(sessionID)
+	if err != nil {
+		return false, err
+	}
+	if session.ID == nil || session.RevokedAt != nil {
+		return true, nil
+	}
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := s.repo.TouchSession(sessionID); err != nil {
+			log.Error("cannot update session last seen time: ", err)
+		}
+	}
+	return false, nil
+}
+
+func (s TokenService) ListSessions(userID int, currentSessionID int) ([]model.Session, error) {
+	sessions, err := s.repo.GetActiveSessionsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = *sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs user out of session, access tokens of the session are rejected
+// from now on and its refresh token cannot be used anymore.
+func (s TokenService) RevokeSession(userID int, sessionID int) error {
+	revoked, err := s.repo.RevokeUserSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return &errors.SessionNotFoundError{SessionID: sessionID}
+	}
+	return nil
+}
+
+func (s TokenService) PurgeExpired() error {
+	return s.repo.DeleteExpiredTokens()
+}
+
+// issueForSession signs access token and stores new refresh token for session. User is
+// reloaded, so role claim always reflects the stored role.
+func (s TokenService) issueForSession(r dao.Repository, session model.Session) (*model.Tokens, error) {
+	user, err := r.GetUserByID(*session.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == nil {
+		return nil, &errors.InvalidTokenError{}
+	}
+	role := user.Role
+	if role == "" {
+		role = model.RoleUser
+	}
+
+	currentTime := time.Now()
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]interface{}{
+		"user_id": *user.ID,
+		"sid":     *session.ID,
+		"jti":     jti,
+		"role":    role,
+	}
+	expiresAt := currentTime.Add(s.cfg.AccessTokenTTL)
+	jwtauth.SetIssuedAt(claims, currentTime)
+	jwtauth.SetExpiry(claims, expiresAt)
+	accessToken, err := s.keySet.Encode(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	err = r.SaveRefreshToken(&model.RefreshToken{
+		Session:   &session,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: currentTime.Add(s.cfg.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Tokens{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: expiresAt,
+		RefreshToken:         refreshToken,
+	}, nil
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
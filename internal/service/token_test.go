@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/keys"
+	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"testing"
+	"time"
+)
+
+var testConfig = &config.ServerConfig{
+	AccessTokenTTL:  5 * time.Minute,
+	RefreshTokenTTL: time.Hour,
+}
+
+func expectAtomic(repo *mock_dao.MockRepository) *gomock.Call {
+	return repo.EXPECT().
+		Atomic(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(r dao.Repository) error) error {
+			return fn(repo)
+		})
+}
+
+func TestTokenService_IssueTokens(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	keySet, _ := keys.GenerateKeySet()
+
+	gomock.InOrder(
+		expectAtomic(repo),
+		repo.EXPECT().SaveSession(gomock.Any()).DoAndReturn(func(session *model.Session) error {
+			assert.Equal(t, "test-agent", session.UserAgent)
+			assert.Equal(t, "127.0.0.1", session.IP)
+			session.ID = GetIntPointer(10)
+			return nil
+		}),
+		repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1), Role: model.RoleSupport}, nil),
+		repo.EXPECT().SaveRefreshToken(gomock.Any()).Return(nil),
+	)
+
+	s := NewTokenService(repo, keySet, testConfig)
+	tokens, err := s.IssueTokens(model.User{ID: GetIntPointer(1)}, model.ClientInfo{
+		UserAgent: "test-agent",
+		IP:        "127.0.0.1",
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	token, err := keySet.Decode(tokens.AccessToken)
+	assert.NoError(t, err)
+	claims, _ := token.AsMap(context.Background())
+	assert.Equal(t, float64(1), claims["user_id"])
+	assert.Equal(t, float64(10), claims["sid"])
+	assert.Equal(t, model.RoleSupport, claims["role"])
+	assert.NotEmpty(t, claims["jti"])
+}
+
+func TestTokenService_RefreshTokens(t *testing.T) {
+	type fields struct {
+		repo *mock_dao.MockRepository
+	}
+	usedAt := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name        string
+		prepare     func(f *fields, tokenHash string)
+		wantErr     assert.ErrorAssertionFunc
+		wantErrType error
+	}{
+		{
+			name: "should issue new tokens",
+			prepare: func(f *fields, tokenHash string) {
+				gomock.InOrder(
+					expectAtomic(f.repo),
+					f.repo.EXPECT().GetRefreshTokenByHash(tokenHash).Return(&model.RefreshToken{
+						ID:        GetIntPointer(1),
+						Session:   &model.Session{ID: GetIntPointer(10)},
+						ExpiresAt: time.Now().Add(time.Hour),
+					}, nil),
+					f.repo.EXPECT().GetSessionByID(10).Return(&model.Session{
+						ID:   GetIntPointer(10),
+						User: &model.User{ID: GetIntPointer(1)},
+					}, nil),
+					f.repo.EXPECT().MarkRefreshTokenUsed(1).Return(true, nil),
+					f.repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1), Role: model.RoleUser}, nil),
+					f.repo.EXPECT().SaveRefreshToken(gomock.Any()).Return(nil),
+				)
+			},
+			wantErr:     assert.NoError,
+			wantErrType: nil,
+		},
+		{
+			name: "should return InvalidTokenError for expired token",
+			prepare: func(f *fields, tokenHash string) {
+				gomock.InOrder(
+					expectAtomic(f.repo),
+					f.repo.EXPECT().GetRefreshTokenByHash(tokenHash).Return(&model.RefreshToken{
+						ID:        GetIntPointer(1),
+						Session:   &model.Session{ID: GetIntPointer(10)},
+						ExpiresAt: time.Now().Add(-time.Hour),
+					}, nil),
+				)
+			},
+			wantErr:     assert.Error,
+			wantErrType: &errors.InvalidTokenError{},
+		},
+		{
+			name: "should revoke session on concurrent token use",
+			prepare: func(f *fields, tokenHash string) {
+				gomock.InOrder(
+					expectAtomic(f.repo),
+					f.repo.EXPECT().GetRefreshTokenByHash(tokenHash).Return(&model.RefreshToken{
+						ID:        GetIntPointer(1),
+						Session:   &model.Session{ID: GetIntPointer(10)},
+						ExpiresAt: time.Now().Add(time.Hour),
+					}, nil),
+					f.repo.EXPECT().GetSessionByID(10).Return(&model.Session{
+						ID:   GetIntPointer(10),
+						User: &model.User{ID: GetIntPointer(1)},
+					}, nil),
+					f.repo.EXPECT().MarkRefreshTokenUsed(1).Return(false, nil),
+					f.repo.EXPECT().RevokeSession(10).Return(nil),
+				)
+			},
+			wantErr:     assert.Error,
+			wantErrType: &errors.InvalidTokenError{},
+		},
+		{
+			name: "should revoke session on token reuse",
+			prepare: func(f *fields, tokenHash string) {
+				gomock.InOrder(
+					expectAtomic(f.repo),
+					f.repo.EXPECT().GetRefreshTokenByHash(tokenHash).Return(&model.RefreshToken{
+						ID:        GetIntPointer(1),
+						Session:   &model.Session{ID: GetIntPointer(10)},
+						ExpiresAt: time.Now().Add(time.Hour),
+						UsedAt:    &usedAt,
+					}, nil),
+					f.repo.EXPECT().GetSessionByID(10).Return(&model.Session{
+						ID:   GetIntPointer(10),
+						User: &model.User{ID: GetIntPointer(1)},
+					}, nil),
+					f.repo.EXPECT().RevokeSession(10).Return(nil),
+				)
+			},
+			wantErr:     assert.Error,
+			wantErrType: &errors.InvalidTokenError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			f := fields{repo: mock_dao.NewMockRepository(ctrl)}
+			tt.prepare(&f, hashToken("refresh"))
+			keySet, _ := keys.GenerateKeySet()
+			s := NewTokenService(f.repo, keySet, testConfig)
+			_, err := s.RefreshTokens("refresh")
+			tt.wantErr(t, err, fmt.Sprintf("RefreshTokens(%v)", "refresh"))
+			assert.IsType(t, tt.wantErrType, err)
+		})
+	}
+}
+
+func TestTokenService_IsRevoked(t *testing.T) {
+	revokedAt := time.Now()
+	tests := []struct {
+		name    string
+		prepare func(repo *mock_dao.MockRepository)
+		want    bool
+	}{
+		{
+			name: "should accept active session",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().IsTokenRevoked("jti").Return(false, nil)
+				repo.EXPECT().GetSessionByID(10).Return(&model.Session{
+					ID:         GetIntPointer(10),
+					LastSeenAt: time.Now(),
+				}, nil)
+			},
+			want: false,
+		},
+		{
+			name: "should update last seen time of idle session",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().IsTokenRevoked("jti").Return(false, nil)
+				repo.EXPECT().GetSessionByID(10).Return(&model.Session{
+					ID:         GetIntPointer(10),
+					LastSeenAt: time.Now().Add(-time.Hour),
+				}, nil)
+				repo.EXPECT().TouchSession(10).Return(nil)
+			},
+			want: false,
+		},
+		{
+			name: "should reject revoked token",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().IsTokenRevoked("jti").Return(true, nil)
+			},
+			want: true,
+		},
+		{
+			name: "should reject token of logged out session",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().IsTokenRevoked("jti").Return(false, nil)
+				repo.EXPECT().GetSessionByID(10).Return(&model.Session{
+					ID:        GetIntPointer(10),
+					RevokedAt: &revokedAt,
+				}, nil)
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewTokenService(repo, nil, testConfig)
+			got, err := s.IsRevoked("jti", 10)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTokenService_ListSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	repo.EXPECT().GetActiveSessionsByUserID(1).Return([]model.Session{
+		{ID: GetIntPointer(10), UserAgent: "phone"},
+		{ID: GetIntPointer(11), UserAgent: "laptop"},
+	}, nil)
+	s := NewTokenService(repo, nil, testConfig)
+	sessions, err := s.ListSessions(1, 11)
+	assert.NoError(t, err)
+	assert.False(t, sessions[0].Current)
+	assert.True(t, sessions[1].Current)
+}
+
+func TestTokenService_RevokeSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().RevokeUserSession(1, 10).Return(true, nil),
+		repo.EXPECT().RevokeUserSession(1, 12).Return(false, nil),
+	)
+	s := NewTokenService(repo, nil, testConfig)
+	assert.NoError(t, s.RevokeSession(1, 10))
+	assert.IsType(t, &errors.SessionNotFoundError{}, s.RevokeSession(1, 12))
+}
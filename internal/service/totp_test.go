@@ -0,0 +1,64 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_totpCode(t *testing.T) {
+	// RFC 6238 appendix B test vectors for SHA1, truncated to 6 digits
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, totpCode(key, tt.unix/totpPeriod), "time %d", tt.unix)
+	}
+}
+
+func Test_verifyTOTP(t *testing.T) {
+	secret := base32NoPadding.EncodeToString([]byte("12345678901234567890"))
+	at := time.Unix(1111111111, 0)
+
+	step, ok := verifyTOTP(secret, "050471", at)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1111111111/totpPeriod), step)
+
+	_, ok = verifyTOTP(secret, "050471", at.Add(totpPeriod*time.Second))
+	assert.True(t, ok, "code of previous step should be accepted")
+
+	_, ok = verifyTOTP(secret, "050471", at.Add(3*totpPeriod*time.Second))
+	assert.False(t, ok)
+
+	_, ok = verifyTOTP(secret, "000000", at)
+	assert.False(t, ok)
+}
+
+func Test_provisioningURI(t *testing.T) {
+	uri, err := url.Parse(provisioningURI("Gophermart", "user@example.com", "SECRET"))
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Gophermart:user@example.com", uri.Path)
+	assert.Equal(t, "SECRET", uri.Query().Get("secret"))
+	assert.Equal(t, "Gophermart", uri.Query().Get("issuer"))
+}
+
+func Test_newRecoveryCodes(t *testing.T) {
+	codes, hashes, err := newRecoveryCodes()
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodesCount)
+	assert.Len(t, hashes, recoveryCodesCount)
+	assert.Regexp(t, "^[a-z2-7]{5}-[a-z2-7]{5}$", codes[0])
+	assert.Equal(t, hashes[0], hashRecoveryCode(" "+codes[0][:5]+codes[0][6:]))
+}
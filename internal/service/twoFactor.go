@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"strings"
+	"time"
+)
+
+// loginChallengeMaxAttempts is number of wrong codes, after which login challenge
+// is rejected and user has to enter password again.
+const loginChallengeMaxAttempts = 5
+
+// twoFactorCodeMaxAttempts is number of wrong codes of signed in user, e.g. for withdrawals, after
+// which codes are locked for cfg.LoginLockoutDuration.
+const twoFactorCodeMaxAttempts = 5
+
+type TwoFactor interface {
+	Enroll(userID int) (*model.TwoFactorEnrollment, error)
+	Confirm(userID int, code string) (*model.RecoveryCodes, error)
+	Disable(userID int, code string) error
+	IsEnabled(userID int) (bool, error)
+	StartChallenge(user model.User) (string, error)
+	CompleteChallenge(challengeToken string, code string, ip string) (*model.User, error)
+	AuthorizeWithdrawal(userID int, sum float32, code string) error
+}
+
+type TwoFactorService struct {
+	repo          dao.Repository
+	loginThrottle LoginThrottle
+	cfg           *config.ServerConfig
+}
+
+func NewTwoFactorService(repo dao.Repository, loginThrottle LoginThrottle, cfg *config.ServerConfig) TwoFactor {
+	return TwoFactorService{
+		repo:          repo,
+		loginThrottle: loginThrottle,
+		cfg:           cfg,
+	}
+}
+
+// Enroll generates new TOTP secret. It takes effect only after Confirm with a code
+// generated from it, until then enrollment can be started over.
+func (s TwoFactorService) Enroll(userID int) (*model.TwoFactorEnrollment, error) {
+	twoFactor, err := s.repo.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor.Enabled {
+		return nil, &errors.TwoFactorAlreadyEnabledError{}
+	}
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.SaveTwoFactor(&model.TwoFactor{
+		User:   user,
+		Secret: secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: provisioningURI(s.cfg.TwoFactorIssuer, user.Login, secret),
+	}, nil
+}
+
+// Confirm enables two-factor authentication and returns recovery codes, which are
+// shown only once.
+func (s TwoFactorService) Confirm(userID int, code string) (*model.RecoveryCodes, error) {
+	twoFactor, err := s.repo.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor.Enabled {
+		return nil, &errors.TwoFactorAlreadyEnabledError{}
+	}
+	if twoFactor.Secret == "" {
+		return nil, &errors.TwoFactorNotEnrolledError{}
+	}
+	step, ok := verifyTOTP(twoFactor.Secret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, &errors.InvalidTwoFactorCodeError{}
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	twoFactor.Enabled = true
+	twoFactor.LastUsedStep = step
+	ctx := context.Background()
+	err = s.repo.Atomic(ctx, func(r dao.Repository) error {
+		err := r.SaveTwoFactor(twoFactor)
+		if err != nil {
+			return err
+		}
+		return r.SaveRecoveryCodes(userID, hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.RecoveryCodes{Codes: codes}, nil
+}
+
+// Disable turns two-factor authentication off, code may be either TOTP or recovery code.
+// Wrong codes are limited as in AuthorizeWithdrawal.
+func (s TwoFactorService) Disable(userID int, code string) error {
+	twoFactor, err := s.repo.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	if !twoFactor.Enabled {
+		return &errors.TwoFactorNotEnrolledError{}
+	}
+	err = s.verifyCodeLimited(twoFactor, code, true)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteTwoFactor(userID)
+}
+
+func (s TwoFactorService) IsEnabled(userID int) (bool, error) {
+	twoFactor, err := s.repo.GetTwoFactor(userID)
+	if err != nil {
+		return false, err
+	}
+	return twoFactor.Enabled, nil
+}
+
+// StartChallenge returns short-lived token, which is exchanged for access token
+// together with TOTP or recovery code in CompleteChallenge.
+func (s TwoFactorService) StartChallenge(user model.User) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = s.repo.SaveLoginChallenge(&model.LoginChallenge{
+		User:      &user,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.cfg.TwoFactorChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CompleteChallenge is throttled as password login is, so code can't be guessed with new challenges
+// while login or client address is locked.
+func (s TwoFactorService) CompleteChallenge(challengeToken string, code string, ip string) (*model.User, error) {
+	challenge, err := s.repo.GetLoginChallengeByHash(hashToken(challengeToken))
+	if err != nil {
+		return nil, err
+	}
+	if challenge.ID == nil ||
+		challenge.UsedAt != nil ||
+		challenge.ExpiresAt.Before(time.Now()) ||
+		challenge.Attempts >= loginChallengeMaxAttempts {
+		return nil, &errors.InvalidTokenError{}
+	}
+	user, err := s.repo.GetUserByID(*challenge.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	twoFactor, err := s.repo.GetTwoFactor(*challenge.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == nil || !twoFactor.Enabled {
+		return nil, &errors.InvalidTokenError{}
+	}
+	err = s.loginThrottle.Check(user.Login, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.verifyCode(twoFactor, code, true)
+	if err != nil {
+		if _, ok := err.(*errors.InvalidTwoFactorCodeError); ok {
+			if err := s.repo.IncrementLoginChallengeAttempts(*challenge.ID); err != nil {
+				log.Error("cannot count login challenge attempt: ", err)
+			}
+		}
+		return nil, err
+	}
+	ok, err := s.repo.UseLoginChallenge(*challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &errors.InvalidTokenError{}
+	}
+	return user, nil
+}
+
+// AuthorizeWithdrawal requires fresh TOTP code for withdrawals above configured
+// threshold from accounts with enabled two-factor authentication.
+func (s TwoFactorService) AuthorizeWithdrawal(userID int, sum float32, code string) error {
+	threshold := s.cfg.TwoFactorWithdrawThreshold
+	if threshold <= 0 || float64(sum) <= threshold {
+		return nil
+	}
+	twoFactor, err := s.repo.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	if !twoFactor.Enabled {
+		return nil
+	}
+	if code == "" {
+		return &errors.TwoFactorRequiredError{}
+	}
+	return s.verifyCodeLimited(twoFactor, code, false)
+}
+
+// verifyCodeLimited verifies code of signed in user. Codes are limited to twoFactorCodeMaxAttempts
+// wrong ones per user, after which LoginLockedError is returned for cfg.LoginLockoutDuration,
+// so stolen access token is not enough to guess code. Attempt is counted before code is verified,
+// so concurrent ones can't exceed the limit.
+func (s TwoFactorService) verifyCodeLimited(twoFactor *model.TwoFactor, code string, allowRecovery bool) error {
+	key := twoFactorCodeKey(*twoFactor.User.ID)
+	err := countLoginAttempt(s.repo, key, model.LoginAttemptPolicy{
+		WindowStart:     time.Now().Add(-s.cfg.LoginFailureWindow),
+		LockoutAfter:    twoFactorCodeMaxAttempts,
+		LockoutDuration: s.cfg.LoginLockoutDuration,
+	})
+	if err != nil {
+		return err
+	}
+	err = s.verifyCode(twoFactor, code, allowRecovery)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteLoginAttempt(key)
+}
+
+// twoFactorCodeKey counts wrong codes of signed in user along with failed logins. Withdrawals
+// and disabling share it, so that attempts can't be spread over both.
+func twoFactorCodeKey(userID int) string {
+	return fmt.Sprintf("2fa:%d", userID)
+}
+
+// verifyCode accepts TOTP code not used before, or unused recovery code when allowRecovery is set.
+func (s TwoFactorService) verifyCode(twoFactor *model.TwoFactor, code string, allowRecovery bool) error {
+	userID := *twoFactor.User.ID
+	code = normalizeCode(code)
+	if step, ok := verifyTOTP(twoFactor.Secret, code, time.Now()); ok {
+		fresh, err := s.repo.UseTOTPStep(userID, step)
+		if err != nil {
+			return err
+		}
+		if fresh {
+			return nil
+		}
+		log.Warnf("replayed TOTP code for user %d", userID)
+		return &errors.InvalidTwoFactorCodeError{}
+	}
+	if allowRecovery && code != "" {
+		used, err := s.repo.UseRecoveryCode(userID, hashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+		if used {
+			log.Warnf("recovery code used by user %d", userID)
+			return nil
+		}
+	}
+	return &errors.InvalidTwoFactorCodeError{}
+}
+
+func normalizeCode(code string) string {
+	return strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+}
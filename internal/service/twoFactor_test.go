@@ -0,0 +1,346 @@
+package service
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"testing"
+	"time"
+)
+
+var twoFactorConfig = &config.ServerConfig{
+	TwoFactorIssuer:            "Gophermart",
+	TwoFactorChallengeTTL:      5 * time.Minute,
+	TwoFactorWithdrawThreshold: 1000,
+	LoginFailureWindow:         15 * time.Minute,
+	LoginLockoutDuration:       15 * time.Minute,
+}
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func currentTOTP() (string, int64) {
+	key, _ := base32NoPadding.DecodeString(testTOTPSecret)
+	step := time.Now().Unix() / totpPeriod
+	return totpCode(key, step), step
+}
+
+func enabledTwoFactor() *model.TwoFactor {
+	return &model.TwoFactor{
+		User:    &model.User{ID: GetIntPointer(1)},
+		Secret:  testTOTPSecret,
+		Enabled: true,
+	}
+}
+
+func TestTwoFactorService_Confirm(t *testing.T) {
+	code, step := currentTOTP()
+	tests := []struct {
+		name        string
+		code        string
+		prepare     func(repo *mock_dao.MockRepository)
+		wantErr     assert.ErrorAssertionFunc
+		wantErrType error
+	}{
+		{
+			name: "should enable two-factor authentication",
+			code: code,
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().GetTwoFactor(1).Return(&model.TwoFactor{
+						User:   &model.User{ID: GetIntPointer(1)},
+						Secret: testTOTPSecret,
+					}, nil),
+					expectAtomic(repo),
+					repo.EXPECT().SaveTwoFactor(gomock.Any()).DoAndReturn(func(twoFactor *model.TwoFactor) error {
+						assert.True(t, twoFactor.Enabled)
+						assert.Equal(t, step, twoFactor.LastUsedStep)
+						return nil
+					}),
+					repo.EXPECT().SaveRecoveryCodes(1, gomock.Len(recoveryCodesCount)).Return(nil),
+				)
+			},
+			wantErr:     assert.NoError,
+			wantErrType: nil,
+		},
+		{
+			name: "should reject wrong code",
+			code: "000000",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetTwoFactor(1).Return(&model.TwoFactor{
+					User:   &model.User{ID: GetIntPointer(1)},
+					Secret: testTOTPSecret,
+				}, nil)
+			},
+			wantErr:     assert.Error,
+			wantErrType: &errors.InvalidTwoFactorCodeError{},
+		},
+		{
+			name: "should require enrollment",
+			code: code,
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetTwoFactor(1).Return(&model.TwoFactor{User: &model.User{ID: GetIntPointer(1)}}, nil)
+			},
+			wantErr:     assert.Error,
+			wantErrType: &errors.TwoFactorNotEnrolledError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewTwoFactorService(repo, NewLoginThrottleService(repo, twoFactorConfig), twoFactorConfig)
+			codes, err := s.Confirm(1, tt.code)
+			tt.wantErr(t, err)
+			assert.IsType(t, tt.wantErrType, err)
+			if err == nil {
+				assert.Len(t, codes.Codes, recoveryCodesCount)
+			}
+		})
+	}
+}
+
+func TestTwoFactorService_CompleteChallenge(t *testing.T) {
+	code, step := currentTOTP()
+	validChallenge := func() *model.LoginChallenge {
+		return &model.LoginChallenge{
+			ID:        GetIntPointer(7),
+			User:      &model.User{ID: GetIntPointer(1)},
+			ExpiresAt: time.Now().Add(time.Minute),
+		}
+	}
+	tests := []struct {
+		name        string
+		code        string
+		prepare     func(repo *mock_dao.MockRepository)
+		wantErr     assert.ErrorAssertionFunc
+		wantErrType error
+	}{
+		{
+			name: "should pass with TOTP code",
+			code: code,
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().GetLoginChallengeByHash(hashToken("challenge")).Return(validChallenge(), nil),
+					repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1), Login: "test"}, nil),
+					repo.EXPECT().GetTwoFactor(1).Return(enabledTwoFactor(), nil),
+					repo.EXPECT().CountLoginAttempt("ip:127.0.0.1", gomock.Any()).Return(true, nil),
+					repo.EXPECT().CountLoginAttempt("login:test", gomock.Any()).Return(true, nil),
+					repo.EXPECT().UseTOTPStep(1, step).Return(true, nil),
+					repo.EXPECT().UseLoginChallenge(7).Return(true, nil),
+				)
+			},
+			wantErr:     assert.NoError,
+			wantErrType: nil,
+		},
+		{
+			name: "should pass with recovery code",
+			code: "abcde-fghij",
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().GetLoginChallengeByHash(hashToken("challenge")).Return(validChallenge(), nil),
+					repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1), Login: "test"}, nil),
+					repo.EXPECT().GetTwoFactor(1).Return(enabledTwoFactor(), nil),
+					repo.EXPECT().CountLoginAttempt("ip:127.0.0.1", gomock.Any()).Return(true, nil),
+					repo.EXPECT().CountLoginAttempt("login:test", gomock.Any()).Return(true, nil),
+					repo.EXPECT().UseRecoveryCode(1, hashRecoveryCode("abcdefghij")).Return(true, nil),
+					repo.EXPECT().UseLoginChallenge(7).Return(true, nil),
+				)
+			},
+			wantErr:     assert.NoError,
+			wantErrType: nil,
+		},
+		{
+			name: "should reject replayed TOTP code and count attempt",
+			code: code,
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					repo.EXPECT().GetLoginChallengeByHash(hashToken("challenge")).Return(validChallenge(), nil),
+					repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1), Login: "test"}, nil),
+					repo.EXPECT().GetTwoFactor(1).Return(enabledTwoFactor(), nil),
+					repo.EXPECT().CountLoginAttempt("ip:127.0.0.1", gomock.Any()).Return(true, nil),
+					repo.EXPECT().CountLoginAttempt("login:test", gomock.Any()).Return(true, nil),
+					repo.EXPECT().UseTOTPStep(1, step).Return(false, nil),
+					repo.EXPECT().IncrementLoginChallengeAttempts(7).Return(nil),
+				)
+			},
+			wantErr:     assert.Error,
+			wantErrType: &errors.InvalidTwoFactorCodeError{},
+		},
+		{
+			name: "should reject code while login is locked",
+			code: code,
+			prepare: func(repo *mock_dao.MockRepository) {
+				lockedUntil := time.Now().Add(time.Minute)
+				gomock.InOrder(
+					repo.EXPECT().GetLoginChallengeByHash(hashToken("challenge")).Return(validChallenge(), nil),
+					repo.EXPECT().GetUserByID(1).Return(&model.User{ID: GetIntPointer(1), Login: "test"}, nil),
+					repo.EXPECT().GetTwoFactor(1).Return(enabledTwoFactor(), nil),
+					repo.EXPECT().CountLoginAttempt("ip:127.0.0.1", gomock.Any()).Return(true, nil),
+					repo.EXPECT().CountLoginAttempt("login:test", gomock.Any()).Return(false, nil),
+					repo.EXPECT().GetLoginAttempt("login:test").Return(&model.LoginAttempt{LockedUntil: &lockedUntil}, nil),
+				)
+			},
+			wantErr:     assert.Error,
+			wantErrType: &errors.LoginLockedError{},
+		},
+		{
+			name: "should reject challenge after too many attempts",
+			code: code,
+			prepare: func(repo *mock_dao.MockRepository) {
+				challenge := validChallenge()
+				challenge.Attempts = loginChallengeMaxAttempts
+				repo.EXPECT().GetLoginChallengeByHash(hashToken("challenge")).Return(challenge, nil)
+			},
+			wantErr:     assert.Error,
+			wantErrType: &errors.InvalidTokenError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewTwoFactorService(repo, NewLoginThrottleService(repo, twoFactorConfig), twoFactorConfig)
+			user, err := s.CompleteChallenge("challenge", tt.code, "127.0.0.1")
+			tt.wantErr(t, err)
+			assert.IsType(t, tt.wantErrType, err)
+			if err == nil {
+				assert.Equal(t, "test", user.Login)
+			}
+		})
+	}
+}
+
+func TestTwoFactorService_AuthorizeWithdrawal(t *testing.T) {
+	code, step := currentTOTP()
+	tests := []struct {
+		name        string
+		sum         float32
+		code        string
+		prepare     func(repo *mock_dao.MockRepository)
+		wantErrType error
+	}{
+		{
+			name:        "should allow withdrawal below threshold",
+			sum:         500,
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			wantErrType: nil,
+		},
+		{
+			name: "should allow withdrawal without two-factor authentication",
+			sum:  5000,
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetTwoFactor(1).Return(&model.TwoFactor{User: &model.User{ID: GetIntPointer(1)}}, nil)
+			},
+			wantErrType: nil,
+		},
+		{
+			name: "should require code above threshold",
+			sum:  5000,
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetTwoFactor(1).Return(enabledTwoFactor(), nil)
+			},
+			wantErrType: &errors.TwoFactorRequiredError{},
+		},
+		{
+			name: "should accept fresh code above threshold",
+			sum:  5000,
+			code: code,
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetTwoFactor(1).Return(enabledTwoFactor(), nil)
+				repo.EXPECT().CountLoginAttempt("2fa:1", gomock.Any()).Return(true, nil)
+				repo.EXPECT().UseTOTPStep(1, step).Return(true, nil)
+				repo.EXPECT().DeleteLoginAttempt("2fa:1").Return(nil)
+			},
+			wantErrType: nil,
+		},
+		{
+			name: "should count wrong code with lockout after too many ones",
+			sum:  5000,
+			code: "000000",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetTwoFactor(1).Return(enabledTwoFactor(), nil)
+				repo.EXPECT().CountLoginAttempt("2fa:1", gomock.Any()).DoAndReturn(
+					func(key string, policy model.LoginAttemptPolicy) (bool, error) {
+						assert.Equal(t, twoFactorCodeMaxAttempts, policy.LockoutAfter)
+						assert.Equal(t, 0, policy.DelayAfter)
+						return true, nil
+					})
+			},
+			wantErrType: &errors.InvalidTwoFactorCodeError{},
+		},
+		{
+			name: "should reject code while withdrawals are locked",
+			sum:  5000,
+			code: code,
+			prepare: func(repo *mock_dao.MockRepository) {
+				lockedUntil := time.Now().Add(time.Minute)
+				repo.EXPECT().GetTwoFactor(1).Return(enabledTwoFactor(), nil)
+				repo.EXPECT().CountLoginAttempt("2fa:1", gomock.Any()).Return(false, nil)
+				repo.EXPECT().GetLoginAttempt("2fa:1").Return(&model.LoginAttempt{LockedUntil: &lockedUntil}, nil)
+			},
+			wantErrType: &errors.LoginLockedError{},
+		},
+		{
+			name: "should not accept recovery code for withdrawal",
+			sum:  5000,
+			code: "abcde-fghij",
+			prepare: func(repo *mock_dao.MockRepository) {
+				repo.EXPECT().GetTwoFactor(1).Return(enabledTwoFactor(), nil)
+				repo.EXPECT().CountLoginAttempt("2fa:1", gomock.Any()).Return(true, nil)
+			},
+			wantErrType: &errors.InvalidTwoFactorCodeError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewTwoFactorService(repo, NewLoginThrottleService(repo, twoFactorConfig), twoFactorConfig)
+			err := s.AuthorizeWithdrawal(1, tt.sum, tt.code)
+			assert.IsType(t, tt.wantErrType, err)
+		})
+	}
+}
+
+func TestTwoFactorService_Disable(t *testing.T) {
+	code, _ := currentTOTP()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+
+	// attempts of user are kept as login_attempts table would keep them
+	attempt := &model.LoginAttempt{Key: "2fa:1"}
+	repo.EXPECT().GetTwoFactor(1).Return(enabledTwoFactor(), nil).AnyTimes()
+	repo.EXPECT().CountLoginAttempt("2fa:1", gomock.Any()).DoAndReturn(func(key string, policy model.LoginAttemptPolicy) (bool, error) {
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(time.Now()) {
+			return false, nil
+		}
+		attempt.Failures++
+		if attempt.Failures >= policy.LockoutAfter {
+			lockedUntil := time.Now().Add(policy.LockoutDuration)
+			attempt.LockedUntil = &lockedUntil
+		}
+		return true, nil
+	}).Times(twoFactorCodeMaxAttempts + 1)
+	repo.EXPECT().GetLoginAttempt("2fa:1").DoAndReturn(func(key string) (*model.LoginAttempt, error) {
+		stored := *attempt
+		return &stored, nil
+	})
+	repo.EXPECT().UseRecoveryCode(1, gomock.Any()).Return(false, nil).Times(twoFactorCodeMaxAttempts)
+
+	s := NewTwoFactorService(repo, NewLoginThrottleService(repo, twoFactorConfig), twoFactorConfig)
+	for i := 0; i < twoFactorCodeMaxAttempts; i++ {
+		assert.IsType(t, &errors.InvalidTwoFactorCodeError{}, s.Disable(1, "abcde-fghij"))
+	}
+	// the 6th code is refused even when it is right, TOTP step is not spent
+	assert.IsType(t, &errors.LoginLockedError{}, s.Disable(1, code))
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"fmt"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	loginMinLength    = 3
+	loginMaxLength    = 64
+	passwordMaxLength = 128
+)
+
+var loginPattern = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+
+// validateUser checks login format and password strength policy for new users.
+func validateUser(user *model.User, cfg *config.ServerConfig) error {
+	validationErr := &errors.ValidationError{}
+
+	loginLength := utf8.RuneCountInString(user.Login)
+	switch {
+	case loginLength == 0:
+		validationErr.Add("login", "must not be empty")
+	case loginLength < loginMinLength || loginLength > loginMaxLength:
+		validationErr.Add("login", fmt.Sprintf("must be from %d to %d characters long", loginMinLength, loginMaxLength))
+	case !loginPattern.MatchString(user.Login):
+		validationErr.Add("login", "may contain only latin letters, digits and . _ @ - characters")
+	}
+
+	validatePassword("password", user.Password, cfg, validationErr)
+
+	if validationErr.HasErrors() {
+		return validationErr
+	}
+	return nil
+}
+
+func validatePassword(field string, password string, cfg *config.ServerConfig, validationErr *errors.ValidationError) {
+	passwordLength := utf8.RuneCountInString(password)
+	if passwordLength < cfg.PasswordMinLength || passwordLength > passwordMaxLength {
+		validationErr.Add(field, fmt.Sprintf("must be from %d to %d characters long", cfg.PasswordMinLength, passwordMaxLength))
+		return
+	}
+	if !cfg.PasswordRequireMixed {
+		return
+	}
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		validationErr.Add(field, "must contain both letters and digits")
+	}
+}
+
+// validateCredentials checks only presence of login and password, it is used on
+// login, as users registered before policy change must still be able to sign in.
+func validateCredentials(user *model.User) error {
+	validationErr := &errors.ValidationError{}
+	if user.Login == "" {
+		validationErr.Add("login", "must not be empty")
+	}
+	if user.Password == "" {
+		validationErr.Add("password", "must not be empty")
+	}
+	if validationErr.HasErrors() {
+		return validationErr
+	}
+	return nil
+}
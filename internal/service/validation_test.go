@@ -0,0 +1,57 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"testing"
+)
+
+func Test_validateUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       model.User
+		wantFields []string
+	}{
+		{
+			name:       "should accept valid user",
+			user:       model.User{Login: "user.name@example", Password: "secret123"},
+			wantFields: nil,
+		},
+		{
+			name:       "should reject empty login and password",
+			user:       model.User{},
+			wantFields: []string{"login", "password"},
+		},
+		{
+			name:       "should reject login with forbidden characters",
+			user:       model.User{Login: "user name", Password: "secret123"},
+			wantFields: []string{"login"},
+		},
+		{
+			name:       "should reject short password",
+			user:       model.User{Login: "user", Password: "s3cret"},
+			wantFields: []string{"password"},
+		},
+		{
+			name:       "should reject password without digits",
+			user:       model.User{Login: "user", Password: "secretsecret"},
+			wantFields: []string{"password"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateUser(&tt.user, authConfig)
+			if tt.wantFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.IsType(t, &errors.ValidationError{}, err)
+			var fields []string
+			for _, field := range err.(*errors.ValidationError).Fields {
+				fields = append(fields, field.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
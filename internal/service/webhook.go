@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	errors2 "errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/yurchenkosv/gofermart/internal/clients"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/dao"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	WebhookEventHeader     = "X-Gophermart-Event"
+	WebhookDeliveryHeader  = "X-Gophermart-Delivery"
+	WebhookTimestampHeader = "X-Gophermart-Timestamp"
+	// WebhookSignatureHeader is "sha256=" followed by hex encoded HMAC-SHA256 of timestamp,
+	// a dot and request body, keyed with webhook secret.
+	WebhookSignatureHeader = "X-Gophermart-Signature"
+
+	webhookMaxPerUser        = 10
+	webhookSecretMinLength   = 16
+	webhookSecretMaxLength   = 128
+	webhookDeliveryBatchSize = 50
+	webhookDeliveryLogLimit  = 100
+	webhookMaxRetryDelay     = 6 * time.Hour
+	// webhookLeaseMargin is added to request timeout, while claimed delivery is hidden from other replicas.
+	webhookLeaseMargin = 30 * time.Second
+)
+
+var knownWebhookEvents = []string{
+	model.WebhookEventOrderProcessed,
+	model.WebhookEventBalanceWithdrawn,
+}
+
+// Webhook manages webhook subscriptions of users and delivers their events. Events are stored
+// in the same transaction as the change they describe, and delivered by DeliverPending.
+type Webhook interface {
+	CreateWebhook(userID int, webhook model.Webhook) (*model.Webhook, error)
+	ListWebhooks(userID int) ([]model.Webhook, error)
+	DeleteWebhook(userID int, webhookID int) error
+	ListDeliveries(userID int, webhookID int) ([]model.WebhookDelivery, error)
+	ReplayDelivery(userID int, webhookID int, deliveryID int) error
+	DeliverPending() error
+}
+
+type WebhookService struct {
+	repo   dao.Repository
+	sender clients.WebhookSender
+	cfg    *config.ServerConfig
+}
+
+func NewWebhookService(repo dao.Repository, sender clients.WebhookSender, cfg *config.ServerConfig) Webhook {
+	return WebhookService{
+		repo:   repo,
+		sender: sender,
+		cfg:    cfg,
+	}
+}
+
+// CreateWebhook subscribes user to events. Secret is generated when not provided,
+// it is returned only here.
+func (s WebhookService) CreateWebhook(userID int, webhook model.Webhook) (*model.Webhook, error) {
+	validationErr := &errors.ValidationError{}
+	endpoint, err := url.Parse(webhook.URL)
+	switch {
+	case err != nil || endpoint.Host == "":
+		validationErr.Add("url", "must be absolute https url")
+	case endpoint.Scheme != "https" && !(endpoint.Scheme == "http" && s.cfg.WebhookAllowHTTP):
+		validationErr.Add("url", "must be absolute https url")
+	case s.sender.CheckHost(endpoint.Hostname()) != nil:
+		validationErr.Add("url", "must resolve to public address")
+	}
+	if len(webhook.EventTypes) == 0 {
+		validationErr.Add("event_types", "must not be empty")
+	}
+	for _, eventType := range webhook.EventTypes {
+		if !isKnownWebhookEvent(eventType) {
+			validationErr.Add("event_types", fmt.Sprintf("must contain only %s", strings.Join(knownWebhookEvents, ", ")))
+			break
+		}
+	}
+	if webhook.Secret != "" && (len(webhook.Secret) < webhookSecretMinLength || len(webhook.Secret) > webhookSecretMaxLength) {
+		validationErr.Add("secret", fmt.Sprintf("must be from %d to %d characters", webhookSecretMinLength, webhookSecretMaxLength))
+	}
+	if validationErr.HasErrors() {
+		return nil, validationErr
+	}
+	if webhook.Secret == "" {
+		webhook.Secret, err = randomToken(32)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	created := model.Webhook{
+		UserID:     userID,
+		URL:        webhook.URL,
+		Secret:     webhook.Secret,
+		EventTypes: webhook.EventTypes,
+		CreatedAt:  time.Now(),
+	}
+	ctx := context.Background()
+	err = s.repo.Atomic(ctx, func(r dao.Repository) error {
+		webhooks, err := r.GetWebhooksByUserID(userID)
+		if err != nil {
+			return err
+		}
+		if len(webhooks) >= webhookMaxPerUser {
+			validationErr.Add("url", fmt.Sprintf("at most %d webhooks are allowed", webhookMaxPerUser))
+			return validationErr
+		}
+		return r.SaveWebhook(&created)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (s WebhookService) ListWebhooks(userID int) ([]model.Webhook, error) {
+	webhooks, err := s.repo.GetWebhooksByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if webhooks == nil {
+		webhooks = []model.Webhook{}
+	}
+	return webhooks, nil
+}
+
+func (s WebhookService) DeleteWebhook(userID int, webhookID int) error {
+	ctx := context.Background()
+	return s.repo.Atomic(ctx, func(r dao.Repository) error {
+		deleted, err := r.DeleteWebhook(userID, webhookID)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return &errors.WebhookNotFoundError{WebhookID: webhookID}
+		}
+		return nil
+	})
+}
+
+// ListDeliveries returns recent delivery attempts of webhook, the newest first.
+func (s WebhookService) ListDeliveries(userID int, webhookID int) ([]model.WebhookDelivery, error) {
+	_, err := s.getWebhook(userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := s.repo.GetWebhookDeliveries(webhookID, webhookDeliveryLogLimit)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery schedules failed delivery to be attempted again.
+func (s WebhookService) ReplayDelivery(userID int, webhookID int, deliveryID int) error {
+	_, err := s.getWebhook(userID, webhookID)
+	if err != nil {
+		return err
+	}
+	replayed, err := s.repo.ReplayWebhookDelivery(webhookID, deliveryID)
+	if err != nil {
+		return err
+	}
+	if !replayed {
+		return &errors.WebhookDeliveryNotReplayableError{DeliveryID: deliveryID}
+	}
+	return nil
+}
+
+// DeliverPending attempts deliveries, which are due. Failed attempt is retried with exponential
+// backoff, until cfg.WebhookMaxAttempts attempts fail.
+func (s WebhookService) DeliverPending() error {
+	leaseUntil := time.Now().Add(s.cfg.WebhookTimeout + webhookLeaseMargin)
+	deliveries, err := s.repo.ClaimWebhookDeliveries(webhookDeliveryBatchSize, leaseUntil)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(delivery *model.WebhookDelivery) {
+			defer wg.Done()
+			if err := s.deliver(delivery); err != nil {
+				log.Errorf("cannot save outcome of webhook delivery %d: %s", *delivery.ID, err)
+			}
+		}(&deliveries[i])
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s WebhookService) deliver(delivery *model.WebhookDelivery) error {
+	webhook, err := s.repo.GetWebhookByID(delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+	currentTime := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &currentTime
+	delivery.NextAttemptAt = nil
+	delivery.ResponseStatus = nil
+	delivery.LastError = ""
+	if webhook.ID == nil {
+		delivery.Status = model.WebhookDeliveryFailed
+		delivery.LastError = "webhook was deleted"
+		return s.repo.UpdateWebhookDelivery(delivery)
+	}
+
+	timestamp := strconv.FormatInt(currentTime.Unix(), 10)
+	status, err := s.sender.Send(webhook.URL, map[string]string{
+		"Content-Type":         "application/json",
+		WebhookEventHeader:     delivery.EventType,
+		WebhookDeliveryHeader:  strconv.Itoa(*delivery.ID),
+		WebhookTimestampHeader: timestamp,
+		WebhookSignatureHeader: "sha256=" + signWebhookPayload(webhook.Secret, timestamp, delivery.Payload),
+	}, delivery.Payload)
+	switch {
+	case err != nil:
+		// error is logged only, as stored one is shown to user and could reveal network details
+		log.Warnf("webhook delivery %d failed: %s", *delivery.ID, err)
+		delivery.LastError = webhookErrorCategory(err)
+	case status < 200 || status > 299:
+		delivery.ResponseStatus = &status
+		delivery.LastError = fmt.Sprintf("unexpected response status %d", status)
+	default:
+		delivery.ResponseStatus = &status
+		delivery.Status = model.WebhookDeliverySucceeded
+		return s.repo.UpdateWebhookDelivery(delivery)
+	}
+
+	if delivery.Attempts >= s.cfg.WebhookMaxAttempts {
+		log.Warnf("webhook delivery %d failed after %d attempts: %s", *delivery.ID, delivery.Attempts, delivery.LastError)
+		delivery.Status = model.WebhookDeliveryFailed
+	} else {
+		nextAttemptAt := currentTime.Add(webhookRetryDelay(s.cfg.WebhookRetryInterval, delivery.Attempts))
+		delivery.Status = model.WebhookDeliveryPending
+		delivery.NextAttemptAt = &nextAttemptAt
+	}
+	return s.repo.UpdateWebhookDelivery(delivery)
+}
+
+func (s WebhookService) getWebhook(userID int, webhookID int) (*model.Webhook, error) {
+	webhook, err := s.repo.GetWebhookByID(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.ID == nil || webhook.UserID != userID {
+		return nil, &errors.WebhookNotFoundError{WebhookID: webhookID}
+	}
+	return webhook, nil
+}
+
+// enqueueWebhookEvent stores deliveries of event for every webhook of user subscribed to it.
+// It has to be called in the transaction, which makes the change described by event.
+func enqueueWebhookEvent(r dao.Repository, userID int, eventType string, data interface{}) error {
+	webhooks, err := r.GetWebhooksByEvent(userID, eventType)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+	currentTime := time.Now()
+	payload, err := json.Marshal(model.WebhookEvent{
+		Type:      eventType,
+		CreatedAt: currentTime,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	for _, webhook := range webhooks {
+		err = r.SaveWebhookDelivery(&model.WebhookDelivery{
+			WebhookID:     *webhook.ID,
+			EventType:     eventType,
+			Payload:       payload,
+			Status:        model.WebhookDeliveryPending,
+			NextAttemptAt: &currentTime,
+			CreatedAt:     currentTime,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func signWebhookPayload(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay doubles interval with every failed attempt, up to webhookMaxRetryDelay.
+func webhookRetryDelay(interval time.Duration, attempts int) time.Duration {
+	delay := interval
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookMaxRetryDelay {
+			return webhookMaxRetryDelay
+		}
+	}
+	return delay
+}
+
+// webhookErrorCategory describes failed request without details of the error.
+func webhookErrorCategory(err error) string {
+	var destinationErr *errors.WebhookDestinationError
+	if errors2.As(err, &destinationErr) {
+		return "destination not allowed"
+	}
+	var netErr net.Error
+	if errors2.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	return "request failed"
+}
+
+func isKnownWebhookEvent(eventType string) bool {
+	for _, known := range knownWebhookEvents {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/yurchenkosv/gofermart/internal/config"
+	"github.com/yurchenkosv/gofermart/internal/errors"
+	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
+	"github.com/yurchenkosv/gofermart/internal/model"
+	"testing"
+	"time"
+)
+
+var webhookTestConfig = &config.ServerConfig{
+	WebhookMaxAttempts:   3,
+	WebhookRetryInterval: time.Minute,
+	WebhookTimeout:       time.Second,
+}
+
+type fakeWebhookSender struct {
+	status  int
+	err     error
+	hostErr error
+	url     string
+	headers map[string]string
+	body    []byte
+}
+
+func (s *fakeWebhookSender) CheckHost(host string) error {
+	return s.hostErr
+}
+
+func (s *fakeWebhookSender) Send(url string, headers map[string]string, body []byte) (int, error) {
+	s.url = url
+	s.headers = headers
+	s.body = body
+	return s.status, s.err
+}
+
+func TestWebhookService_CreateWebhook(t *testing.T) {
+	tests := []struct {
+		name        string
+		webhook     model.Webhook
+		hostErr     error
+		prepare     func(repo *mock_dao.MockRepository)
+		wantErrType error
+	}{
+		{
+			name: "should create webhook with generated secret",
+			webhook: model.Webhook{
+				URL:        "https://example.com/hook",
+				EventTypes: []string{model.WebhookEventOrderProcessed},
+			},
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().GetWebhooksByUserID(1).Return(nil, nil),
+					repo.EXPECT().SaveWebhook(gomock.Any()).DoAndReturn(func(webhook *model.Webhook) error {
+						assert.Equal(t, 1, webhook.UserID)
+						assert.NotEmpty(t, webhook.Secret)
+						webhook.ID = GetIntPointer(5)
+						return nil
+					}),
+				)
+			},
+		},
+		{
+			name: "should reject invalid url and unknown event",
+			webhook: model.Webhook{
+				URL:        "ftp://example.com",
+				EventTypes: []string{"order.created"},
+			},
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			wantErrType: &errors.ValidationError{},
+		},
+		{
+			name: "should reject plain http url",
+			webhook: model.Webhook{
+				URL:        "http://example.com/hook",
+				EventTypes: []string{model.WebhookEventOrderProcessed},
+			},
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			wantErrType: &errors.ValidationError{},
+		},
+		{
+			name: "should reject host resolving to internal address",
+			webhook: model.Webhook{
+				URL:        "https://metadata.internal/latest",
+				EventTypes: []string{model.WebhookEventOrderProcessed},
+			},
+			hostErr:     &errors.WebhookDestinationError{Host: "metadata.internal"},
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			wantErrType: &errors.ValidationError{},
+		},
+		{
+			name: "should reject short secret",
+			webhook: model.Webhook{
+				URL:        "https://example.com/hook",
+				Secret:     "short",
+				EventTypes: []string{model.WebhookEventBalanceWithdrawn},
+			},
+			prepare:     func(repo *mock_dao.MockRepository) {},
+			wantErrType: &errors.ValidationError{},
+		},
+		{
+			name: "should reject webhook over limit",
+			webhook: model.Webhook{
+				URL:        "https://example.com/hook",
+				EventTypes: []string{model.WebhookEventOrderProcessed},
+			},
+			prepare: func(repo *mock_dao.MockRepository) {
+				gomock.InOrder(
+					expectAtomic(repo),
+					repo.EXPECT().GetWebhooksByUserID(1).Return(make([]model.Webhook, webhookMaxPerUser), nil),
+				)
+			},
+			wantErrType: &errors.ValidationError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			tt.prepare(repo)
+			s := NewWebhookService(repo, &fakeWebhookSender{hostErr: tt.hostErr}, webhookTestConfig)
+			created, err := s.CreateWebhook(1, tt.webhook)
+			if tt.wantErrType != nil {
+				assert.IsType(t, tt.wantErrType, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 5, *created.ID)
+			assert.NotEmpty(t, created.Secret)
+		})
+	}
+}
+
+func TestWebhookService_DeliverPending(t *testing.T) {
+	tests := []struct {
+		name            string
+		sender          *fakeWebhookSender
+		attempts        int
+		wantStatus      string
+		wantNextAttempt time.Duration
+		wantError       string
+	}{
+		{
+			name:       "should mark successful delivery",
+			sender:     &fakeWebhookSender{status: 204},
+			wantStatus: model.WebhookDeliverySucceeded,
+		},
+		{
+			name:            "should retry with backoff after error status",
+			sender:          &fakeWebhookSender{status: 500},
+			attempts:        1,
+			wantStatus:      model.WebhookDeliveryPending,
+			wantNextAttempt: 2 * time.Minute,
+		},
+		{
+			name:            "should retry after connection error",
+			sender:          &fakeWebhookSender{err: fmt.Errorf("dial tcp 10.0.0.1:22: connection refused")},
+			wantStatus:      model.WebhookDeliveryPending,
+			wantNextAttempt: time.Minute,
+			wantError:       "request failed",
+		},
+		{
+			name:       "should fail delivery after max attempts",
+			sender:     &fakeWebhookSender{status: 500},
+			attempts:   2,
+			wantStatus: model.WebhookDeliveryFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_dao.NewMockRepository(ctrl)
+			payload := []byte(`{"type":"order.processed"}`)
+			gomock.InOrder(
+				repo.EXPECT().ClaimWebhookDeliveries(webhookDeliveryBatchSize, gomock.Any()).Return([]model.WebhookDelivery{{
+					ID:        GetIntPointer(7),
+					WebhookID: 5,
+					EventType: model.WebhookEventOrderProcessed,
+					Payload:   payload,
+					Status:    model.WebhookDeliveryPending,
+					Attempts:  tt.attempts,
+				}}, nil),
+				repo.EXPECT().GetWebhookByID(5).Return(&model.Webhook{
+					ID:     GetIntPointer(5),
+					URL:    "https://example.com/hook",
+					Secret: "secret",
+				}, nil),
+				repo.EXPECT().UpdateWebhookDelivery(gomock.Any()).DoAndReturn(func(delivery *model.WebhookDelivery) error {
+					assert.Equal(t, tt.attempts+1, delivery.Attempts)
+					assert.Equal(t, tt.wantStatus, delivery.Status)
+					if tt.wantNextAttempt == 0 {
+						assert.Nil(t, delivery.NextAttemptAt)
+					} else {
+						assert.Equal(t, tt.wantNextAttempt, delivery.NextAttemptAt.Sub(*delivery.LastAttemptAt))
+					}
+					return nil
+				}),
+			)
+			s := NewWebhookService(repo, tt.sender, webhookTestConfig)
+			assert.NoError(t, s.DeliverPending())
+
+			timestamp := tt.sender.headers[WebhookTimestampHeader]
+			assert.Equal(t, "https://example.com/hook", tt.sender.url)
+			assert.Equal(t, "7", tt.sender.headers[WebhookDeliveryHeader])
+			assert.Equal(t, "sha256="+signWebhookPayload("secret", timestamp, payload), tt.sender.headers[WebhookSignatureHeader])
+		})
+	}
+}
+
+func Test_signWebhookPayload(t *testing.T) {
+	// echo -n '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163",
+		signWebhookPayload("secret", "1700000000", []byte("{}")),
+	)
+}
+
+func Test_webhookRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Minute, webhookRetryDelay(time.Minute, 1))
+	assert.Equal(t, 4*time.Minute, webhookRetryDelay(time.Minute, 3))
+	assert.Equal(t, webhookMaxRetryDelay, webhookRetryDelay(time.Minute, 30))
+}
+
+func TestWebhookService_ReplayDelivery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_dao.NewMockRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().GetWebhookByID(5).Return(&model.Webhook{ID: GetIntPointer(5), UserID: 1}, nil),
+		repo.EXPECT().ReplayWebhookDelivery(5, 7).Return(true, nil),
+		repo.EXPECT().GetWebhookByID(5).Return(&model.Webhook{ID: GetIntPointer(5), UserID: 1}, nil),
+		repo.EXPECT().ReplayWebhookDelivery(5, 8).Return(false, nil),
+		repo.EXPECT().GetWebhookByID(5).Return(&model.Webhook{ID: GetIntPointer(5), UserID: 1}, nil),
+	)
+	s := NewWebhookService(repo, &fakeWebhookSender{}, webhookTestConfig)
+	assert.NoError(t, s.ReplayDelivery(1, 5, 7))
+	assert.IsType(t, &errors.WebhookDeliveryNotReplayableError{}, s.ReplayDelivery(1, 5, 8))
+	assert.IsType(t, &errors.WebhookNotFoundError{}, s.ReplayDelivery(2, 5, 7))
+}
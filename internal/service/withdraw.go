@@ -2,12 +2,10 @@ package service
 
 import (
 	"context"
-	"database/sql"
-	errors2 "errors"
 	"github.com/yurchenkosv/gofermart/internal/dao"
 	"github.com/yurchenkosv/gofermart/internal/errors"
 	"github.com/yurchenkosv/gofermart/internal/model"
-	"strconv"
+	"github.com/yurchenkosv/gofermart/internal/validator"
 )
 
 type Withdraw interface {
@@ -16,11 +14,15 @@ type Withdraw interface {
 }
 
 type WithdrawService struct {
-	repo dao.Repository
+	repo            dao.Repository
+	numberValidator validator.Validator
 }
 
-func NewWithdrawService(repo dao.Repository) Withdraw {
-	return WithdrawService{repo: repo}
+func NewWithdrawService(repo dao.Repository, numberValidator validator.Validator) Withdraw {
+	return WithdrawService{
+		repo:            repo,
+		numberValidator: numberValidator,
+	}
 }
 
 func (s WithdrawService) GetWithdrawalsForCurrentUser(UserID int) ([]*model.Withdraw, error) {
@@ -35,35 +37,43 @@ func (s WithdrawService) GetWithdrawalsForCurrentUser(UserID int) ([]*model.With
 }
 
 func (s WithdrawService) ProcessWithdraw(withdraw model.Withdraw) error {
-	orderNum, _ := strconv.Atoi(withdraw.Order)
-	if !checkOrderFormat(orderNum) {
+	withdraw.Order = s.numberValidator.Normalize(withdraw.Order)
+	if !s.numberValidator.Valid(withdraw.Order) {
 		return &errors.OrderFormatError{OrderNumber: withdraw.Order}
 	}
-	b := model.Balance{User: model.User{ID: withdraw.User.ID}}
-	currentBalance, err := s.repo.GetBalanceByUserID(*b.User.ID)
-	if errors2.Is(err, sql.ErrNoRows) {
-		return &errors.LowBalanceError{}
-	}
-
-	expectedAfterWithdraw := currentBalance.Balance - withdraw.Sum
-	if expectedAfterWithdraw < 0 {
-		return &errors.LowBalanceError{
-			CurrentBalance: currentBalance.Balance,
-		}
-	}
-	b.Balance = expectedAfterWithdraw
-	b.SpentAllTime = currentBalance.SpentAllTime + withdraw.Sum
 	ctx := context.Background()
-	err = s.repo.Atomic(ctx, func(r dao.Repository) error {
-		err = s.repo.SaveBalance(&b)
+	err := s.repo.Atomic(ctx, func(r dao.Repository) error {
+		// balance is locked until commit, so concurrent withdrawals can't spend it twice
+		balance, err := r.LockBalanceByUserID(*withdraw.User.ID)
+		if err != nil {
+			return err
+		}
+		if withdraw.IdempotencyKeyID != nil {
+			// retry took over the key, while the first attempt was still running, and waited for its commit
+			withdrawn, err := r.IsWithdrawnByIdempotencyKey(*withdraw.IdempotencyKeyID)
+			if err != nil {
+				return err
+			}
+			if withdrawn {
+				return nil
+			}
+		}
+		if balance.Balance-withdraw.Sum < 0 {
+			return &errors.LowBalanceError{
+				CurrentBalance: balance.Balance,
+			}
+		}
+		balance.Balance -= withdraw.Sum
+		balance.SpentAllTime += withdraw.Sum
+		err = r.SaveBalance(balance)
 		if err != nil {
 			return err
 		}
-		err = s.repo.SaveWithdraw(&withdraw)
+		err = r.SaveWithdraw(&withdraw)
 		if err != nil {
 			return err
 		}
-		return nil
+		return enqueueWebhookEvent(r, *withdraw.User.ID, model.WebhookEventBalanceWithdrawn, &withdraw)
 	})
 	if err != nil {
 		return err
@@ -7,6 +7,7 @@ import (
 	"github.com/yurchenkosv/gofermart/internal/errors"
 	mock_dao "github.com/yurchenkosv/gofermart/internal/mocks"
 	"github.com/yurchenkosv/gofermart/internal/model"
+	"github.com/yurchenkosv/gofermart/internal/validator"
 	"testing"
 	"time"
 )
@@ -54,7 +55,7 @@ func TestGetWithdrawalsForCurrentUser(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			authRepo := mock_dao.NewMockRepository(ctrl)
-			withdrawService := NewWithdrawService(authRepo)
+			withdrawService := NewWithdrawService(authRepo, validator.NewLuhnValidator())
 			tt.behavior(authRepo, tt.id)
 			got, err := withdrawService.GetWithdrawalsForCurrentUser(tt.id)
 			if (err != nil) != tt.wantErr {
@@ -84,7 +85,8 @@ func TestWithdrawService_ProcessWithdraw(t *testing.T) {
 		{
 			name: "should success process withdraw",
 			prepare: func(f *fields, withdraw model.Withdraw) {
-				f.repo.EXPECT().GetBalanceByUserID(*withdraw.User.ID).Return(
+				expectAtomic(f.repo)
+				f.repo.EXPECT().LockBalanceByUserID(*withdraw.User.ID).Return(
 					&model.Balance{
 						User:         model.User{ID: withdraw.User.ID},
 						Balance:      100,
@@ -97,6 +99,7 @@ func TestWithdrawService_ProcessWithdraw(t *testing.T) {
 					SpentAllTime: 150,
 				}).Return(nil)
 				f.repo.EXPECT().SaveWithdraw(&withdraw).Return(nil)
+				f.repo.EXPECT().GetWebhooksByEvent(1, model.WebhookEventBalanceWithdrawn).Return(nil, nil)
 			},
 			wantErr:     assert.NoError,
 			wantErrType: nil,
@@ -109,6 +112,29 @@ func TestWithdrawService_ProcessWithdraw(t *testing.T) {
 				},
 			}},
 		},
+		{
+			name: "should not withdraw again for retry of taken over request",
+			prepare: func(f *fields, withdraw model.Withdraw) {
+				expectAtomic(f.repo)
+				f.repo.EXPECT().LockBalanceByUserID(*withdraw.User.ID).Return(
+					&model.Balance{
+						User:         model.User{ID: withdraw.User.ID},
+						Balance:      50,
+						SpentAllTime: 150,
+					},
+					nil)
+				f.repo.EXPECT().IsWithdrawnByIdempotencyKey(7).Return(true, nil)
+			},
+			wantErr:     assert.NoError,
+			wantErrType: nil,
+			args: args{withdraw: model.Withdraw{
+				Order:            "2377225624",
+				Sum:              50,
+				ProcessedAt:      time.Unix(123123132, 0),
+				User:             model.User{ID: GetIntPointer(1)},
+				IdempotencyKeyID: GetIntPointer(7),
+			}},
+		},
 		{
 			name:        "should return OrderFormatError",
 			prepare:     func(f *fields, withdraw model.Withdraw) {},
@@ -126,7 +152,8 @@ func TestWithdrawService_ProcessWithdraw(t *testing.T) {
 		{
 			name: "should return LowBalanceError",
 			prepare: func(f *fields, withdraw model.Withdraw) {
-				f.repo.EXPECT().GetBalanceByUserID(*withdraw.User.ID).Return(
+				expectAtomic(f.repo)
+				f.repo.EXPECT().LockBalanceByUserID(*withdraw.User.ID).Return(
 					&model.Balance{
 						User:         model.User{ID: withdraw.User.ID},
 						Balance:      100,
@@ -152,7 +179,8 @@ func TestWithdrawService_ProcessWithdraw(t *testing.T) {
 			f := fields{repo: mock_dao.NewMockRepository(ctrl)}
 			tt.prepare(&f, tt.args.withdraw)
 			s := WithdrawService{
-				repo: f.repo,
+				repo:            f.repo,
+				numberValidator: validator.NewLuhnValidator(),
 			}
 			err := s.ProcessWithdraw(tt.args.withdraw)
 			tt.wantErr(t, err, fmt.Sprintf("ProcessWithdraw(%v)", tt.args.withdraw))
@@ -0,0 +1,48 @@
+package validator
+
+const luhnMaxLength = 64
+
+// LuhnValidator accepts numbers of any length up to luhnMaxLength digits with valid
+// Luhn check digit. Check works on the digit string, so long numbers do not overflow.
+type LuhnValidator struct{}
+
+func NewLuhnValidator() Validator {
+	return LuhnValidator{}
+}
+
+func (v LuhnValidator) Normalize(number string) string {
+	return normalize(number)
+}
+
+func (v LuhnValidator) Valid(number string) bool {
+	if len(number) < 2 || len(number) > luhnMaxLength || !isDigits(number) {
+		return false
+	}
+	return luhnValid(number)
+}
+
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func isDigits(number string) bool {
+	for i := 0; i < len(number); i++ {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+	}
+	return number != ""
+}
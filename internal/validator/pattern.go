@@ -0,0 +1,30 @@
+package validator
+
+import "regexp"
+
+// PatternValidator accepts normalized numbers matching partner specific pattern,
+// optionally requiring valid Luhn check digit as well.
+type PatternValidator struct {
+	pattern *regexp.Regexp
+	luhn    bool
+}
+
+// NewPatternValidator compiles pattern, which is anchored to match whole number.
+func NewPatternValidator(pattern string, luhn bool) (Validator, error) {
+	compiled, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return PatternValidator{pattern: compiled, luhn: luhn}, nil
+}
+
+func (v PatternValidator) Normalize(number string) string {
+	return normalize(number)
+}
+
+func (v PatternValidator) Valid(number string) bool {
+	if !v.pattern.MatchString(number) {
+		return false
+	}
+	return !v.luhn || (isDigits(number) && luhnValid(number))
+}
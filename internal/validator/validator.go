@@ -0,0 +1,65 @@
+package validator
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Validator checks format of order numbers. Numbers are normalized before both
+// validation and lookup, so the same order written differently is still the same order.
+type Validator interface {
+	Normalize(number string) string
+	Valid(number string) bool
+}
+
+// Registry keeps order number formats of partner merchants. Orders uploaded by users
+// and by merchants without own format are checked by Luhn validator.
+type Registry struct {
+	mu               sync.RWMutex
+	defaultValidator Validator
+	partners         map[int]Validator
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		defaultValidator: NewLuhnValidator(),
+		partners:         map[int]Validator{},
+	}
+}
+
+func (r *Registry) Register(merchantID int, validator Validator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.partners[merchantID] = validator
+}
+
+// Unregister returns merchant to default validator.
+func (r *Registry) Unregister(merchantID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.partners, merchantID)
+}
+
+// For returns validator of merchant, merchantID is nil for orders uploaded by users.
+func (r *Registry) For(merchantID *int) Validator {
+	if merchantID == nil {
+		return r.defaultValidator
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if validator, ok := r.partners[*merchantID]; ok {
+		return validator
+	}
+	return r.defaultValidator
+}
+
+// normalize removes whitespace and separators people put between digit groups.
+func normalize(number string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' || r == '_' || r == '.' {
+			return -1
+		}
+		return r
+	}, number)
+}
@@ -0,0 +1,83 @@
+package validator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLuhnValidator(t *testing.T) {
+	tests := []struct {
+		name   string
+		number string
+		want   bool
+	}{
+		{
+			name:   "should success with right Luhn number",
+			number: "2377225624",
+			want:   true,
+		},
+		{
+			name:   "should fail with wrong Luhn number",
+			number: "1234567890",
+			want:   false,
+		},
+		{
+			name:   "should success with number longer than int64",
+			number: "123456789012345678901234567891",
+			want:   true,
+		},
+		{
+			name:   "should fail with wrong long number",
+			number: "123456789012345678901234567890",
+			want:   false,
+		},
+		{
+			name:   "should fail with letters",
+			number: "2377a225624",
+			want:   false,
+		},
+		{
+			name:   "should fail with empty number",
+			number: "",
+			want:   false,
+		},
+	}
+	v := NewLuhnValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, v.Valid(tt.number))
+		})
+	}
+}
+
+func TestLuhnValidator_Normalize(t *testing.T) {
+	v := NewLuhnValidator()
+	assert.Equal(t, "2377225624", v.Normalize(" 2377 2256-24\n"))
+	assert.Equal(t, "2377225624", v.Normalize("2377.2256_24"))
+	assert.True(t, v.Valid(v.Normalize("4561 2612 1234 5467")))
+}
+
+func TestRegistry_For(t *testing.T) {
+	partner, err := NewPatternValidator(`SHOP-?[0-9]{6}`, false)
+	require.NoError(t, err)
+	registry := NewRegistry()
+	registry.Register(3, partner)
+
+	merchantID := 3
+	v := registry.For(&merchantID)
+	assert.True(t, v.Valid(v.Normalize("SHOP 123456")))
+	assert.False(t, v.Valid(v.Normalize("SHOP 1234567")))
+
+	otherMerchantID := 4
+	assert.True(t, registry.For(&otherMerchantID).Valid("2377225624"))
+	assert.False(t, registry.For(nil).Valid("SHOP123456"))
+}
+
+func TestPatternValidator_Luhn(t *testing.T) {
+	v, err := NewPatternValidator(`9[0-9]+`, true)
+	require.NoError(t, err)
+	assert.True(t, v.Valid("91"))
+	assert.False(t, v.Valid("92"))
+	assert.False(t, v.Valid("2377225624"))
+}